@@ -3,13 +3,16 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"slices"
+	"time"
 
 	"github.com/prymitive/karma/internal/alertmanager"
 	"github.com/prymitive/karma/internal/config"
 	"github.com/prymitive/karma/internal/models"
 	"github.com/prymitive/karma/internal/regex"
+	"github.com/prymitive/karma/internal/transform"
 )
 
 const (
@@ -104,14 +107,69 @@ type silenceACLScope struct {
 	Filters       []silenceFilter
 }
 
+type aclConstraints struct {
+	CommentRegex  *regexp.Regexp
+	MaxDuration   time.Duration
+	MaxStartsIn   time.Duration
+	RequireTicket bool
+}
+
+func (ac *aclConstraints) isEmpty() bool {
+	return ac.CommentRegex == nil && ac.MaxDuration == 0 && ac.MaxStartsIn == 0 && !ac.RequireTicket
+}
+
+// check returns an error describing the first constraint that given silence
+// doesn't satisfy
+func (ac *aclConstraints) check(silence *models.Silence, now time.Time) error {
+	// Alertmanager will start silences with startsAt in the past right away
+	startsAt := silence.StartsAt
+	if startsAt.Before(now) {
+		startsAt = now
+	}
+
+	if ac.MaxStartsIn > 0 {
+		if startsIn := startsAt.Sub(now); startsIn > ac.MaxStartsIn {
+			return fmt.Errorf("silence starts in %s, it must start within %s", startsIn.Round(time.Second), ac.MaxStartsIn)
+		}
+	}
+
+	if ac.MaxDuration > 0 {
+		if duration := silence.EndsAt.Sub(startsAt); duration > ac.MaxDuration {
+			return fmt.Errorf("silence duration %s is longer than the maximum of %s", duration.Round(time.Second), ac.MaxDuration)
+		}
+	}
+
+	if ac.CommentRegex != nil && !ac.CommentRegex.MatchString(silence.Comment) {
+		return fmt.Errorf("silence comment must match %q", ac.CommentRegex.String())
+	}
+
+	if ac.RequireTicket {
+		if ticketID, _ := transform.DetectLinks(silence); ticketID == "" {
+			return errors.New("silence comment must include a ticket ID")
+		}
+	}
+
+	return nil
+}
+
 type silenceACL struct {
-	Action   string
-	Reason   string
-	Scope    silenceACLScope
-	Matchers aclMatchers
+	Action      string
+	Reason      string
+	Scope       silenceACLScope
+	Matchers    aclMatchers
+	Constraints aclConstraints
 }
 
-func (acl *silenceACL) isAllowed(amName string, silence *models.Silence, groups []string) (bool, error) {
+// isAllowed checks if silence is allowed by this rule, constraints are only
+// checked if withConstraints is true
+func (acl *silenceACL) isAllowed(amName string, silence *models.Silence, groups []string, withConstraints bool) (bool, error) {
+	checkConstraints := func() error {
+		if !withConstraints {
+			return nil
+		}
+		return acl.Constraints.check(silence, time.Now())
+	}
+
 	groupMatch := len(acl.Scope.Groups) == 0
 	for _, aclGroup := range acl.Scope.Groups {
 		if slices.Contains(groups, aclGroup) {
@@ -135,9 +193,22 @@ func (acl *silenceACL) isAllowed(amName string, silence *models.Silence, groups
 	if groupMatch && amMatch && filterMatch {
 		switch acl.Action {
 		case aclActionAllow:
+			// allow rules with constraints only allow silences satisfying
+			// all of them, other silences are blocked so users can see
+			// which constraint wasn't met
+			if err := checkConstraints(); err != nil {
+				return false, fmt.Errorf("silence blocked by ACL rule: %s: %w", acl.Reason, err)
+			}
 			return true, nil
 		case aclActionBlock:
-			return false, fmt.Errorf("silence blocked by ACL rule: %s", acl.Reason)
+			if acl.Constraints.isEmpty() {
+				return false, fmt.Errorf("silence blocked by ACL rule: %s", acl.Reason)
+			}
+			// block rules with constraints only block silences violating
+			// any of them
+			if err := checkConstraints(); err != nil {
+				return false, fmt.Errorf("silence blocked by ACL rule: %s: %w", acl.Reason, err)
+			}
 		case aclActionRequireMatcher:
 			for _, aclM := range acl.Matchers.Required {
 				var wasFound bool
@@ -148,12 +219,87 @@ func (acl *silenceACL) isAllowed(amName string, silence *models.Silence, groups
 					return false, fmt.Errorf("silence blocked by ACL rule: %s", acl.Reason)
 				}
 			}
+			if err := checkConstraints(); err != nil {
+				return false, fmt.Errorf("silence blocked by ACL rule: %s: %w", acl.Reason, err)
+			}
 		}
 	}
 
 	return false, nil
 }
 
+// checkSilenceACLs evaluates all configured silence ACL rules in order and
+// returns an error if given silence is blocked, first rule allowing the silence
+// stops the evaluation
+func checkSilenceACLs(amName string, silence *models.Silence, groups []string) error {
+	return evalSilenceACLs(amName, silence, groups, true)
+}
+
+// checkSilenceExpireACLs evaluates all configured silence ACL rules for a
+// silence that is being expired, constraints only apply to the content of
+// new or edited silences so they are skipped, otherwise existing silences
+// created before the constraints were added could never be expired
+func checkSilenceExpireACLs(amName string, silence *models.Silence, groups []string) error {
+	return evalSilenceACLs(amName, silence, groups, false)
+}
+
+func evalSilenceACLs(amName string, silence *models.Silence, groups []string, withConstraints bool) error {
+	for i, acl := range silenceACLs {
+		isAllowed, err := acl.isAllowed(amName, silence, groups, withConstraints)
+		slog.Debug("ACL rule check", slog.Int("index", i), slog.Bool("allowed", isAllowed), slog.Any("error", err))
+		if err != nil {
+			return err
+		}
+		if isAllowed {
+			return nil
+		}
+	}
+	return nil
+}
+
+// newSilenceFilterFromConfig validates silence filter config and compiles all
+// regexes used by it
+func newSilenceFilterFromConfig(filter config.SilenceFilters) (silenceFilter, error) {
+	if filter.Name == "" && filter.NameRegex == "" {
+		return silenceFilter{}, errors.New("silence ACL rule filter requires 'name' or 'name_re' to be set")
+	}
+	if filter.Name != "" && filter.NameRegex != "" {
+		return silenceFilter{}, errors.New("silence ACL rule filter can only have 'name' or 'name_re' set, not both")
+	}
+
+	if filter.Value == "" && filter.ValueRegex == "" {
+		return silenceFilter{}, errors.New("silence ACL rule filter requires 'value' or 'value_re' to be set")
+	}
+	if filter.Value != "" && filter.ValueRegex != "" {
+		return silenceFilter{}, errors.New("silence ACL rule filter can only have 'value' or 'value_re' set, not both")
+	}
+
+	f := silenceFilter{
+		Name:    filter.Name,
+		Value:   filter.Value,
+		IsRegex: filter.IsRegex,
+		IsEqual: filter.IsEqual,
+	}
+
+	if filter.NameRegex != "" {
+		re, err := regex.CompileAnchored(filter.NameRegex)
+		if err != nil {
+			return silenceFilter{}, fmt.Errorf("invalid ACL rule, failed to parse name_re %q: %w", filter.NameRegex, err)
+		}
+		f.NameRegex = re
+	}
+
+	if filter.ValueRegex != "" {
+		re, err := regex.CompileAnchored(filter.ValueRegex)
+		if err != nil {
+			return silenceFilter{}, fmt.Errorf("invalid ACL rule, failed to parse value_re %q: %w", filter.ValueRegex, err)
+		}
+		f.ValueRegex = re
+	}
+
+	return f, nil
+}
+
 func newSilenceACLFromConfig(cfg config.SilenceACLRule) (*silenceACL, error) {
 	acl := silenceACL{
 		Action: cfg.Action,
@@ -201,43 +347,10 @@ func newSilenceACLFromConfig(cfg config.SilenceACLRule) (*silenceACL, error) {
 	}
 
 	for _, filter := range cfg.Scope.Filters {
-		if filter.Name == "" && filter.NameRegex == "" {
-			return nil, errors.New("silence ACL rule filter requires 'name' or 'name_re' to be set")
-		}
-		if filter.Name != "" && filter.NameRegex != "" {
-			return nil, errors.New("silence ACL rule filter can only have 'name' or 'name_re' set, not both")
-		}
-
-		if filter.Value == "" && filter.ValueRegex == "" {
-			return nil, errors.New("silence ACL rule filter requires 'value' or 'value_re' to be set")
-		}
-		if filter.Value != "" && filter.ValueRegex != "" {
-			return nil, errors.New("silence ACL rule filter can only have 'value' or 'value_re' set, not both")
+		f, err := newSilenceFilterFromConfig(filter)
+		if err != nil {
+			return nil, err
 		}
-
-		f := silenceFilter{
-			Name:    filter.Name,
-			Value:   filter.Value,
-			IsRegex: filter.IsRegex,
-			IsEqual: filter.IsEqual,
-		}
-
-		if filter.NameRegex != "" {
-			re, err := regex.CompileAnchored(filter.NameRegex)
-			if err != nil {
-				return nil, fmt.Errorf("invalid ACL rule, failed to parse name_re %q: %w", filter.NameRegex, err)
-			}
-			f.NameRegex = re
-		}
-
-		if filter.ValueRegex != "" {
-			re, err := regex.CompileAnchored(filter.ValueRegex)
-			if err != nil {
-				return nil, fmt.Errorf("invalid ACL rule, failed to parse value_re %q: %w", filter.ValueRegex, err)
-			}
-			f.ValueRegex = re
-		}
-
 		acl.Scope.Filters = append(acl.Scope.Filters, f)
 	}
 
@@ -284,5 +397,28 @@ func newSilenceACLFromConfig(cfg config.SilenceACLRule) (*silenceACL, error) {
 		}
 	}
 
+	if cfg.Constraints.MaxDuration < 0 {
+		return nil, errors.New("silence ACL rule constraint 'maxDuration' must be >= 0")
+	}
+	acl.Constraints.MaxDuration = cfg.Constraints.MaxDuration
+
+	if cfg.Constraints.MaxStartsIn < 0 {
+		return nil, errors.New("silence ACL rule constraint 'maxStartsIn' must be >= 0")
+	}
+	acl.Constraints.MaxStartsIn = cfg.Constraints.MaxStartsIn
+
+	if cfg.Constraints.CommentRegex != "" {
+		re, err := regexp.Compile(cfg.Constraints.CommentRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACL rule, failed to parse commentRegex %q: %w", cfg.Constraints.CommentRegex, err)
+		}
+		acl.Constraints.CommentRegex = re
+	}
+
+	if cfg.Constraints.RequireTicket && len(config.Config.Silences.Comments.LinkDetect.Rules) == 0 {
+		return nil, errors.New("silence ACL rule constraint 'requireTicket' needs silences.comments.linkDetect rules to be configured")
+	}
+	acl.Constraints.RequireTicket = cfg.Constraints.RequireTicket
+
 	return &acl, nil
 }
@@ -3,8 +3,10 @@ package main
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/transform"
 )
 
 func truePtr() *bool {
@@ -182,3 +184,128 @@ func TestAclSilenceMatcher(t *testing.T) {
 		}
 	}
 }
+
+func TestAclConstraints(t *testing.T) {
+	transform.SetLinkRules([]models.LinkDetectRule{
+		{Regex: regexp.MustCompile("(TICKET-[0-9]+)"), URITemplate: "https://tickets.example.com/$1"},
+	})
+	defer transform.SetLinkRules(nil)
+
+	prodFilter := silenceFilter{Name: "cluster", Value: "prod"}
+	prodPolicy := []*silenceACL{
+		{
+			Action:      aclActionBlock,
+			Reason:      "production silences can't be longer than 7 days",
+			Scope:       silenceACLScope{Filters: []silenceFilter{prodFilter}},
+			Constraints: aclConstraints{MaxDuration: time.Hour * 24 * 7},
+		},
+		{
+			Action:      aclActionAllow,
+			Reason:      "production silences require a ticket",
+			Scope:       silenceACLScope{Filters: []silenceFilter{prodFilter}},
+			Constraints: aclConstraints{RequireTicket: true},
+		},
+		{
+			Action: aclActionBlock,
+			Reason: "silences are blocked by default",
+		},
+	}
+
+	type testCaseT struct {
+		name    string
+		acls    []*silenceACL
+		silence models.Silence
+		err     string
+	}
+
+	now := time.Now()
+	prodMatchers := []models.SilenceMatcher{models.NewSilenceMatcher("cluster", "prod", false, true)}
+	devMatchers := []models.SilenceMatcher{models.NewSilenceMatcher("cluster", "dev", false, true)}
+
+	testCases := []testCaseT{
+		{
+			name:    "prod silence with a ticket is allowed",
+			acls:    prodPolicy,
+			silence: models.Silence{Matchers: prodMatchers, StartsAt: now, EndsAt: now.Add(time.Hour * 48), Comment: "TICKET-1"},
+		},
+		{
+			name:    "prod silence without a ticket is rejected by allow rule constraints",
+			acls:    prodPolicy,
+			silence: models.Silence{Matchers: prodMatchers, StartsAt: now, EndsAt: now.Add(time.Hour), Comment: "no ticket"},
+			err:     "silence blocked by ACL rule: production silences require a ticket: silence comment must include a ticket ID",
+		},
+		{
+			name:    "long prod silence with a ticket is blocked",
+			acls:    prodPolicy,
+			silence: models.Silence{Matchers: prodMatchers, StartsAt: now, EndsAt: now.Add(time.Hour * 24 * 8), Comment: "TICKET-1"},
+			err:     "silence blocked by ACL rule: production silences can't be longer than 7 days: silence duration 192h0m0s is longer than the maximum of 168h0m0s",
+		},
+		{
+			name:    "dev silence is blocked by the default rule",
+			acls:    prodPolicy,
+			silence: models.Silence{Matchers: devMatchers, StartsAt: now, EndsAt: now.Add(time.Hour)},
+			err:     "silence blocked by ACL rule: silences are blocked by default",
+		},
+		{
+			name: "duration is counted from now for silences starting in the past",
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "too long", Constraints: aclConstraints{MaxDuration: time.Hour}},
+			},
+			silence: models.Silence{Matchers: devMatchers, StartsAt: now.Add(time.Hour * -24), EndsAt: now.Add(time.Minute * 30)},
+		},
+		{
+			name: "silence starting too late is blocked",
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "plan ahead less", Constraints: aclConstraints{MaxStartsIn: time.Hour * 24}},
+			},
+			silence: models.Silence{Matchers: devMatchers, StartsAt: now.Add(time.Hour * 72), EndsAt: now.Add(time.Hour * 73)},
+			err:     "silence blocked by ACL rule: plan ahead less: silence starts in 72h0m0s, it must start within 24h0m0s",
+		},
+		{
+			name: "comment not matching regex is blocked",
+			acls: []*silenceACL{
+				{
+					Action:      aclActionRequireMatcher,
+					Reason:      "explain why",
+					Constraints: aclConstraints{CommentRegex: regexp.MustCompile("^reason: .+")},
+				},
+			},
+			silence: models.Silence{Matchers: devMatchers, StartsAt: now, EndsAt: now.Add(time.Hour), Comment: "foo"},
+			err:     `silence blocked by ACL rule: explain why: silence comment must match "^reason: .+"`,
+		},
+		{
+			name: "comment matching regex is allowed",
+			acls: []*silenceACL{
+				{
+					Action:      aclActionRequireMatcher,
+					Reason:      "explain why",
+					Constraints: aclConstraints{CommentRegex: regexp.MustCompile("^reason: .+")},
+				},
+			},
+			silence: models.Silence{Matchers: devMatchers, StartsAt: now, EndsAt: now.Add(time.Hour), Comment: "reason: foo"},
+		},
+		{
+			name: "missing ticket is blocked",
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "tickets required", Constraints: aclConstraints{RequireTicket: true}},
+			},
+			silence: models.Silence{Matchers: devMatchers, StartsAt: now, EndsAt: now.Add(time.Hour), Comment: "foo"},
+			err:     "silence blocked by ACL rule: tickets required: silence comment must include a ticket ID",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			silenceACLs = testCase.acls
+			defer func() { silenceACLs = []*silenceACL{} }()
+
+			var errMsg string
+			if err := checkSilenceACLs("default", &testCase.silence, []string{}); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != testCase.err {
+				t.Errorf("checkSilenceACLs() returned %q, expected %q", errMsg, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/models"
+)
+
+const (
+	alertExportLabelPrefix      = "labels."
+	alertExportAnnotationPrefix = "annotations."
+)
+
+var defaultAlertExportColumns = []string{
+	"labels",
+	"annotations",
+	"state",
+	"startsAt",
+	"receiver",
+	"alertmanager",
+	"silencedBy",
+}
+
+var alertExportColumns = []string{
+	"labels",
+	"annotations",
+	"state",
+	"startsAt",
+	"receiver",
+	"alertmanager",
+	"cluster",
+	"silencedBy",
+	"inhibitedBy",
+	"fingerprint",
+}
+
+// alertExportRow is a single deduplicated alert with group labels merged
+// into alert labels, alerts routed to multiple receivers have one row for
+// each receiver
+type alertExportRow struct {
+	startsAt     time.Time
+	state        string
+	receiver     string
+	fingerprint  string
+	labels       promlabels.Labels
+	annotations  map[string]string
+	alertmanager []string
+	cluster      []string
+	silencedBy   []string
+	inhibitedBy  []string
+}
+
+// parseAlertExportColumns validates the list of columns passed via
+// ?columns=..., it accepts either a comma separated list or repeated values
+func parseAlertExportColumns(values []string) ([]string, error) {
+	columns := []string{}
+	for _, v := range values {
+		for col := range strings.SplitSeq(v, ",") {
+			col = strings.TrimSpace(col)
+			if col == "" {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(col, alertExportLabelPrefix) && col != alertExportLabelPrefix:
+			case strings.HasPrefix(col, alertExportAnnotationPrefix) && col != alertExportAnnotationPrefix:
+			case slices.Contains(alertExportColumns, col):
+			default:
+				return nil, fmt.Errorf("invalid column %q, valid columns are: %s, %sNAME and %sNAME",
+					col, strings.Join(alertExportColumns, ", "), alertExportLabelPrefix, alertExportAnnotationPrefix)
+			}
+			columns = append(columns, col)
+		}
+	}
+	if len(columns) == 0 {
+		return defaultAlertExportColumns, nil
+	}
+	return columns, nil
+}
+
+func newAlertExportRows(groups []models.AlertGroup) []alertExportRow {
+	rows := []alertExportRow{}
+	for _, ag := range groups {
+		for _, alert := range ag.Alerts {
+			b := promlabels.NewBuilder(alert.Labels)
+			ag.Labels.Range(func(l promlabels.Label) {
+				b.Set(l.Name, l.Value)
+			})
+			row := alertExportRow{
+				startsAt:    alert.StartsAt,
+				state:       alert.State.String(),
+				receiver:    alert.Receiver,
+				fingerprint: alert.LabelsFP,
+				labels:      b.Labels(),
+				annotations: make(map[string]string, len(alert.Annotations)),
+			}
+			for _, a := range alert.Annotations {
+				row.annotations[a.Name] = a.Value
+			}
+			for _, am := range alert.Alertmanager {
+				row.alertmanager = append(row.alertmanager, am.Name)
+				row.cluster = append(row.cluster, am.Cluster)
+				row.silencedBy = append(row.silencedBy, am.SilencedBy...)
+				row.inhibitedBy = append(row.inhibitedBy, am.InhibitedBy...)
+			}
+			row.alertmanager = slices.Compact(slices.Sorted(slices.Values(row.alertmanager)))
+			row.cluster = slices.Compact(slices.Sorted(slices.Values(row.cluster)))
+			row.silencedBy = slices.Compact(slices.Sorted(slices.Values(row.silencedBy)))
+			row.inhibitedBy = slices.Compact(slices.Sorted(slices.Values(row.inhibitedBy)))
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := promlabels.Compare(rows[i].labels, rows[j].labels); c != 0 {
+			return c < 0
+		}
+		return rows[i].receiver < rows[j].receiver
+	})
+	return rows
+}
+
+// value returns the value of given column, labels and annotations are
+// returned as a map, lists as a slice of strings
+func (row alertExportRow) value(column string) any {
+	switch column {
+	case "labels":
+		return row.labels.Map()
+	case "annotations":
+		return row.annotations
+	case "state":
+		return row.state
+	case "startsAt":
+		return row.startsAt.UTC().Format(time.RFC3339)
+	case "receiver":
+		return row.receiver
+	case "alertmanager":
+		return nonNilStrings(row.alertmanager)
+	case "cluster":
+		return nonNilStrings(row.cluster)
+	case "silencedBy":
+		return nonNilStrings(row.silencedBy)
+	case "inhibitedBy":
+		return nonNilStrings(row.inhibitedBy)
+	case "fingerprint":
+		return row.fingerprint
+	}
+	if name, ok := strings.CutPrefix(column, alertExportLabelPrefix); ok {
+		return row.labels.Get(name)
+	}
+	if name, ok := strings.CutPrefix(column, alertExportAnnotationPrefix); ok {
+		return row.annotations[name]
+	}
+	return ""
+}
+
+// text returns the value of given column as a single string, labels and
+// annotations use Prometheus selector syntax, lists are comma separated
+func (row alertExportRow) text(column string) string {
+	switch v := row.value(column).(type) {
+	case map[string]string:
+		return promlabels.FromMap(v).String()
+	case []string:
+		return strings.Join(v, ",")
+	case string:
+		return v
+	}
+	return ""
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// escapeTSV replaces characters that can't be present in a TSV field with
+// escape sequences
+var escapeTSV = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace
+
+// escapeCSVFormula prefixes values that spreadsheets would evaluate as a
+// formula with ', label and annotation values come from alerting rules and
+// must not be executed when a CSV or TSV export is opened
+func escapeCSVFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeAlertExportCSV(out io.Writer, columns []string, rows []alertExportRow) {
+	w := csv.NewWriter(out)
+	_ = w.Write(columns)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			record[i] = escapeCSVFormula(row.text(col))
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+}
+
+func writeAlertExportTSV(out io.Writer, columns []string, rows []alertExportRow) {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = escapeTSV(col)
+	}
+	_, _ = io.WriteString(out, strings.Join(header, "\t")+"\n")
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			record[i] = escapeTSV(escapeCSVFormula(row.text(col)))
+		}
+		_, _ = io.WriteString(out, strings.Join(record, "\t")+"\n")
+	}
+}
+
+func writeAlertExportNDJSON(out io.Writer, columns []string, rows []alertExportRow) {
+	// the encoder writes a newline after each top-level value
+	enc := jsontext.NewEncoder(out, jsontext.EscapeForHTML(true))
+	for _, row := range rows {
+		_ = enc.WriteToken(jsontext.BeginObject)
+		for _, col := range columns {
+			_ = enc.WriteToken(jsontext.String(col))
+			_ = jsonv2.MarshalEncode(enc, row.value(col), jsonv2.Deterministic(true))
+		}
+		_ = enc.WriteToken(jsontext.EndObject)
+	}
+}
+
+// alertExportHandler streams one row per deduplicated alert matching filters
+// passed via ?q=..., columns can be selected via ?columns=...
+func alertExportHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		noCache(w)
+
+		columnValues, _ := lookupQueryStringSlice(r, "columns")
+		columns, err := parseAlertExportColumns(columnValues)
+		if err != nil {
+			badRequestJSON(w, err.Error())
+			return
+		}
+
+		q, _ := lookupQueryStringSlice(r, "q")
+		rows := newAlertExportRows(filterAlerts(alertmanager.DedupAlerts(), getFiltersFromQuery(q)))
+
+		switch format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		case "tsv":
+			w.Header().Set("Content-Type", "text/tab-separated-values; charset=utf-8")
+		case "ndjson":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=alerts.%s", format))
+		w.WriteHeader(http.StatusOK)
+
+		switch format {
+		case "csv":
+			writeAlertExportCSV(w, columns, rows)
+		case "tsv":
+			writeAlertExportTSV(w, columns, rows)
+		case "ndjson":
+			writeAlertExportNDJSON(w, columns, rows)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+)
+
+func TestAlertExport(t *testing.T) {
+	type testCaseT struct {
+		name        string
+		path        string
+		code        int
+		contentType string
+		body        string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:        "csv",
+			path:        "/alerts/export.csv?q=alertname=HTTP_Probe_Failed&q=@receiver=by-name",
+			code:        200,
+			contentType: "text/csv; charset=utf-8",
+			body: `labels,annotations,state,startsAt,receiver,alertmanager,silencedBy
+"{alertname=""HTTP_Probe_Failed"", cluster=""dev"", instance=""web1"", job=""node_exporter""}","{help=""Example help annotation"", summary=""Example summary"", url=""http://localhost/example.html""}",suppressed,2026-03-11T12:48:38Z,by-name,default,810ccf7f-c957-474a-b383-7e76d66a4d3b
+"{alertname=""HTTP_Probe_Failed"", cluster=""dev"", instance=""web2"", job=""node_exporter""}","{summary=""Example summary""}",active,2026-03-11T12:48:38Z,by-name,default,
+`,
+		},
+		{
+			name:        "tsv with custom columns",
+			path:        "/alerts/export.tsv?q=alertname=HTTP_Probe_Failed&columns=labels.instance,receiver&columns=annotations.help",
+			code:        200,
+			contentType: "text/tab-separated-values; charset=utf-8",
+			body: "labels.instance\treceiver\tannotations.help\n" +
+				"web1\tby-cluster-service\tExample help annotation\n" +
+				"web1\tby-name\tExample help annotation\n" +
+				"web2\tby-cluster-service\t\n" +
+				"web2\tby-name\t\n",
+		},
+		{
+			name:        "ndjson",
+			path:        "/alerts/export.ndjson?q=instance=server7&q=@receiver=by-name&columns=labels,silencedBy,inhibitedBy,cluster",
+			code:        200,
+			contentType: "application/x-ndjson",
+			body: `{"labels":{"alertname":"Host_Down","cluster":"dev","instance":"server7","ip":"127.0.0.7","job":"node_ping"},"silencedBy":["9bd58938-25fd-41c5-aba3-9bc373074484","dcb3b5d0-9f10-4baa-977a-70073a1899bd"],"inhibitedBy":[],"cluster":["default"]}
+`,
+		},
+		{
+			name:        "no matching alerts",
+			path:        "/alerts/export.ndjson?q=alertname=Foo",
+			code:        200,
+			contentType: "application/x-ndjson",
+			body:        "",
+		},
+		{
+			name:        "invalid column",
+			path:        "/alerts/export.csv?columns=labels,foo",
+			code:        400,
+			contentType: "application/json",
+			body:        `{"error":"invalid column \"foo\", valid columns are: labels, annotations, state, startsAt, receiver, alertmanager, cluster, silencedBy, inhibitedBy, fingerprint, labels.NAME and annotations.NAME"}`,
+		},
+		{
+			name:        "empty label column",
+			path:        "/alerts/export.tsv?columns=labels.",
+			code:        400,
+			contentType: "application/json",
+			body:        `{"error":"invalid column \"labels.\", valid columns are: labels, annotations, state, startsAt, receiver, alertmanager, cluster, silencedBy, inhibitedBy, fingerprint, labels.NAME and annotations.NAME"}`,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("GET %s returned status %d, %d expected", tc.path, resp.Code, tc.code)
+			}
+			if ct := resp.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("GET %s returned Content-Type %q, %q expected", tc.path, ct, tc.contentType)
+			}
+			if diff := cmp.Diff(tc.body, resp.Body.String()); diff != "" {
+				t.Errorf("Incorrect response body (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAlertExportCSVFormulas(t *testing.T) {
+	rows := []alertExportRow{
+		{
+			labels: promlabels.FromStrings("alertname", "=HYPERLINK(\"http://example.com\")", "instance", "+1", "job", "-1"),
+			annotations: map[string]string{
+				"summary": "@SUM(A1:A2)",
+				"help":    "foo=bar",
+			},
+		},
+	}
+	var buf bytes.Buffer
+	writeAlertExportCSV(&buf, []string{"labels.alertname", "labels.instance", "labels.job", "annotations.summary", "annotations.help"}, rows)
+	expected := `labels.alertname,labels.instance,labels.job,annotations.summary,annotations.help
+"'=HYPERLINK(""http://example.com"")",'+1,'-1,'@SUM(A1:A2),foo=bar
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Incorrect CSV (-want +got):\n%s", diff)
+	}
+}
+
+func TestAlertExportTSVFormulas(t *testing.T) {
+	rows := []alertExportRow{
+		{
+			labels: promlabels.FromStrings("alertname", "=HYPERLINK(\"http://example.com\")", "instance", "+1", "job", "-1"),
+			annotations: map[string]string{
+				"summary": "@SUM(A1:A2)",
+				"help":    "\t=1+1",
+			},
+		},
+	}
+	var buf bytes.Buffer
+	writeAlertExportTSV(&buf, []string{"labels.alertname", "labels.instance", "labels.job", "annotations.summary", "annotations.help"}, rows)
+	expected := "labels.alertname\tlabels.instance\tlabels.job\tannotations.summary\tannotations.help\n" +
+		"'=HYPERLINK(\"http://example.com\")\t'+1\t'-1\t'@SUM(A1:A2)\t'\\t=1+1\n"
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Incorrect TSV (-want +got):\n%s", diff)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"regexp"
 	"slices"
 	"sort"
 	"strings"
@@ -20,6 +22,7 @@ import (
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 
 	"github.com/prymitive/karma/internal/alertmanager"
@@ -30,9 +33,85 @@ import (
 
 type AlertHistoryPayload struct {
 	Labels  map[string]string `json:"labels"`
+	Range   string            `json:"range"`
+	Step    string            `json:"step"`
 	Sources []string          `json:"sources"`
 }
 
+const (
+	defaultHistoryRange = time.Hour * 24
+	defaultHistoryStep  = time.Hour
+)
+
+// historyRange describes the time range of a history query, end is aligned
+// to a multiple of step so all sources return samples with the same
+// timestamps
+type historyRange struct {
+	end     time.Time
+	step    time.Duration
+	samples int
+}
+
+func newHistoryRange(payload AlertHistoryPayload, now time.Time) (historyRange, error) {
+	hr := historyRange{step: defaultHistoryStep}
+	duration := defaultHistoryRange
+
+	if payload.Range != "" {
+		d, err := model.ParseDuration(payload.Range)
+		if err != nil {
+			return hr, fmt.Errorf("invalid range: %w", err)
+		}
+		duration = time.Duration(d)
+	}
+	if payload.Step != "" {
+		d, err := model.ParseDuration(payload.Step)
+		if err != nil {
+			return hr, fmt.Errorf("invalid step: %w", err)
+		}
+		hr.step = time.Duration(d)
+	}
+
+	switch {
+	case duration <= 0:
+		return hr, errors.New("range must be > 0")
+	case duration > config.Config.History.MaxRange:
+		return hr, fmt.Errorf("range must be <= %s", model.Duration(config.Config.History.MaxRange))
+	case hr.step < config.Config.History.MinStep:
+		return hr, fmt.Errorf("step must be >= %s", model.Duration(config.Config.History.MinStep))
+	case hr.step > duration:
+		return hr, errors.New("step must be <= range")
+	case duration%hr.step != 0:
+		return hr, errors.New("range must be a multiple of step")
+	}
+
+	hr.samples = int(duration / hr.step)
+	if hr.samples > config.Config.History.MaxSamples {
+		return hr, fmt.Errorf("range and step would return %d samples, maximum is %d", hr.samples, config.Config.History.MaxSamples)
+	}
+
+	// each sample counts alert changes in the (timestamp - step, timestamp]
+	// window, round up so the current window is included
+	hr.end = now.Truncate(hr.step)
+	if hr.end.Before(now) {
+		hr.end = hr.end.Add(hr.step)
+	}
+	return hr, nil
+}
+
+func (hr historyRange) start() time.Time {
+	return hr.end.Add(-hr.step * time.Duration(hr.samples-1))
+}
+
+// bucket returns the index of the sample that given timestamp belongs to,
+// samples are sorted from the newest to the oldest
+func (hr historyRange) bucket(ts time.Time) (int, bool) {
+	if ts.After(hr.end) {
+		return 0, false
+	}
+	i := int(hr.end.Sub(ts) / hr.step)
+	return i, i < hr.samples
+}
+
 type OffsetSample struct {
 	Timestamp time.Time `json:"timestamp"`
 	Value     int       `json:"value"`
@@ -54,44 +133,42 @@ func alertHistory(historyPoller *historyPoller, w http.ResponseWriter, r *http.R
 	var payload AlertHistoryPayload
 	err := jsonv2.UnmarshalRead(r.Body, &payload)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequestJSON(w, err.Error())
 		return
 	}
 
-	perSource := map[string][]OffsetSample{}
-	errors := []string{}
+	hr, err := newHistoryRange(payload, time.Now())
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
 
-	for _, source := range payload.Sources {
-		results := make(chan historyQueryResult)
-		historyPoller.submit(source, payload.Labels, results)
-		r := <-results
-		if r.err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %s", source, r.err))
-			continue
-		}
-		perSource[source] = r.values
+	perSource, errs := queryHistory(historyPoller, payload.Sources, payload.Labels, hr, nil)
+
+	// use recorded history if we didn't get anything from Prometheus
+	if alertHistoryRecorder.enabled && len(perSource) == 0 {
+		slog.Debug("Using recorded alert history", slog.Any("labels", payload.Labels), slog.Any("errors", errs))
+		perSource["karma"] = []historySeries{{values: alertHistoryRecorder.count(payload.Labels, hr)}}
+		errs = nil
 	}
 
 	var e string
-	if len(errors) > 0 {
-		e = "One or more errors occurred when querying Prometheus API: " + strings.Join(errors, ", ")
+	if len(errs) > 0 {
+		e = "One or more errors occurred when querying Prometheus API: " + strings.Join(errs, ", ")
 	}
 	resp := AlertHistoryResponse{
 		Error:   e,
-		Samples: make([]OffsetSample, 24),
+		Samples: make([]OffsetSample, hr.samples),
 	}
-	ts := time.Now().Add(time.Hour)
-	for i := range 24 {
-		ts = ts.Add(time.Hour * -1)
-		resp.Samples[i].Timestamp = ts
+	for i := range resp.Samples {
+		resp.Samples[i].Timestamp = hr.end.Add(-hr.step * time.Duration(i))
 	}
 
-	for _, vals := range perSource {
-		for _, val := range vals {
-			for i, fin := range resp.Samples {
-				if absTimeDiff(val.Timestamp, fin.Timestamp) < time.Minute*30 {
+	for _, series := range perSource {
+		for _, hs := range series {
+			for _, val := range hs.values {
+				if i, ok := hr.bucket(val.Timestamp); ok {
 					resp.Samples[i].Value += val.Value
-					break
 				}
 			}
 		}
@@ -103,25 +180,249 @@ func alertHistory(historyPoller *historyPoller, w http.ResponseWriter, r *http.R
 	_, _ = w.Write(data)
 }
 
+// HistoryKnownBad is a history query that recently failed, it won't be
+// retried until it expires
+type HistoryKnownBad struct {
+	Timestamp time.Time         `json:"timestamp"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	Labels    map[string]string `json:"labels"`
+	URI       string            `json:"uri"`
+	Error     string            `json:"error"`
+}
+
+func alertHistoryKnownBad(historyPoller *historyPoller, w http.ResponseWriter, _ *http.Request) {
+	noCache(w)
+
+	if !config.Config.History.Enabled {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp := []HistoryKnownBad{}
+	for _, kb := range historyPoller.knownBadList(time.Now()) {
+		resp = append(resp, HistoryKnownBad{
+			Timestamp: kb.timestamp,
+			ExpiresAt: kb.timestamp.Add(historyCacheTTL),
+			Labels:    kb.labels,
+			URI:       kb.uri,
+			Error:     kb.err.Error(),
+		})
+	}
+
+	data, _ := marshalJSON(resp)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// historySeries is a single time series returned by a history query
+type historySeries struct {
+	labels model.Metric
+	values []OffsetSample
+}
+
+// matchHistoryGroup splits sources into those handled by given history group
+// and the rest, groups without any sources will handle all of them
+func matchHistoryGroup(group config.HistoryGroup, sources []string) (matched, rest []string) {
+	if len(group.SourceRegexes) == 0 {
+		return sources, nil
+	}
+	for _, source := range sources {
+		uri := strings.TrimSuffix(source, "/")
+		if slices.ContainsFunc(group.SourceRegexes, func(re *regexp.Regexp) bool { return re.MatchString(uri) }) {
+			matched = append(matched, source)
+		} else {
+			rest = append(rest, source)
+		}
+	}
+	return matched, rest
+}
+
+// queryHistory runs history queries for all given sources, sources matching
+// any history group are queried together, results are returned for each
+// source or group name
+func queryHistory(hp *historyPoller, sources []string, labels map[string]string, hr historyRange, groupBy []string) (map[string][]historySeries, []string) {
+	perSource := map[string][]historySeries{}
+	errs := []string{}
+
+	rest := sources
+	for _, group := range config.Config.History.Groups {
+		var matched []string
+		matched, rest = matchHistoryGroup(group, rest)
+		// groups without sources are used for all alerts with no source or
+		// with sources not handled by any other group
+		if len(matched) == 0 && (len(group.SourceRegexes) > 0 || len(sources) > 0) {
+			continue
+		}
+		uris := matched
+		if group.URI != "" {
+			uris = []string{group.URI}
+		}
+		by := groupBy
+		if len(groupBy) > 0 {
+			// keep replica labels in aggregated results so we can dedup them
+			by = append(slices.Clone(groupBy), group.ReplicaLabels...)
+		}
+		var series []historySeries
+		var groupErrs []string
+		var isOK bool
+		for _, uri := range uris {
+			r := hp.query(uri, labels, hr, by)
+			switch {
+			case r.err != nil:
+				groupErrs = append(groupErrs, fmt.Sprintf("%s: %s", uri, r.err))
+			case !r.disabled:
+				series = append(series, r.series...)
+				isOK = true
+			}
+		}
+		// one replica is enough, only report errors if all queries failed
+		if isOK {
+			perSource[group.Name] = dedupHistorySeries(series, group.ReplicaLabels)
+		} else {
+			errs = append(errs, groupErrs...)
+		}
+	}
+
+	for _, source := range rest {
+		r := hp.query(source, labels, hr, groupBy)
+		switch {
+		case r.err != nil:
+			errs = append(errs, fmt.Sprintf("%s: %s", source, r.err))
+		case !r.disabled:
+			perSource[source] = r.series
+		}
+	}
+
+	return perSource, errs
+}
+
+// dedupHistorySeries merges series that are identical once all replica labels
+// are removed, like results from both Prometheus servers in a HA pair, using
+// the highest value seen for each timestamp
+func dedupHistorySeries(series []historySeries, replicaLabels []string) []historySeries {
+	merged := []historySeries{}
+	index := map[model.Fingerprint]int{}
+	for _, hs := range series {
+		metric := hs.labels.Clone()
+		for _, name := range replicaLabels {
+			delete(metric, model.LabelName(name))
+		}
+		fp := metric.Fingerprint()
+		i, ok := index[fp]
+		if !ok {
+			i = len(merged)
+			index[fp] = i
+			merged = append(merged, historySeries{labels: metric})
+		}
+		for _, sample := range hs.values {
+			j := slices.IndexFunc(merged[i].values, func(s OffsetSample) bool { return s.Timestamp.Equal(sample.Timestamp) })
+			switch {
+			case j < 0:
+				merged[i].values = append(merged[i].values, sample)
+			case sample.Value > merged[i].values[j].Value:
+				merged[i].values[j].Value = sample.Value
+			}
+		}
+	}
+	return merged
+}
+
 type historyQueryResult struct {
 	err    error
-	values []OffsetSample
+	series []historySeries
+	// set if history queries for this source were disabled via rewrite rules
+	disabled bool
 }
 
 type historyJob struct {
 	labels map[string]string
 	result chan<- historyQueryResult
 	uri    string
+	// if set results are aggregated by these labels
+	groupBy []string
+	hr      historyRange
 }
 
 type cachedOffsets struct {
 	timestamp time.Time
-	values    []OffsetSample
+	series    []historySeries
 }
 
 type knownBadUpstream struct {
 	timestamp time.Time
 	err       error
+	uri       string
+	labels    map[string]string
+}
+
+// history query results and errors are reused for this long
+const historyCacheTTL = time.Minute * 5
+
+var (
+	historyQueueLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "karma_history_queue_length",
+			Help: "Number of history queries waiting for a free worker.",
+		},
+	)
+	historyWorkers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "karma_history_workers",
+			Help: "Number of history query workers.",
+		},
+	)
+	historyWorkersBusy = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "karma_history_workers_busy",
+			Help: "Number of history query workers currently processing a query.",
+		},
+	)
+	historyQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "karma_history_query_duration_seconds",
+			Help:    "History query latencies in seconds.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20},
+		}, []string{"uri"},
+	)
+	historyQueryErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "karma_history_query_errors_total",
+			Help: "Total number of failed history queries.",
+		}, []string{"uri"},
+	)
+	historyCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "karma_history_cache_lookups_total",
+			Help: "Total number of history cache lookups, result is one of hit, miss or known_bad.",
+		}, []string{"result"},
+	)
+	historyKnownBad = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "karma_history_known_bad_count",
+			Help: "Number of history queries that recently failed and won't be retried until they expire.",
+		},
+		func() float64 {
+			if hp := runningHistoryPoller.Load(); hp != nil {
+				return float64(len(hp.knownBadList(time.Now())))
+			}
+			return 0
+		},
+	)
+	// runningHistoryPoller is used to count known bad entries at scrape time
+	runningHistoryPoller atomic.Pointer[historyPoller]
+)
+
+func init() {
+	prometheus.MustRegister(
+		historyQueueLength,
+		historyWorkers,
+		historyWorkersBusy,
+		historyQueryDuration,
+		historyQueryErrors,
+		historyCacheLookups,
+		historyKnownBad,
+	)
 }
 
 type historyPoller struct {
@@ -145,7 +446,9 @@ func newHistoryPoller(queueSize int, queryTimeout time.Duration) *historyPoller
 }
 
 func (hp *historyPoller) run(workers int) {
+	runningHistoryPoller.Store(hp)
 	hp.isRunning.Store(true)
+	historyWorkers.Set(float64(workers))
 	wg := sync.WaitGroup{}
 	for w := 1; w <= workers; w++ {
 		wg.Go(func() {
@@ -161,14 +464,22 @@ func (hp *historyPoller) stop() {
 	close(hp.queue)
 }
 
-func (hp *historyPoller) submit(uri string, labels map[string]string, result chan<- historyQueryResult) {
+func (hp *historyPoller) submit(uri string, labels map[string]string, hr historyRange, groupBy []string, result chan<- historyQueryResult) {
 	if hp.isRunning.Load() {
-		hp.queue <- historyJob{uri: uri, labels: labels, result: result}
+		hp.queue <- historyJob{uri: uri, labels: labels, hr: hr, groupBy: groupBy, result: result}
+		historyQueueLength.Set(float64(len(hp.queue)))
 	}
 }
 
-func (hp *historyPoller) cacheSave(key string, values []OffsetSample) {
-	_ = hp.cache.Add(key, &cachedOffsets{timestamp: time.Now(), values: values})
+// query submits a single history query and waits for the result
+func (hp *historyPoller) query(uri string, labels map[string]string, hr historyRange, groupBy []string) historyQueryResult {
+	result := make(chan historyQueryResult)
+	hp.submit(uri, labels, hr, groupBy, result)
+	return <-result
+}
+
+func (hp *historyPoller) cacheSave(key string, series []historySeries) {
+	_ = hp.cache.Add(key, &cachedOffsets{timestamp: time.Now(), series: series})
 }
 
 func (hp *historyPoller) cacheLookup(key string) *cachedOffsets {
@@ -189,66 +500,98 @@ func (hp *historyPoller) knownBadLookup(key string) (*knownBadUpstream, bool) {
 	return nil, false
 }
 
+// knownBadList returns all known bad entries that didn't expire yet, newest
+// first
+func (hp *historyPoller) knownBadList(now time.Time) []knownBadUpstream {
+	expiredAt := now.Add(-historyCacheTTL)
+	kbs := []knownBadUpstream{}
+	for _, kb := range hp.knownBad.Values() {
+		if kb.timestamp.After(expiredAt) {
+			kbs = append(kbs, *kb)
+		}
+	}
+	sort.SliceStable(kbs, func(i, j int) bool {
+		return kbs[i].timestamp.After(kbs[j].timestamp)
+	})
+	return kbs
+}
+
 func (hp *historyPoller) startWorker(wid int) {
 	slog.Debug("Starting history poller", slog.Int("worker", wid), slog.Int("queue", cap(hp.queue)), slog.Duration("timeout", hp.queryTimeout))
 	for j := range hp.queue {
-		sourceURI, headers := rewriteSource(config.Config.History.Rewrite, j.uri)
-		expiredAt := time.Now().Add(time.Minute * -5)
-		key := hashQuery(sourceURI, j.labels)
-		if kb, found := hp.knownBadLookup(key); found && kb.timestamp.After(expiredAt) {
-			slog.Debug(
-				"Upstream already marked as invalid, skipping",
-				slog.Int("worker", wid),
-				slog.String("uri", sourceURI),
-				slog.Any("labels", j.labels),
-				slog.String("key", key),
-			)
-			j.result <- historyQueryResult{values: nil, err: kb.err}
-			continue
-		}
-		if v := hp.cacheLookup(key); v != nil && v.timestamp.After(expiredAt) {
-			slog.Debug(
-				"Got results from cache",
-				slog.Int("worker", wid),
-				slog.String("uri", sourceURI),
-				slog.Any("labels", j.labels),
-				slog.String("key", key),
-			)
-			j.result <- historyQueryResult{values: v.values, err: nil}
-			continue
-		}
-		transport, err := rewriteTransport(config.Config.History.Rewrite, j.uri)
-		if err != nil {
-			slog.Warn(
-				"Error while configuring HTTP transport for history request",
-				slog.Int("worker", wid),
-				slog.String("uri", sourceURI),
-				slog.Any("error", err),
-			)
-		}
-		if len(headers) > 0 {
-			transport = mapper.SetHeaders(transport, headers)
-		}
-		values, err := countAlerts(sourceURI, hp.queryTimeout, transport, j.labels)
-		if err != nil {
-			slog.Error(
-				"History query failed",
-				slog.Any("error", err),
-				slog.Int("worker", wid),
-				slog.String("uri", sourceURI),
-				slog.Any("labels", j.labels),
-			)
-			hp.knownBadSave(key, knownBadUpstream{timestamp: time.Now(), err: err})
-		} else {
-			hp.cacheSave(key, values)
-		}
-		j.result <- historyQueryResult{values: values, err: err}
+		historyQueueLength.Set(float64(len(hp.queue)))
+		historyWorkersBusy.Inc()
+		j.result <- hp.process(wid, j)
+		historyWorkersBusy.Dec()
 	}
 }
 
-func hashQuery(uri string, labels map[string]string) string {
+func (hp *historyPoller) process(wid int, j historyJob) historyQueryResult {
+	sourceURI, headers := rewriteSource(config.Config.History.Rewrite, j.uri)
+	if sourceURI == "" {
+		return historyQueryResult{disabled: true}
+	}
+	expiredAt := time.Now().Add(-historyCacheTTL)
+	key := hashQuery(sourceURI, j.labels, j.hr, j.groupBy)
+	if kb, found := hp.knownBadLookup(key); found && kb.timestamp.After(expiredAt) {
+		slog.Debug(
+			"Upstream already marked as invalid, skipping",
+			slog.Int("worker", wid),
+			slog.String("uri", sourceURI),
+			slog.Any("labels", j.labels),
+			slog.String("key", key),
+		)
+		historyCacheLookups.WithLabelValues("known_bad").Inc()
+		return historyQueryResult{series: nil, err: kb.err}
+	}
+	if v := hp.cacheLookup(key); v != nil && v.timestamp.After(expiredAt) {
+		slog.Debug(
+			"Got results from cache",
+			slog.Int("worker", wid),
+			slog.String("uri", sourceURI),
+			slog.Any("labels", j.labels),
+			slog.String("key", key),
+		)
+		historyCacheLookups.WithLabelValues("hit").Inc()
+		return historyQueryResult{series: v.series, err: nil}
+	}
+	historyCacheLookups.WithLabelValues("miss").Inc()
+	transport, err := rewriteTransport(config.Config.History.Rewrite, j.uri)
+	if err != nil {
+		slog.Warn(
+			"Error while configuring HTTP transport for history request",
+			slog.Int("worker", wid),
+			slog.String("uri", sourceURI),
+			slog.Any("error", err),
+		)
+	}
+	if len(headers) > 0 {
+		transport = mapper.SetHeaders(transport, headers)
+	}
+	sanitizedURI := uriUtil.SanitizeURI(sourceURI)
+	start := time.Now()
+	series, err := countAlerts(sourceURI, hp.queryTimeout, transport, j.labels, j.hr, j.groupBy)
+	historyQueryDuration.WithLabelValues(sanitizedURI).Observe(time.Since(start).Seconds())
+	if err != nil {
+		slog.Error(
+			"History query failed",
+			slog.Any("error", err),
+			slog.Int("worker", wid),
+			slog.String("uri", sourceURI),
+			slog.Any("labels", j.labels),
+		)
+		historyQueryErrors.WithLabelValues(sanitizedURI).Inc()
+		hp.knownBadSave(key, knownBadUpstream{timestamp: time.Now(), err: err, uri: sanitizedURI, labels: j.labels})
+	} else {
+		hp.cacheSave(key, series)
+	}
+	return historyQueryResult{series: series, err: err}
+}
+
+func hashQuery(uri string, labels map[string]string, hr historyRange, groupBy []string) string {
 	hasher := sha1.New()
 	_, _ = io.WriteString(hasher, uri)
+	_, _ = fmt.Fprintf(hasher, "\n%d\n%s\n%d\n%s\n", hr.samples, hr.step, hr.end.Unix(), strings.Join(groupBy, ","))
 	kvs := make([]string, 0, len(labels))
 	for k, v := range labels {
 		kvs = append(kvs, fmt.Sprintf("%s=%s", k, v))
@@ -306,7 +649,7 @@ func rewriteTransport(rules []config.HistoryRewrite, uri string) (http.RoundTrip
 	return http.DefaultTransport, nil
 }
 
-func countAlerts(uri string, timeout time.Duration, transport http.RoundTripper, labels map[string]string) (ret []OffsetSample, err error) {
+func countAlerts(uri string, timeout time.Duration, transport http.RoundTripper, labels map[string]string, hr historyRange, groupBy []string) (ret []historySeries, err error) {
 	if uri == "" {
 		return ret, err
 	}
@@ -334,9 +677,9 @@ func countAlerts(uri string, timeout time.Duration, transport http.RoundTripper,
 	}
 
 	r := v1.Range{
-		Start: time.Now().Add(-time.Hour * 23),
-		End:   time.Now(),
-		Step:  time.Hour,
+		Start: hr.start(),
+		End:   hr.end,
+		Step:  hr.step,
 	}
 
 	lv := model.LabelSet{}
@@ -345,7 +688,10 @@ func countAlerts(uri string, timeout time.Duration, transport http.RoundTripper,
 			lv[model.LabelName(k)] = model.LabelValue(v)
 		}
 	}
-	q := fmt.Sprintf("changes(ALERTS_FOR_STATE%s[1h])", lv)
+	q := fmt.Sprintf("changes(ALERTS_FOR_STATE%s[%s])", lv, model.Duration(hr.step))
+	if len(groupBy) > 0 {
+		q = fmt.Sprintf("sum by (%s) (%s)", strings.Join(groupBy, ", "), q)
+	}
 	slog.Debug(
 		"Send alert count query",
 		slog.String("uri", uri),
@@ -362,22 +708,17 @@ func countAlerts(uri string, timeout time.Duration, transport http.RoundTripper,
 	if samples, ok := result.(model.Matrix); ok {
 		for _, sample := range samples {
 			slog.Debug("Sample values", slog.Int("values", len(sample.Values)))
+			hs := historySeries{labels: sample.Metric}
 			for _, pair := range sample.Values {
 				slog.Debug("Sample", slog.Int("value", int(pair.Value)), slog.Time("timestamp", pair.Timestamp.Time()))
-				ret = append(ret, OffsetSample{
+				hs.values = append(hs.values, OffsetSample{
 					Timestamp: pair.Timestamp.Time(),
 					Value:     int(pair.Value),
 				})
 			}
+			ret = append(ret, hs)
 		}
 	}
 
 	return ret, nil
 }
-
-func absTimeDiff(a, b time.Time) time.Duration {
-	if a.Before(b) {
-		return b.Sub(a)
-	}
-	return a.Sub(b)
-}
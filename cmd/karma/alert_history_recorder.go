@@ -0,0 +1,369 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/prometheus/common/model"
+
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+// HistoryEvent is recorded every time karma sees an alert that started
+// firing, which is the same thing that changes(ALERTS_FOR_STATE) counts when
+// querying Prometheus
+type HistoryEvent struct {
+	StartsAt time.Time         `json:"startsAt"`
+	ID       string            `json:"id"`
+	Labels   map[string]string `json:"labels"`
+	// set when karma noticed that the alert is no longer firing
+	ResolvedAt time.Time `json:"resolvedAt,omitzero"`
+	// set when karma noticed that the alert was silenced while firing
+	SilencedAt time.Time `json:"silencedAt,omitzero"`
+}
+
+// recordedAlert holds state of alerts that are currently firing, it's only
+// kept in memory
+type recordedAlert struct {
+	alertmanagers []string
+	// false if alert was already silenced when karma first saw it
+	wasUnsilenced bool
+}
+
+// historyRecorder keeps alert history based on alerts collected from
+// Alertmanager, it's used for alerts that can't be queried in Prometheus,
+// like alerts from Loki ruler or alerts pushed by other tools
+type historyRecorder struct {
+	path      string
+	retention time.Duration
+	events    []HistoryEvent
+	// latest recorded startsAt for each alert
+	seen map[string]time.Time
+	// index of the latest event for each alert
+	index     map[string]int
+	active    map[string]*recordedAlert
+	lifetimes map[string]models.AlertLifetime
+	enabled   bool
+	// true until alerts were collected from all Alertmanager instances after
+	// loading events from disk
+	loaded bool
+	lock   sync.RWMutex
+	// serializes file writes, those are done without holding lock
+	writeLock sync.Mutex
+}
+
+var alertHistoryRecorder = &historyRecorder{}
+
+func newHistoryRecorder(enabled bool, path string, retention time.Duration) (*historyRecorder, error) {
+	hr := historyRecorder{
+		path:      path,
+		retention: retention,
+		events:    []HistoryEvent{},
+		seen:      map[string]time.Time{},
+		index:     map[string]int{},
+		active:    map[string]*recordedAlert{},
+		lifetimes: map[string]models.AlertLifetime{},
+		enabled:   enabled,
+	}
+
+	if enabled && path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			slog.Info("Alert history file doesn't exist yet", slog.String("path", path))
+		case err != nil:
+			return nil, fmt.Errorf("failed to read alert history file %q: %w", path, err)
+		default:
+			err = jsonv2.Unmarshal(data, &hr.events)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse alert history file %q: %w", path, err)
+			}
+			slog.Info("Loaded alert history", slog.String("path", path), slog.Int("events", len(hr.events)))
+			hr.loaded = true
+		}
+	}
+	for _, ev := range hr.events {
+		if ev.StartsAt.After(hr.seen[ev.ID]) {
+			hr.seen[ev.ID] = ev.StartsAt
+		}
+	}
+	hr.reindex()
+
+	return &hr, nil
+}
+
+// record adds a new event for every alert that started firing since the last
+// call, updates resolved and silenced alerts and removes events older than the
+// retention period, events are written to disk only if anything changed
+func (hr *historyRecorder) record(groups []models.AlertGroup, unhealthy map[string]bool, now time.Time) {
+	if !hr.enabled {
+		return
+	}
+
+	hr.writeLock.Lock()
+	defer hr.writeLock.Unlock()
+
+	events, changed := hr.update(groups, unhealthy, now)
+	if changed && hr.path != "" {
+		if err := writeJSONFile(hr.path, events); err != nil {
+			slog.Error("Failed to write alert history file", slog.Any("error", err), slog.String("path", hr.path))
+		}
+	}
+}
+
+// update applies collected alerts to recorded events and returns a copy of
+// all events if anything was modified
+func (hr *historyRecorder) update(groups []models.AlertGroup, unhealthy map[string]bool, now time.Time) ([]HistoryEvent, bool) {
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	cutoff := now.Add(-hr.retention)
+	isExpired := func(ev HistoryEvent) bool { return ev.StartsAt.Before(cutoff) }
+
+	var changed bool
+	// names of Alertmanager instances each alert was seen on
+	present := map[string][]string{}
+	for _, ag := range groups {
+		for _, alert := range ag.Alerts {
+			if alert.StartsAt.Before(cutoff) {
+				continue
+			}
+			alertmanagers := present[alert.LabelsFP]
+			for _, am := range alert.Alertmanager {
+				alertmanagers = append(alertmanagers, am.Name)
+			}
+			present[alert.LabelsFP] = alertmanagers
+
+			ra, ok := hr.active[alert.LabelsFP]
+			if !ok {
+				ra = &recordedAlert{}
+				hr.active[alert.LabelsFP] = ra
+			}
+
+			if startsAt, ok := hr.seen[alert.LabelsFP]; !ok || alert.StartsAt.After(startsAt) {
+				// alert started firing again, so the previous event was resolved
+				if i, ok := hr.index[alert.LabelsFP]; ok && hr.events[i].ResolvedAt.IsZero() {
+					hr.events[i].ResolvedAt = alert.StartsAt
+				}
+				hr.seen[alert.LabelsFP] = alert.StartsAt
+				hr.events = append(hr.events, HistoryEvent{
+					StartsAt: alert.StartsAt,
+					ID:       alert.LabelsFP,
+					Labels:   alert.Labels.Map(),
+				})
+				hr.index[alert.LabelsFP] = len(hr.events) - 1
+				changed = true
+			}
+
+			if !isAlertSilenced(alert) {
+				ra.wasUnsilenced = true
+			} else if i := hr.index[alert.LabelsFP]; ra.wasUnsilenced && hr.events[i].SilencedAt.IsZero() {
+				hr.events[i].SilencedAt = now
+				changed = true
+			}
+		}
+	}
+
+	for id, alertmanagers := range present {
+		hr.active[id].alertmanagers = alertmanagers
+	}
+
+	for id, i := range hr.index {
+		if _, ok := present[id]; ok || !hr.events[i].ResolvedAt.IsZero() {
+			continue
+		}
+		// alert might be missing only because we failed to collect alerts
+		// from Alertmanager, don't mark it as resolved yet
+		// events loaded from disk have no active entry until the alert is seen
+		// again, we don't know which Alertmanager it came from so leave those
+		// alone too
+		ra, ok := hr.active[id]
+		if !ok || slices.ContainsFunc(ra.alertmanagers, func(name string) bool { return unhealthy[name] }) {
+			continue
+		}
+		hr.events[i].ResolvedAt = now
+		delete(hr.active, id)
+		changed = true
+	}
+
+	// once alerts were collected from all Alertmanager instances we know
+	// that any event loaded from disk for an alert that is still missing was
+	// resolved while karma wasn't running
+	if hr.loaded && !slices.Contains(slices.Collect(maps.Values(unhealthy)), true) {
+		for id, i := range hr.index {
+			if _, ok := present[id]; ok || !hr.events[i].ResolvedAt.IsZero() {
+				continue
+			}
+			hr.events[i].ResolvedAt = now
+			changed = true
+		}
+		hr.loaded = false
+	}
+
+	if slices.ContainsFunc(hr.events, isExpired) {
+		hr.events = slices.DeleteFunc(hr.events, isExpired)
+		for id, startsAt := range hr.seen {
+			if startsAt.Before(cutoff) {
+				delete(hr.seen, id)
+				delete(hr.active, id)
+			}
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil, false
+	}
+	hr.reindex()
+	return slices.Clone(hr.events), true
+}
+
+// reindex must be called after events are modified, it updates the index of
+// latest events and lifetime statistics
+func (hr *historyRecorder) reindex() {
+	hr.index = make(map[string]int, len(hr.seen))
+	for i, ev := range hr.events {
+		hr.index[ev.ID] = i
+	}
+	hr.lifetimes = alertLifetimes(hr.events)
+}
+
+func isAlertSilenced(alert models.Alert) bool {
+	return slices.ContainsFunc(alert.Alertmanager, func(am models.AlertmanagerInstance) bool {
+		return len(am.SilencedBy) > 0
+	})
+}
+
+// alertLifetimes calculates lifetime statistics for each alertname
+func alertLifetimes(events []HistoryEvent) map[string]models.AlertLifetime {
+	durations := map[string][]time.Duration{}
+	toSilence := map[string][]time.Duration{}
+	lifetimes := map[string]models.AlertLifetime{}
+	for _, ev := range events {
+		name := ev.Labels[model.AlertNameLabel]
+		if name == "" {
+			continue
+		}
+		al := lifetimes[name]
+		al.Alertname = name
+		al.Fired++
+		if !ev.ResolvedAt.IsZero() {
+			al.Resolved++
+			durations[name] = append(durations[name], ev.ResolvedAt.Sub(ev.StartsAt))
+		}
+		if !ev.SilencedAt.IsZero() {
+			al.Silenced++
+			toSilence[name] = append(toSilence[name], ev.SilencedAt.Sub(ev.StartsAt))
+		}
+		lifetimes[name] = al
+	}
+	for name, al := range lifetimes {
+		al.DurationMedian = int(percentile(durations[name], 0.5).Seconds())
+		al.DurationP90 = int(percentile(durations[name], 0.9).Seconds())
+		al.TimeToSilenceMedian = int(percentile(toSilence[name], 0.5).Seconds())
+		lifetimes[name] = al
+	}
+	return lifetimes
+}
+
+// percentile returns the nearest-rank percentile of given durations
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := slices.Sorted(slices.Values(durations))
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// lifetime returns lifetime statistics for given alertname
+func (hr *historyRecorder) lifetime(alertname string) (models.AlertLifetime, bool) {
+	hr.lock.RLock()
+	defer hr.lock.RUnlock()
+
+	al, ok := hr.lifetimes[alertname]
+	return al, ok
+}
+
+// count returns a sample for every recorded event of alerts with all given
+// labels, that happened in given time range
+func (hr *historyRecorder) count(labels map[string]string, r historyRange) []OffsetSample {
+	hr.lock.RLock()
+	defer hr.lock.RUnlock()
+
+	samples := []OffsetSample{}
+	for _, ev := range hr.events {
+		if _, ok := r.bucket(ev.StartsAt); !ok {
+			continue
+		}
+		if !isHistoryEventMatch(ev, labels) {
+			continue
+		}
+		samples = append(samples, OffsetSample{Timestamp: ev.StartsAt, Value: 1})
+	}
+	return samples
+}
+
+func isHistoryEventMatch(ev HistoryEvent, labels map[string]string) bool {
+	for k, v := range labels {
+		if ev.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// groupLifetime returns lifetime statistics for given alert group, if all
+// alerts in that group have the same alertname
+func (hr *historyRecorder) groupLifetime(ag models.AlertGroup) *models.AlertLifetime {
+	// alertname is removed from alert labels if alerts are grouped by it
+	alertname := ag.Labels.Get(model.AlertNameLabel)
+	if alertname == "" {
+		for i, alert := range ag.Alerts {
+			name := alert.Labels.Get(model.AlertNameLabel)
+			if i > 0 && name != alertname {
+				return nil
+			}
+			alertname = name
+		}
+	}
+	if al, ok := hr.lifetime(alertname); ok {
+		return &al
+	}
+	return nil
+}
+
+func alertHistoryLifetime(w http.ResponseWriter, _ *http.Request) {
+	noCache(w)
+
+	if !config.Config.History.Enabled || !alertHistoryRecorder.enabled {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	alertHistoryRecorder.lock.RLock()
+	resp := make([]models.AlertLifetime, 0, len(alertHistoryRecorder.lifetimes))
+	for _, al := range alertHistoryRecorder.lifetimes {
+		resp = append(resp, al)
+	}
+	alertHistoryRecorder.lock.RUnlock()
+	slices.SortFunc(resp, func(a, b models.AlertLifetime) int {
+		return cmp.Compare(a.Alertname, b.Alertname)
+	})
+
+	data, _ := marshalJSON(resp)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+func newRecordedAlert(startsAt time.Time, ls ...string) models.Alert {
+	alert := models.Alert{
+		StartsAt: startsAt,
+		Labels:   promlabels.FromStrings(ls...),
+		State:    models.AlertStateActive,
+	}
+	alert.UpdateFingerprints()
+	return alert
+}
+
+func TestHistoryRecorder(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 13, 5, 0, time.UTC)
+	historyPath := path.Join(t.TempDir(), "history.json")
+
+	hr, err := newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := []models.AlertGroup{
+		{
+			Alerts: models.AlertList{
+				newRecordedAlert(now.Add(-time.Minute*30), "alertname", "Foo", "instance", "1"),
+				newRecordedAlert(now.Add(-time.Hour*3), "alertname", "Foo", "instance", "2"),
+				// too old, won't be recorded
+				newRecordedAlert(now.Add(-time.Hour*25), "alertname", "Foo", "instance", "3"),
+			},
+		},
+		{
+			Alerts: models.AlertList{
+				// same alert as in the first group, but with a different receiver
+				newRecordedAlert(now.Add(-time.Minute*30), "alertname", "Foo", "instance", "1"),
+				newRecordedAlert(now.Add(-time.Minute*5), "alertname", "Bar", "instance", "1"),
+			},
+		},
+	}
+	hr.record(groups, nil, now)
+	hr.record(groups, nil, now)
+	if len(hr.events) != 3 {
+		t.Fatalf("Expected 3 recorded events, got %d: %v", len(hr.events), hr.events)
+	}
+
+	// alert resolved and started firing again
+	groups[0].Alerts[0] = newRecordedAlert(now.Add(time.Minute), "alertname", "Foo", "instance", "1")
+	hr.record(groups, nil, now.Add(time.Minute))
+	if len(hr.events) != 4 {
+		t.Fatalf("Expected 4 recorded events, got %d: %v", len(hr.events), hr.events)
+	}
+
+	hr, err = newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hr.events) != 4 {
+		t.Fatalf("Expected 4 events loaded from %s, got %d", historyPath, len(hr.events))
+	}
+	hr.record(groups, nil, now.Add(time.Minute))
+	if len(hr.events) != 4 {
+		t.Fatalf("Expected 4 recorded events after reload, got %d: %v", len(hr.events), hr.events)
+	}
+
+	type testCaseT struct {
+		name    string
+		labels  map[string]string
+		payload AlertHistoryPayload
+		values  []int
+	}
+
+	testCases := []testCaseT{
+		{
+			name:   "alertname=Foo",
+			labels: map[string]string{"alertname": "Foo"},
+			values: append([]int{1, 1, 0, 1}, make([]int, 20)...),
+		},
+		{
+			name:   "instance=1",
+			labels: map[string]string{"instance": "1"},
+			values: append([]int{2, 1}, make([]int, 22)...),
+		},
+		{
+			name:   "alertname=Bar,instance=2",
+			labels: map[string]string{"alertname": "Bar", "instance": "2"},
+			values: make([]int, 24),
+		},
+		{
+			name:    "alertname=Foo in 1d steps",
+			labels:  map[string]string{"alertname": "Foo"},
+			payload: AlertHistoryPayload{Range: "2d", Step: "1d"},
+			values:  []int{3, 0},
+		},
+	}
+
+	mockConfig(t.Setenv)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newHistoryRange(tc.payload, now.Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			values := make([]int, r.samples)
+			for _, s := range hr.count(tc.labels, r) {
+				i, _ := r.bucket(s.Timestamp)
+				values[i] += s.Value
+			}
+			if diff := cmp.Diff(tc.values, values); diff != "" {
+				t.Errorf("Incorrect counts (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// all events are expired
+	hr.record(nil, nil, now.Add(time.Hour*48))
+	if len(hr.events) != 0 || len(hr.seen) != 0 {
+		t.Errorf("Expected all events to be expired, got %v", hr.events)
+	}
+}
+
+func TestHistoryRecorderInvalidFile(t *testing.T) {
+	historyPath := path.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(historyPath, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := newHistoryRecorder(true, historyPath, time.Hour)
+	if err == nil {
+		t.Errorf("newHistoryRecorder() didn't return any error")
+	}
+
+	// file is not read if recorder is disabled
+	if _, err = newHistoryRecorder(false, historyPath, time.Hour); err != nil {
+		t.Errorf("newHistoryRecorder() returned an error: %s", err)
+	}
+}
+
+func TestAlertHistoryRecorderFallback(t *testing.T) {
+	mockConfig(t.Setenv)
+	defer func() {
+		alertHistoryRecorder = &historyRecorder{}
+		config.Config.History.Rewrite = []config.HistoryRewrite{}
+	}()
+
+	now := time.Now()
+	hr, err := newHistoryRecorder(true, "", time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr.record([]models.AlertGroup{
+		{Alerts: models.AlertList{newRecordedAlert(now.Add(-time.Minute), "alertname", "Foo")}},
+	}, nil, now)
+	alertHistoryRecorder = hr
+
+	hp := newHistoryPoller(1, time.Second)
+	r := testRouter()
+	setupRouter(r, hp)
+	go hp.run(1)
+	defer hp.stop()
+	for !hp.isRunning.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, sources := range [][]string{nil, {"%gh&%ij"}} {
+		payload, _ := json.Marshal(AlertHistoryPayload{
+			Sources: sources,
+			Labels:  map[string]string{"alertname": "Foo"},
+		})
+		req := httptest.NewRequest("POST", "/history.json", bytes.NewReader(payload))
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != 200 {
+			t.Fatalf("POST /history.json returned status %d", resp.Code)
+		}
+		var ahr AlertHistoryResponse
+		if err = json.Unmarshal(resp.Body.Bytes(), &ahr); err != nil {
+			t.Fatal(err)
+		}
+		if ahr.Error != "" {
+			t.Errorf("Unexpected error in response: %s", ahr.Error)
+		}
+		if len(ahr.Samples) != 24 || ahr.Samples[0].Value+ahr.Samples[1].Value != 1 {
+			t.Errorf("Recorded history not used for sources=%v: %v", sources, ahr.Samples)
+		}
+	}
+}
+
+func newLifetimeAlert(startsAt time.Time, alertmanager string, isSilenced bool, ls ...string) models.Alert {
+	alert := newRecordedAlert(startsAt, ls...)
+	am := models.AlertmanagerInstance{Name: alertmanager}
+	if isSilenced {
+		am.SilencedBy = []string{"silence"}
+	}
+	alert.Alertmanager = []models.AlertmanagerInstance{am}
+	return alert
+}
+
+func TestHistoryRecorderLifetime(t *testing.T) {
+	start := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	historyPath := path.Join(t.TempDir(), "history.json")
+
+	hr, err := newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo1 := func(startsAt time.Time) models.Alert {
+		return newLifetimeAlert(startsAt, "am1", false, "alertname", "Foo", "instance", "1")
+	}
+	// already silenced when it started firing, won't count as silenced
+	foo2 := newLifetimeAlert(start, "am2", true, "alertname", "Foo", "instance", "2")
+	bar := func(isSilenced bool) models.Alert {
+		return newLifetimeAlert(start, "am1", isSilenced, "alertname", "Bar")
+	}
+	record := func(offset time.Duration, unhealthy map[string]bool, alerts ...models.Alert) {
+		hr.record([]models.AlertGroup{{Alerts: alerts}}, unhealthy, start.Add(offset))
+	}
+
+	record(0, nil, foo1(start), foo2, bar(false))
+	// foo1 is resolved, bar was silenced
+	record(time.Minute*5, nil, foo2, bar(true))
+	// foo2 is missing because am2 failed, bar is resolved, foo1 fires again
+	record(time.Minute*10, map[string]bool{"am2": true}, foo1(start.Add(time.Minute*9)))
+	// foo2 is resolved
+	record(time.Minute*20, nil, foo1(start.Add(time.Minute*9)))
+	// foo1 was resolved and fired again between collections
+	record(time.Minute*30, nil, foo1(start.Add(time.Minute*25)))
+
+	expected := map[string]models.AlertLifetime{
+		"Foo": {
+			Alertname:      "Foo",
+			Fired:          4,
+			Resolved:       3,
+			DurationMedian: 960,
+			DurationP90:    1200,
+		},
+		"Bar": {
+			Alertname:           "Bar",
+			Fired:               1,
+			Resolved:            1,
+			Silenced:            1,
+			DurationMedian:      600,
+			DurationP90:         600,
+			TimeToSilenceMedian: 300,
+		},
+	}
+	if diff := cmp.Diff(expected, hr.lifetimes); diff != "" {
+		t.Errorf("Incorrect lifetimes (-want +got):\n%s", diff)
+	}
+
+	hr, err = newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expected, hr.lifetimes); diff != "" {
+		t.Errorf("Incorrect lifetimes after reload (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		alerts   models.AlertList
+		lifetime *models.AlertLifetime
+	}{
+		{alerts: models.AlertList{foo1(start), foo2}, lifetime: &models.AlertLifetime{
+			Alertname: "Foo", Fired: 4, Resolved: 3, DurationMedian: 960, DurationP90: 1200,
+		}},
+		{alerts: models.AlertList{foo1(start), bar(false)}},
+		{alerts: models.AlertList{newRecordedAlert(start, "alertname", "Baz")}},
+	} {
+		if diff := cmp.Diff(tc.lifetime, hr.groupLifetime(models.AlertGroup{Alerts: tc.alerts})); diff != "" {
+			t.Errorf("Incorrect groupLifetime result (-want +got):\n%s", diff)
+		}
+	}
+
+	mockConfig(t.Setenv)
+	defer func() {
+		alertHistoryRecorder = &historyRecorder{}
+	}()
+	r := testRouter()
+	setupRouter(r, nil)
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest("GET", "/history/lifetime.json", nil))
+	if resp.Code != 400 {
+		t.Errorf("GET /history/lifetime.json returned status %d with recorder disabled", resp.Code)
+	}
+
+	alertHistoryRecorder = hr
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest("GET", "/history/lifetime.json", nil))
+	if resp.Code != 200 {
+		t.Fatalf("GET /history/lifetime.json returned status %d", resp.Code)
+	}
+	var lifetimes []models.AlertLifetime
+	if err = json.Unmarshal(resp.Body.Bytes(), &lifetimes); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]models.AlertLifetime{expected["Bar"], expected["Foo"]}, lifetimes); diff != "" {
+		t.Errorf("Incorrect response (-want +got):\n%s", diff)
+	}
+}
+
+func TestHistoryRecorderRestart(t *testing.T) {
+	start := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	historyPath := path.Join(t.TempDir(), "history.json")
+
+	hr, err := newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo := newLifetimeAlert(start, "am1", false, "alertname", "Foo")
+	hr.record([]models.AlertGroup{{Alerts: models.AlertList{foo}}}, nil, start)
+
+	hr, err = newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// am1 is unreachable on the first collection after restart, but we don't
+	// know yet which Alertmanager the alert came from
+	hr.record(nil, map[string]bool{"am1": true}, start.Add(time.Minute*5))
+	if !hr.events[0].ResolvedAt.IsZero() {
+		t.Errorf("Event was resolved before the alert was seen after restart: %v", hr.events[0])
+	}
+
+	hr.record([]models.AlertGroup{{Alerts: models.AlertList{foo}}}, nil, start.Add(time.Minute*10))
+	hr.record(nil, nil, start.Add(time.Minute*15))
+	if !hr.events[0].ResolvedAt.Equal(start.Add(time.Minute * 15)) {
+		t.Errorf("Event wasn't resolved after the alert was gone: %v", hr.events[0])
+	}
+}
+
+func TestHistoryRecorderResolvesLoadedEvents(t *testing.T) {
+	start := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	historyPath := path.Join(t.TempDir(), "history.json")
+
+	hr, err := newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo := newLifetimeAlert(start, "am1", false, "alertname", "Foo")
+	hr.record([]models.AlertGroup{{Alerts: models.AlertList{foo}}}, nil, start)
+
+	hr, err = newHistoryRecorder(true, historyPath, time.Hour*24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr.record(nil, map[string]bool{"am1": true}, start.Add(time.Minute*5))
+	if !hr.events[0].ResolvedAt.IsZero() {
+		t.Errorf("Event was resolved while an Alertmanager was unhealthy: %v", hr.events[0])
+	}
+
+	// alert resolved while karma wasn't running
+	hr.record(nil, map[string]bool{"am1": false}, start.Add(time.Minute*10))
+	if !hr.events[0].ResolvedAt.Equal(start.Add(time.Minute * 10)) {
+		t.Errorf("Loaded event wasn't resolved after all Alertmanagers were collected: %v", hr.events[0])
+	}
+
+	// nothing changed so the file isn't written again
+	if err = os.Remove(historyPath); err != nil {
+		t.Fatal(err)
+	}
+	hr.record(nil, nil, start.Add(time.Minute*15))
+	if _, err = os.Stat(historyPath); !os.IsNotExist(err) {
+		t.Errorf("History file was written without any changes: %v", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	for _, tc := range []struct {
+		durations []time.Duration
+		p         float64
+		out       time.Duration
+	}{
+		{p: 0.5},
+		{durations: []time.Duration{time.Second}, p: 0.9, out: time.Second},
+		{durations: []time.Duration{time.Second * 3, time.Second, time.Second * 2}, p: 0.5, out: time.Second * 2},
+		{durations: []time.Duration{time.Second * 4, time.Second, time.Second * 2, time.Second * 3}, p: 0.5, out: time.Second * 2},
+		{durations: []time.Duration{time.Second * 4, time.Second, time.Second * 2, time.Second * 3}, p: 0.9, out: time.Second * 4},
+	} {
+		if out := percentile(tc.durations, tc.p); out != tc.out {
+			t.Errorf("percentile(%v, %f) returned %s, expected %s", tc.durations, tc.p, out, tc.out)
+		}
+	}
+}
+
+func TestAlertsLifetime(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	defer func() {
+		alertHistoryRecorder = &historyRecorder{}
+	}()
+
+	hr, err := newHistoryRecorder(true, "", time.Hour*24*365*100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr.record(alertmanager.DedupAlerts(), nil, time.Now())
+	alertHistoryRecorder = hr
+
+	payload, _ := json.Marshal(models.AlertsRequest{
+		GridLimits:        map[string]int{},
+		DefaultGroupLimit: 5,
+	})
+	r := testRouter()
+	setupRouter(r, nil)
+	req := httptest.NewRequest("POST", "/alerts.json", bytes.NewReader(payload))
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("POST /alerts.json returned status %d", resp.Code)
+	}
+
+	var ur models.AlertsResponse
+	if err = json.Unmarshal(resp.Body.Bytes(), &ur); err != nil {
+		t.Fatal(err)
+	}
+	var groups int
+	for _, grid := range ur.Grids {
+		for _, ag := range grid.AlertGroups {
+			groups++
+			if ag.Lifetime == nil {
+				t.Errorf("Group %s is missing lifetime", ag.ID)
+				continue
+			}
+			if ag.Lifetime.Fired < len(ag.Alerts) {
+				t.Errorf("Group %s lifetime fired=%d, expected at least %d", ag.ID, ag.Lifetime.Fired, len(ag.Alerts))
+			}
+		}
+	}
+	if groups == 0 {
+		t.Error("No alert groups returned")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"strconv"
@@ -12,7 +13,9 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/jarcoal/httpmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 
 	"github.com/prymitive/karma/internal/config"
@@ -163,8 +166,9 @@ func TestAlertHistory(t *testing.T) {
 			},
 			queries: []historyQuery{
 				{
-					payload: []byte("foo"),
-					code:    400,
+					payload:  []byte("foo"),
+					code:     400,
+					response: AlertHistoryResponse{Error: "jsontext: invalid character 'o' in literal false (expecting 'a') after offset 1"},
 				},
 			},
 		},
@@ -594,6 +598,63 @@ func TestAlertHistory(t *testing.T) {
 				},
 			},
 		},
+		{
+			mocks: []mock{
+				{
+					method: "POST",
+					uri:    regexp.MustCompile("^http://localhost:9102/api/v1/labels"),
+					responder: httpmock.NewJsonResponderOrPanic(200, prometheusAPIV1Labels{
+						Status: "success",
+						Data:   []string{"alertname", "instance", "job"},
+					}),
+				},
+				{
+					method: "POST",
+					uri:    regexp.MustCompile("^http://localhost:9102/api/v1/query_range"),
+					responder: httpmock.NewJsonResponderOrPanic(200, prometheusAPIV1QueryRange{
+						Status: "success",
+						Data: generateV1Matrix(
+							[]seriesValues{
+								{
+									metric: model.Metric{
+										"alertname": "Fake Alert",
+									},
+									values: generateIntSlice(1, 1, 7),
+								},
+							}, time.Hour*24,
+						),
+					}),
+				},
+			},
+			config: cfg{
+				enabled: true,
+				timeout: time.Second * 5,
+				workers: 5,
+			},
+			queries: []historyQuery{
+				{
+					payload: generateHistoryPayload(AlertHistoryPayload{
+						Sources: []string{"http://localhost:9102"},
+						Labels:  map[string]string{"alertname": "Fake Alert"},
+						Range:   "7d",
+						Step:    "1d",
+					}),
+					code: 200,
+					response: AlertHistoryResponse{
+						Samples: generateHistorySamples(generateIntSlice(1, 1, 7), time.Hour*24),
+					},
+				},
+				{
+					payload: generateHistoryPayload(AlertHistoryPayload{
+						Sources: []string{"http://localhost:9102"},
+						Labels:  map[string]string{"alertname": "Fake Alert"},
+						Range:   "60d",
+					}),
+					code:     400,
+					response: AlertHistoryResponse{Error: "range must be <= 30d"},
+				},
+			},
+		},
 	}
 
 	defer func() {
@@ -610,6 +671,9 @@ func TestAlertHistory(t *testing.T) {
 	setupRouter(r, hp)
 	go hp.run(5)
 	defer hp.stop()
+	for !hp.isRunning.Load() {
+		time.Sleep(time.Millisecond)
+	}
 
 	opt := cmp.Comparer(func(_, _ time.Time) bool {
 		return true
@@ -641,7 +705,8 @@ func TestAlertHistory(t *testing.T) {
 						break
 					}
 
-					if resp.Code != 200 {
+					// errors are only checked if the test case expects one
+					if resp.Code != 200 && q.response.Error == "" {
 						continue
 					}
 					r := AlertHistoryResponse{}
@@ -660,36 +725,421 @@ func TestAlertHistory(t *testing.T) {
 	}
 }
 
-func TestAbsTime(t *testing.T) {
+// newFakePrometheus starts a HTTP server implementing enough of the Prometheus
+// API to answer history queries, every series will have one sample per step,
+// starting from the end of the queried range
+func newFakePrometheus(t *testing.T, series []seriesValues) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/labels":
+			_ = json.NewEncoder(w).Encode(prometheusAPIV1Labels{
+				Status: "success",
+				Data:   []string{"alertname", "instance", "replica"},
+			})
+		case "/api/v1/query_range":
+			end, _ := strconv.ParseFloat(r.FormValue("end"), 64)
+			step, _ := strconv.ParseFloat(r.FormValue("step"), 64)
+			matrix := model.Matrix{}
+			for _, sv := range series {
+				ss := model.SampleStream{Metric: sv.metric}
+				for i, val := range sv.values {
+					ss.Values = append(ss.Values, model.SamplePair{
+						Timestamp: model.TimeFromUnix(int64(end - step*float64(i))),
+						Value:     model.SampleValue(val),
+					})
+				}
+				matrix = append(matrix, &ss)
+			}
+			_ = json.NewEncoder(w).Encode(prometheusAPIV1QueryRange{
+				Status: "success",
+				Data:   queryResult{ResultType: "matrix", Result: matrix},
+			})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAlertHistoryGroups(t *testing.T) {
+	// HA pair, alert state is evaluated independently so values can differ
+	promA := newFakePrometheus(t, []seriesValues{
+		{metric: model.Metric{"alertname": "Foo", "instance": "1"}, values: []int{1, 2}},
+	})
+	promB := newFakePrometheus(t, []seriesValues{
+		{metric: model.Metric{"alertname": "Foo", "instance": "1"}, values: []int{1, 3}},
+		{metric: model.Metric{"alertname": "Foo", "instance": "2"}, values: []int{1, 0}},
+	})
+	// Thanos Query with deduplication disabled returns series from both replicas
+	thanos := newFakePrometheus(t, []seriesValues{
+		{metric: model.Metric{"alertname": "Foo", "instance": "1", "replica": "a"}, values: []int{2, 1}},
+		{metric: model.Metric{"alertname": "Foo", "instance": "1", "replica": "b"}, values: []int{2, 1}},
+		{metric: model.Metric{"alertname": "Foo", "instance": "2", "replica": "a"}, values: []int{1, 1}},
+	})
+	other := newFakePrometheus(t, []seriesValues{
+		{metric: model.Metric{"alertname": "Foo", "instance": "3"}, values: []int{1, 1}},
+	})
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	haGroup := config.HistoryGroup{
+		Name: "ha",
+		SourceRegexes: []*regexp.Regexp{
+			regex.MustCompileAnchored(regexp.QuoteMeta(promA.URL)),
+			regex.MustCompileAnchored(regexp.QuoteMeta(promB.URL)),
+			regex.MustCompileAnchored(regexp.QuoteMeta(broken.URL)),
+		},
+	}
+	thanosGroup := config.HistoryGroup{
+		Name:          "thanos",
+		URI:           thanos.URL,
+		ReplicaLabels: []string{"replica"},
+	}
+
 	type testCaseT struct {
-		a    time.Time
-		b    time.Time
-		diff time.Duration
+		name    string
+		groups  []config.HistoryGroup
+		sources []string
+		values  []int
+		err     bool
 	}
 
 	testCases := []testCaseT{
 		{
-			a:    time.Date(2021, 6, 7, 12, 13, 0, 0, time.UTC),
-			b:    time.Date(2021, 6, 7, 12, 13, 5, 0, time.UTC),
-			diff: time.Second * 5,
+			name:    "no groups",
+			sources: []string{promA.URL, promB.URL},
+			values:  []int{3, 5},
+		},
+		{
+			name:    "HA pair is deduplicated",
+			groups:  []config.HistoryGroup{haGroup},
+			sources: []string{promA.URL, promB.URL + "/", other.URL},
+			values:  []int{3, 4},
+		},
+		{
+			name:    "one replica is enough",
+			groups:  []config.HistoryGroup{haGroup},
+			sources: []string{promA.URL, broken.URL},
+			values:  []int{1, 2},
+		},
+		{
+			name:    "all replicas failed",
+			groups:  []config.HistoryGroup{haGroup},
+			sources: []string{broken.URL},
+			values:  []int{0, 0},
+			err:     true,
+		},
+		{
+			name:    "central endpoint is used for unmatched sources",
+			groups:  []config.HistoryGroup{haGroup, thanosGroup},
+			sources: []string{other.URL},
+			values:  []int{3, 2},
+		},
+		{
+			name:   "central endpoint is used for alerts without sources",
+			groups: []config.HistoryGroup{haGroup, thanosGroup},
+			values: []int{3, 2},
 		},
 		{
-			a:    time.Date(2021, 6, 7, 12, 13, 10, 0, time.UTC),
-			b:    time.Date(2021, 6, 7, 12, 13, 5, 0, time.UTC),
-			diff: time.Second * 5,
+			name:    "central endpoint is skipped if all sources are matched",
+			groups:  []config.HistoryGroup{haGroup, thanosGroup},
+			sources: []string{promA.URL, promB.URL},
+			values:  []int{2, 3},
 		},
 	}
 
+	mockConfig(t.Setenv)
+	defer func() {
+		config.Config.History.Groups = []config.HistoryGroup{}
+	}()
+
+	hp := newHistoryPoller(1, time.Second*5)
+	r := testRouter()
+	setupRouter(r, hp)
+	go hp.run(1)
+	defer hp.stop()
+	for !hp.isRunning.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Config.History.Groups = tc.groups
+
+			payload := generateHistoryPayload(AlertHistoryPayload{
+				Sources: tc.sources,
+				Labels:  map[string]string{"alertname": "Foo"},
+			})
+			req := httptest.NewRequest("POST", "/history.json", bytes.NewReader(payload))
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != 200 {
+				t.Fatalf("POST /history.json returned status %d", resp.Code)
+			}
+			var ahr AlertHistoryResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &ahr); err != nil {
+				t.Fatal(err)
+			}
+			if (ahr.Error != "") != tc.err {
+				t.Errorf("Unexpected error in response: %q", ahr.Error)
+			}
+			values := []int{ahr.Samples[0].Value, ahr.Samples[1].Value}
+			if diff := cmp.Diff(tc.values, values); diff != "" {
+				t.Errorf("Incorrect samples (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAlertHistoryMetrics(t *testing.T) {
+	prom := newFakePrometheus(t, []seriesValues{
+		{metric: model.Metric{"alertname": "Metrics"}, values: []int{1}},
+	})
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	mockConfig(t.Setenv)
+
+	hp := newHistoryPoller(1, time.Second*5)
+	r := testRouter()
+	setupRouter(r, hp)
+	go hp.run(1)
+	defer hp.stop()
+	for !hp.isRunning.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	lookups := func() map[string]float64 {
+		return map[string]float64{
+			"hit":       testutil.ToFloat64(historyCacheLookups.WithLabelValues("hit")),
+			"miss":      testutil.ToFloat64(historyCacheLookups.WithLabelValues("miss")),
+			"known_bad": testutil.ToFloat64(historyCacheLookups.WithLabelValues("known_bad")),
+		}
+	}
+	before := lookups()
+	errorsBefore := testutil.ToFloat64(historyQueryErrors.WithLabelValues(broken.URL))
+
+	payload := generateHistoryPayload(AlertHistoryPayload{
+		Sources: []string{prom.URL, broken.URL},
+		Labels:  map[string]string{"alertname": "Metrics"},
+	})
+	for range 2 {
+		req := httptest.NewRequest("POST", "/history.json", bytes.NewReader(payload))
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != 200 {
+			t.Fatalf("POST /history.json returned status %d", resp.Code)
+		}
+	}
+
+	after := lookups()
+	for result, delta := range map[string]float64{"hit": 1, "miss": 2, "known_bad": 1} {
+		if after[result]-before[result] != delta {
+			t.Errorf("karma_history_cache_lookups_total{result=%q} increased by %f, expected %f", result, after[result]-before[result], delta)
+		}
+	}
+	if v := testutil.ToFloat64(historyQueryErrors.WithLabelValues(broken.URL)) - errorsBefore; v != 1 {
+		t.Errorf("karma_history_query_errors_total increased by %f, expected 1", v)
+	}
+	if v := testutil.ToFloat64(historyKnownBad); v != 1 {
+		t.Errorf("karma_history_known_bad_count is %f, expected 1", v)
+	}
+	if v := testutil.ToFloat64(historyWorkers); v != 1 {
+		t.Errorf("karma_history_workers is %f, expected 1", v)
+	}
+	if v := testutil.ToFloat64(historyWorkersBusy); v != 0 {
+		t.Errorf("karma_history_workers_busy is %f, expected 0", v)
+	}
+	if v := testutil.ToFloat64(historyQueueLength); v != 0 {
+		t.Errorf("karma_history_queue_length is %f, expected 0", v)
+	}
+
+	req := httptest.NewRequest("GET", "/history/knownBad.json", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("GET /history/knownBad.json returned status %d", resp.Code)
+	}
+	var kbs []HistoryKnownBad
+	if err := json.Unmarshal(resp.Body.Bytes(), &kbs); err != nil {
+		t.Fatal(err)
+	}
+	if len(kbs) != 1 {
+		t.Fatalf("Expected a single known bad entry, got %v", kbs)
+	}
+	expected := HistoryKnownBad{
+		Labels: map[string]string{"alertname": "Metrics"},
+		URI:    broken.URL,
+		Error:  "failed to query Prometheus for label names: server_error: server error: 500",
+	}
+	if diff := cmp.Diff(expected, kbs[0], cmpopts.IgnoreFields(HistoryKnownBad{}, "Timestamp", "ExpiresAt")); diff != "" {
+		t.Errorf("Incorrect known bad entry (-want +got):\n%s", diff)
+	}
+	if kbs[0].ExpiresAt.Sub(kbs[0].Timestamp) != historyCacheTTL {
+		t.Errorf("Incorrect expiresAt %s for timestamp %s", kbs[0].ExpiresAt, kbs[0].Timestamp)
+	}
+
+	// expired entries are not listed
+	if kbs := hp.knownBadList(time.Now().Add(historyCacheTTL)); len(kbs) != 0 {
+		t.Errorf("Expected no known bad entries after %s, got %v", historyCacheTTL, kbs)
+	}
+
+	// and are not counted once they expire
+	for _, kb := range hp.knownBad.Values() {
+		kb.timestamp = kb.timestamp.Add(-historyCacheTTL)
+	}
+	if v := testutil.ToFloat64(historyKnownBad); v != 0 {
+		t.Errorf("karma_history_known_bad_count is %f after all entries expired, expected 0", v)
+	}
+}
+
+func TestHistoryRange(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 13, 5, 0, time.UTC)
+
+	type testCaseT struct {
+		payload AlertHistoryPayload
+		err     string
+		end     time.Time
+		step    time.Duration
+		samples int
+	}
+
+	testCases := []testCaseT{
+		{
+			end:     time.Date(2021, 6, 7, 13, 0, 0, 0, time.UTC),
+			step:    time.Hour,
+			samples: 24,
+		},
+		{
+			payload: AlertHistoryPayload{Range: "6h", Step: "15m"},
+			end:     time.Date(2021, 6, 7, 12, 15, 0, 0, time.UTC),
+			step:    time.Minute * 15,
+			samples: 24,
+		},
+		{
+			payload: AlertHistoryPayload{Range: "7d", Step: "1d"},
+			end:     time.Date(2021, 6, 8, 0, 0, 0, 0, time.UTC),
+			step:    time.Hour * 24,
+			samples: 7,
+		},
+		{
+			payload: AlertHistoryPayload{Range: "30d", Step: "6h"},
+			end:     time.Date(2021, 6, 7, 18, 0, 0, 0, time.UTC),
+			step:    time.Hour * 6,
+			samples: 120,
+		},
+		{
+			payload: AlertHistoryPayload{Range: "foo"},
+			err:     `invalid range: not a valid duration string: "foo"`,
+		},
+		{
+			payload: AlertHistoryPayload{Step: "foo"},
+			err:     `invalid step: not a valid duration string: "foo"`,
+		},
+		{
+			payload: AlertHistoryPayload{Range: "0s"},
+			err:     "range must be > 0",
+		},
+		{
+			payload: AlertHistoryPayload{Range: "31d", Step: "1d"},
+			err:     "range must be <= 30d",
+		},
+		{
+			payload: AlertHistoryPayload{Range: "1h", Step: "1m"},
+			err:     "step must be >= 5m",
+		},
+		{
+			payload: AlertHistoryPayload{Range: "1h", Step: "2h"},
+			err:     "step must be <= range",
+		},
+		{
+			payload: AlertHistoryPayload{Range: "1d", Step: "7h"},
+			err:     "range must be a multiple of step",
+		},
+		{
+			payload: AlertHistoryPayload{Range: "30d", Step: "5m"},
+			err:     "range and step would return 8640 samples, maximum is 1000",
+		},
+	}
+
+	mockConfig(t.Setenv)
 	for i, tc := range testCases {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			d := absTimeDiff(tc.a, tc.b)
-			if diff := cmp.Diff(tc.diff, d); diff != "" {
-				t.Errorf("Incorrect absTimeDiff result (-want +got):\n%s", diff)
+			hr, err := newHistoryRange(tc.payload, now)
+			if err != nil {
+				if diff := cmp.Diff(tc.err, err.Error()); diff != "" {
+					t.Errorf("Incorrect newHistoryRange error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if tc.err != "" {
+				t.Fatalf("newHistoryRange didn't return any error, expected %q", tc.err)
+			}
+			if !hr.end.Equal(tc.end) || hr.step != tc.step || hr.samples != tc.samples {
+				t.Errorf("Incorrect newHistoryRange result: end=%s step=%s samples=%d", hr.end, hr.step, hr.samples)
+			}
+		})
+	}
+}
+
+func TestHistoryRangeBucket(t *testing.T) {
+	hr := historyRange{
+		end:     time.Date(2021, 6, 7, 13, 0, 0, 0, time.UTC),
+		step:    time.Hour,
+		samples: 24,
+	}
+
+	type testCaseT struct {
+		ts     time.Time
+		bucket int
+		ok     bool
+	}
+
+	testCases := []testCaseT{
+		{ts: time.Date(2021, 6, 7, 13, 0, 1, 0, time.UTC)},
+		{ts: time.Date(2021, 6, 7, 13, 0, 0, 0, time.UTC), bucket: 0, ok: true},
+		{ts: time.Date(2021, 6, 7, 12, 13, 5, 0, time.UTC), bucket: 0, ok: true},
+		{ts: time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC), bucket: 1, ok: true},
+		{ts: time.Date(2021, 6, 6, 14, 0, 0, 0, time.UTC), bucket: 23, ok: true},
+		{ts: time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC), bucket: 24},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			bucket, ok := hr.bucket(tc.ts)
+			if ok != tc.ok || (ok && bucket != tc.bucket) {
+				t.Errorf("Incorrect bucket for %s: got %d/%v, expected %d/%v", tc.ts, bucket, ok, tc.bucket, tc.ok)
 			}
 		})
 	}
 }
 
+func TestHashQuery(t *testing.T) {
+	hr := historyRange{
+		end:     time.Date(2021, 6, 7, 13, 0, 0, 0, time.UTC),
+		step:    time.Minute,
+		samples: 60,
+	}
+	key := hashQuery("http://localhost", map[string]string{"alertname": "Foo"}, hr, nil)
+
+	// cached series are stamped with the end of the range, so queries with
+	// a different end can't reuse them
+	moved := hr
+	moved.end = hr.end.Add(time.Minute)
+	if hashQuery("http://localhost", map[string]string{"alertname": "Foo"}, moved, nil) == key {
+		t.Errorf("hashQuery() returned the same key for a different range end")
+	}
+	if hashQuery("http://localhost", map[string]string{"alertname": "Foo"}, hr, nil) != key {
+		t.Errorf("hashQuery() returned a different key for the same query")
+	}
+}
+
 func TestRewriteSource(t *testing.T) {
 	type testCaseT struct {
 		uri   string
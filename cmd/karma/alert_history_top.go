@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+const (
+	defaultHistoryTopLabel = "alertname"
+	defaultHistoryTopLimit = 10
+)
+
+// AlertHistoryTopEntry is the number of times alerts with given label value
+// started firing
+type AlertHistoryTopEntry struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// historySourceTracker remembers all alert sources seen recently, so that
+// history of alerts that are no longer firing can still be queried
+type historySourceTracker struct {
+	lock     sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+var historySources = &historySourceTracker{lastSeen: map[string]time.Time{}}
+
+func (st *historySourceTracker) record(groups []models.AlertGroup, now time.Time) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	for _, ag := range groups {
+		for _, source := range ag.Sources() {
+			st.lastSeen[source] = now
+		}
+	}
+	for source, ts := range st.lastSeen {
+		if now.Sub(ts) > config.Config.History.MaxRange {
+			delete(st.lastSeen, source)
+		}
+	}
+}
+
+// list returns sorted sources seen within the max history range
+func (st *historySourceTracker) list(now time.Time) []string {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	sources := make([]string, 0, len(st.lastSeen))
+	for source, ts := range st.lastSeen {
+		if now.Sub(ts) <= config.Config.History.MaxRange {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+type AlertHistoryTopResponse struct {
+	Error string                 `json:"error"`
+	Label string                 `json:"label"`
+	Range string                 `json:"range"`
+	Top   []AlertHistoryTopEntry `json:"top"`
+}
+
+// alertHistoryTop returns label values with the highest number of alert
+// firing transitions over given range, it queries all Prometheus servers
+// that generated any alert within the max history range
+func alertHistoryTop(historyPoller *historyPoller, w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	if !config.Config.History.Enabled {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = defaultHistoryTopLabel
+	}
+	if !model.LegacyValidation.IsValidLabelName(label) {
+		badRequestJSON(w, fmt.Sprintf("invalid label name %q", label))
+		return
+	}
+
+	limit := defaultHistoryTopLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			badRequestJSON(w, fmt.Sprintf("invalid limit %q, must be a number >= 1", v))
+			return
+		}
+	}
+
+	rangeText := r.URL.Query().Get("range")
+	if rangeText == "" {
+		rangeText = model.Duration(defaultHistoryRange).String()
+	}
+	// use a single sample covering the whole range
+	now := time.Now()
+	hr, err := newHistoryRange(AlertHistoryPayload{Range: rangeText, Step: rangeText}, now)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+	// newHistoryRange aligns the end to the step, which would make the only
+	// sample cover the current calendar day or week, end it at the last full
+	// minute instead so it covers the whole range and can still be cached
+	hr.end = now.Truncate(time.Minute)
+
+	perSource, errs := queryHistory(historyPoller, historySources.list(now), map[string]string{}, hr, []string{label})
+
+	counts := map[string]int{}
+	for _, series := range perSource {
+		for _, hs := range series {
+			value := string(hs.labels[model.LabelName(label)])
+			if value == "" {
+				continue
+			}
+			for _, val := range hs.values {
+				if _, ok := hr.bucket(val.Timestamp); ok {
+					counts[value] += val.Value
+				}
+			}
+		}
+	}
+
+	resp := AlertHistoryTopResponse{
+		Label: label,
+		Range: model.Duration(hr.step).String(),
+		Top:   []AlertHistoryTopEntry{},
+	}
+	if len(errs) > 0 {
+		resp.Error = "One or more errors occurred when querying Prometheus API: " + strings.Join(errs, ", ")
+	}
+	for value, count := range counts {
+		if count > 0 {
+			resp.Top = append(resp.Top, AlertHistoryTopEntry{Value: value, Count: count})
+		}
+	}
+	sort.Slice(resp.Top, func(i, j int) bool {
+		if resp.Top[i].Count != resp.Top[j].Count {
+			return resp.Top[i].Count > resp.Top[j].Count
+		}
+		return resp.Top[i].Value < resp.Top[j].Value
+	})
+	if len(resp.Top) > limit {
+		resp.Top = resp.Top[:limit]
+	}
+
+	data, _ := marshalJSON(resp)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/regex"
+)
+
+func TestAlertHistoryTop(t *testing.T) {
+	prom := newFakePrometheus(t, []seriesValues{
+		{metric: model.Metric{"alertname": "Foo"}, values: []int{5}},
+		{metric: model.Metric{"alertname": "Bar"}, values: []int{7}},
+		{metric: model.Metric{"alertname": "Baz"}, values: []int{5}},
+		{metric: model.Metric{"alertname": "Zero"}, values: []int{0}},
+		{metric: model.Metric{}, values: []int{3}},
+	})
+	var lock sync.Mutex
+	queries := []string{}
+	ranges := [][2]time.Time{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/query_range" {
+			start, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+			end, _ := strconv.ParseFloat(r.FormValue("end"), 64)
+			lock.Lock()
+			queries = append(queries, r.FormValue("query"))
+			ranges = append(ranges, [2]time.Time{time.Unix(int64(start), 0), time.Unix(int64(end), 0)})
+			lock.Unlock()
+		}
+		prom.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+
+	type testCaseT struct {
+		name     string
+		query    string
+		code     int
+		err      string
+		response AlertHistoryTopResponse
+		queries  []string
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "defaults",
+			code: 200,
+			response: AlertHistoryTopResponse{
+				Label: "alertname",
+				Range: "1d",
+				Top: []AlertHistoryTopEntry{
+					{Value: "Bar", Count: 7},
+					{Value: "Baz", Count: 5},
+					{Value: "Foo", Count: 5},
+				},
+			},
+			queries: []string{"sum by (alertname) (changes(ALERTS_FOR_STATE{}[1d]))"},
+		},
+		{
+			name:  "custom label, range and limit",
+			query: "?label=job&range=7d&limit=2",
+			code:  200,
+			response: AlertHistoryTopResponse{
+				Label: "job",
+				Range: "1w",
+				Top:   []AlertHistoryTopEntry{},
+			},
+			queries: []string{"sum by (job) (changes(ALERTS_FOR_STATE{}[1w]))"},
+		},
+		{
+			name:  "limit is applied to cached results",
+			query: "?limit=1",
+			code:  200,
+			response: AlertHistoryTopResponse{
+				Label: "alertname",
+				Range: "1d",
+				Top: []AlertHistoryTopEntry{
+					{Value: "Bar", Count: 7},
+				},
+			},
+			queries: []string{},
+		},
+		{
+			name:  "invalid label",
+			query: "?label=foo-bar",
+			code:  400,
+			err:   `{"error":"invalid label name \"foo-bar\""}`,
+		},
+		{
+			name:  "invalid limit",
+			query: "?limit=0",
+			code:  400,
+			err:   `{"error":"invalid limit \"0\", must be a number \u003e= 1"}`,
+		},
+		{
+			name:  "invalid range",
+			query: "?range=60d",
+			code:  400,
+			err:   `{"error":"range must be \u003c= 30d"}`,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	config.Config.History.Rewrite = []config.HistoryRewrite{
+		{
+			SourceRegex: regex.MustCompileAnchored(regexp.QuoteMeta("http://localhost/prometheus")),
+			URI:         upstream.URL,
+		},
+	}
+	defer func() {
+		config.Config.History.Rewrite = []config.HistoryRewrite{}
+	}()
+
+	hp := newHistoryPoller(1, time.Second*5)
+	r := testRouter()
+	setupRouter(r, hp)
+	go hp.run(1)
+	defer hp.stop()
+	for !hp.isRunning.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lock.Lock()
+			queries = []string{}
+			ranges = [][2]time.Time{}
+			lock.Unlock()
+
+			now := time.Now()
+			req := httptest.NewRequest("GET", "/history/top.json"+tc.query, nil)
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Fatalf("GET /history/top.json%s returned status %d, %d expected", tc.query, resp.Code, tc.code)
+			}
+			if resp.Code != 200 {
+				if diff := cmp.Diff(tc.err, resp.Body.String()); diff != "" {
+					t.Errorf("Incorrect error response (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			var ur AlertHistoryTopResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &ur); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.response, ur); diff != "" {
+				t.Errorf("Incorrect response (-want +got):\n%s", diff)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if diff := cmp.Diff(tc.queries, queries); diff != "" {
+				t.Errorf("Incorrect queries (-want +got):\n%s", diff)
+			}
+			// a single sample at the last full minute, not at the end of
+			// the current day or week
+			for _, qr := range ranges {
+				if !qr[0].Equal(qr[1]) {
+					t.Errorf("Query start %s != end %s", qr[0], qr[1])
+				}
+				if qr[1].After(now) || qr[1].Before(now.Add(-time.Minute*2)) {
+					t.Errorf("Query end %s is not within the last 2 minutes of %s", qr[1], now)
+				}
+			}
+		})
+	}
+
+	// replica labels are kept when querying history groups
+	config.Config.History.Groups = []config.HistoryGroup{
+		{Name: "thanos", URI: upstream.URL, ReplicaLabels: []string{"replica"}},
+	}
+	defer func() {
+		config.Config.History.Groups = []config.HistoryGroup{}
+	}()
+	lock.Lock()
+	queries = []string{}
+	lock.Unlock()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/history/top.json", nil))
+	lock.Lock()
+	if diff := cmp.Diff([]string{"sum by (alertname, replica) (changes(ALERTS_FOR_STATE{}[1d]))"}, queries); diff != "" {
+		t.Errorf("Incorrect queries (-want +got):\n%s", diff)
+	}
+	lock.Unlock()
+
+	config.Config.History.Enabled = false
+	defer func() {
+		config.Config.History.Enabled = true
+	}()
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest("GET", "/history/top.json", nil))
+	if resp.Code != 400 {
+		t.Errorf("GET /history/top.json returned status %d with history disabled", resp.Code)
+	}
+}
+
+func TestHistorySourceTracker(t *testing.T) {
+	mockConfig(t.Setenv)
+
+	newGroup := func(sources ...string) models.AlertGroup {
+		ag := models.AlertGroup{}
+		for _, source := range sources {
+			ag.Alerts = append(ag.Alerts, models.Alert{
+				Alertmanager: []models.AlertmanagerInstance{{Source: source}},
+			})
+		}
+		return ag
+	}
+
+	st := &historySourceTracker{lastSeen: map[string]time.Time{}}
+	now := time.Now()
+	st.record([]models.AlertGroup{newGroup("http://a", "http://b")}, now.Add(-config.Config.History.MaxRange))
+	st.record([]models.AlertGroup{newGroup("http://c")}, now.Add(-time.Hour))
+
+	// sources with no alerts firing right now are still returned
+	if diff := cmp.Diff([]string{"http://a", "http://b", "http://c"}, st.list(now)); diff != "" {
+		t.Errorf("Incorrect sources (-want +got):\n%s", diff)
+	}
+
+	// sources not seen within max range are forgotten
+	st.record([]models.AlertGroup{newGroup("http://b")}, now.Add(time.Minute))
+	if diff := cmp.Diff([]string{"http://b", "http://c"}, st.list(now.Add(time.Minute))); diff != "" {
+		t.Errorf("Incorrect sources (-want +got):\n%s", diff)
+	}
+	if len(st.lastSeen) != 2 {
+		t.Errorf("Expired sources were not removed: %v", st.lastSeen)
+	}
+}
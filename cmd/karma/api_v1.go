@@ -0,0 +1,488 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/filters"
+	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/uri"
+)
+
+// Types in this file are part of the public /api/v1/ API, they must not be
+// changed in a way that would break existing clients, fields can only be
+// added.
+
+const (
+	apiV1DefaultLimit = 100
+	apiV1MaxLimit     = 1000
+)
+
+type APIv1Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIv1ErrorResponse is returned by all /api/v1/ endpoints on errors
+type APIv1ErrorResponse struct {
+	Error APIv1Error `json:"error"`
+}
+
+type APIv1Pagination struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	// Total is the number of all items, before applying offset and limit
+	Total int `json:"total"`
+}
+
+// APIv1List is returned by all /api/v1/ endpoints returning a list of items
+type APIv1List[T any] struct {
+	Data       []T             `json:"data"`
+	Pagination APIv1Pagination `json:"pagination"`
+}
+
+type APIv1AlertmanagerInstance struct {
+	StartsAt    time.Time `json:"startsAt"`
+	Name        string    `json:"name"`
+	Cluster     string    `json:"cluster"`
+	State       string    `json:"state"`
+	Source      string    `json:"source"`
+	SilencedBy  []string  `json:"silencedBy"`
+	InhibitedBy []string  `json:"inhibitedBy"`
+}
+
+type APIv1Alert struct {
+	StartsAt      time.Time                   `json:"startsAt"`
+	Fingerprint   string                      `json:"fingerprint"`
+	State         string                      `json:"state"`
+	Receiver      string                      `json:"receiver"`
+	Labels        map[string]string           `json:"labels"`
+	Annotations   map[string]string           `json:"annotations"`
+	Alertmanagers []APIv1AlertmanagerInstance `json:"alertmanagers"`
+	FlapScore     int                         `json:"flapScore"`
+}
+
+type APIv1AlertGroup struct {
+	ID         string            `json:"id"`
+	Receiver   string            `json:"receiver"`
+	Labels     map[string]string `json:"labels"`
+	StateCount map[string]int    `json:"stateCount"`
+	Alerts     []APIv1Alert      `json:"alerts"`
+}
+
+type APIv1SilenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+type APIv1Silence struct {
+	StartsAt   time.Time             `json:"startsAt"`
+	EndsAt     time.Time             `json:"endsAt"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	ID         string                `json:"id"`
+	Cluster    string                `json:"cluster"`
+	CreatedBy  string                `json:"createdBy"`
+	Comment    string                `json:"comment"`
+	TicketID   string                `json:"ticketID"`
+	TicketURL  string                `json:"ticketURL"`
+	Matchers   []APIv1SilenceMatcher `json:"matchers"`
+	AlertCount int                   `json:"alertCount"`
+	IsExpired  bool                  `json:"isExpired"`
+}
+
+type APIv1Upstream struct {
+	Name           string   `json:"name"`
+	Cluster        string   `json:"cluster"`
+	URI            string   `json:"uri"`
+	Version        string   `json:"version"`
+	Error          string   `json:"error"`
+	ClusterMembers []string `json:"clusterMembers"`
+	ReadOnly       bool     `json:"readonly"`
+	IsHealthy      bool     `json:"isHealthy"`
+}
+
+type apiV1Param struct {
+	name        string
+	description string
+	kind        reflect.Kind
+	repeated    bool
+}
+
+type apiV1Route struct {
+	path     string
+	summary  string
+	params   []apiV1Param
+	response reflect.Type
+	handler  http.HandlerFunc
+}
+
+var apiV1FilterParam = apiV1Param{
+	name:        "q",
+	description: "Alert filter, using the same syntax as filters in the UI, can be repeated",
+	kind:        reflect.String,
+	repeated:    true,
+}
+
+var apiV1PaginationParams = []apiV1Param{
+	{
+		name:        "offset",
+		description: "Number of items to skip",
+		kind:        reflect.Int,
+	},
+	{
+		name:        "limit",
+		description: fmt.Sprintf("Maximum number of items to return, defaults to %d, can't be more than %d", apiV1DefaultLimit, apiV1MaxLimit),
+		kind:        reflect.Int,
+	},
+}
+
+// apiV1Routes is used both to register /api/v1/ handlers and to generate
+// the OpenAPI document
+var apiV1Routes = []apiV1Route{
+	{
+		path:     "/alerts",
+		summary:  "List deduplicated alerts, sorted by labels",
+		params:   append([]apiV1Param{apiV1FilterParam}, apiV1PaginationParams...),
+		response: reflect.TypeFor[APIv1List[APIv1Alert]](),
+		handler:  apiV1Alerts,
+	},
+	{
+		path:     "/groups",
+		summary:  "List alert groups with deduplicated alerts, sorted by receiver and labels",
+		params:   append([]apiV1Param{apiV1FilterParam}, apiV1PaginationParams...),
+		response: reflect.TypeFor[APIv1List[APIv1AlertGroup]](),
+		handler:  apiV1Groups,
+	},
+	{
+		path:    "/silences",
+		summary: "List deduplicated silences, sorted by cluster and ID",
+		params: append([]apiV1Param{
+			{
+				name:        "cluster",
+				description: "Only return silences from this cluster, can be repeated",
+				kind:        reflect.String,
+				repeated:    true,
+			},
+			{
+				name:        "searchTerm",
+				description: "Only return silences matching this search term, using the same syntax as the silence browser",
+				kind:        reflect.String,
+			},
+			{
+				name:        "expired",
+				description: "Include expired silences",
+				kind:        reflect.Bool,
+			},
+		}, apiV1PaginationParams...),
+		response: reflect.TypeFor[APIv1List[APIv1Silence]](),
+		handler:  apiV1Silences,
+	},
+	{
+		path:     "/upstreams",
+		summary:  "List Alertmanager upstreams, sorted by name",
+		params:   apiV1PaginationParams,
+		response: reflect.TypeFor[APIv1List[APIv1Upstream]](),
+		handler:  apiV1Upstreams,
+	},
+}
+
+func setupAPIv1Router(r chi.Router) {
+	for _, route := range apiV1Routes {
+		r.Get(route.path, route.handler)
+	}
+	r.Get("/openapi.json", apiV1OpenAPI)
+	r.NotFound(func(w http.ResponseWriter, _ *http.Request) {
+		apiV1Error(w, http.StatusNotFound, "not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, _ *http.Request) {
+		apiV1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+}
+
+func apiV1Error(w http.ResponseWriter, code int, message string) {
+	noCache(w)
+	mimeJSON(w)
+	w.WriteHeader(code)
+	data, _ := marshalJSON(APIv1ErrorResponse{Error: APIv1Error{Code: code, Message: message}})
+	_, _ = w.Write(data)
+}
+
+// apiV1Response writes given value as JSON, map keys are sorted so the
+// response is stable across requests
+func apiV1Response(w http.ResponseWriter, v any) {
+	noCache(w)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	data, _ := jsonv2.Marshal(v, jsonv2.Deterministic(true), jsontext.EscapeForHTML(true))
+	_, _ = w.Write(data)
+}
+
+func parseAPIv1Int(r *http.Request, name string, value, minValue, maxValue int) (int, error) {
+	v, found := lookupQueryString(r, name)
+	if !found {
+		return value, nil
+	}
+	value, err := strconv.Atoi(v)
+	if err != nil || value < minValue || value > maxValue {
+		if maxValue == math.MaxInt {
+			return 0, fmt.Errorf("invalid %s value %q, must be a number >= %d", name, v, minValue)
+		}
+		return 0, fmt.Errorf("invalid %s value %q, must be a number between %d and %d", name, v, minValue, maxValue)
+	}
+	return value, nil
+}
+
+func parseAPIv1Pagination(r *http.Request) (p APIv1Pagination, err error) {
+	if p.Offset, err = parseAPIv1Int(r, "offset", 0, 0, math.MaxInt); err != nil {
+		return p, err
+	}
+	if p.Limit, err = parseAPIv1Int(r, "limit", apiV1DefaultLimit, 1, apiV1MaxLimit); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func paginateAPIv1[T any](items []T, p APIv1Pagination) APIv1List[T] {
+	p.Total = len(items)
+	start := min(p.Offset, len(items))
+	end := min(start+p.Limit, len(items))
+	data := make([]T, 0, end-start)
+	data = append(data, items[start:end]...)
+	return APIv1List[T]{Data: data, Pagination: p}
+}
+
+// parseAPIv1Filters returns filters passed via ?q=..., unlike UI endpoints
+// invalid filters are rejected instead of being ignored
+func parseAPIv1Filters(r *http.Request) ([]filters.Filter, error) {
+	q, _ := lookupQueryStringSlice(r, "q")
+	fl := getFiltersFromQuery(q)
+	for _, f := range fl {
+		if !f.Valid() {
+			return nil, fmt.Errorf("invalid filter %q: %s", f.RawText(), f.Error())
+		}
+	}
+	return fl, nil
+}
+
+func newAPIv1Alert(ag models.AlertGroup, alert models.Alert) APIv1Alert {
+	a := APIv1Alert{
+		StartsAt:      alert.StartsAt,
+		Fingerprint:   alert.LabelsFP,
+		State:         alert.State.String(),
+		Receiver:      alert.Receiver,
+		Labels:        alert.Labels.Map(),
+		Annotations:   make(map[string]string, len(alert.Annotations)),
+		Alertmanagers: make([]APIv1AlertmanagerInstance, 0, len(alert.Alertmanager)),
+		FlapScore:     alert.FlapScore,
+	}
+	ag.Labels.Range(func(l promlabels.Label) {
+		a.Labels[l.Name] = l.Value
+	})
+	for _, annotation := range alert.Annotations {
+		a.Annotations[annotation.Name] = annotation.Value
+	}
+	for _, am := range alert.Alertmanager {
+		a.Alertmanagers = append(a.Alertmanagers, APIv1AlertmanagerInstance{
+			StartsAt:    am.StartsAt,
+			Name:        am.Name,
+			Cluster:     am.Cluster,
+			State:       am.State.String(),
+			Source:      am.Source,
+			SilencedBy:  nonNilStrings(am.SilencedBy),
+			InhibitedBy: nonNilStrings(am.InhibitedBy),
+		})
+	}
+	sort.Slice(a.Alertmanagers, func(i, j int) bool {
+		return a.Alertmanagers[i].Name < a.Alertmanagers[j].Name
+	})
+	return a
+}
+
+func newAPIv1AlertGroup(ag models.AlertGroup) APIv1AlertGroup {
+	g := APIv1AlertGroup{
+		ID:         ag.ID,
+		Receiver:   ag.Receiver,
+		Labels:     ag.Labels.Map(),
+		StateCount: ag.StateCount,
+		Alerts:     make([]APIv1Alert, 0, len(ag.Alerts)),
+	}
+	for _, alert := range ag.Alerts {
+		g.Alerts = append(g.Alerts, newAPIv1Alert(ag, alert))
+	}
+	sortAPIv1Alerts(g.Alerts)
+	return g
+}
+
+func compareLabelMaps(a, b map[string]string) int {
+	return promlabels.Compare(promlabels.FromMap(a), promlabels.FromMap(b))
+}
+
+func sortAPIv1Alerts(alerts []APIv1Alert) {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if c := compareLabelMaps(alerts[i].Labels, alerts[j].Labels); c != 0 {
+			return c < 0
+		}
+		return alerts[i].Receiver < alerts[j].Receiver
+	})
+}
+
+func apiV1Alerts(w http.ResponseWriter, r *http.Request) {
+	page, err := parseAPIv1Pagination(r)
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fl, err := parseAPIv1Filters(r)
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	alerts := []APIv1Alert{}
+	for _, ag := range filterAlerts(alertmanager.DedupAlerts(), fl) {
+		for _, alert := range ag.Alerts {
+			alerts = append(alerts, newAPIv1Alert(ag, alert))
+		}
+	}
+	sortAPIv1Alerts(alerts)
+
+	apiV1Response(w, paginateAPIv1(alerts, page))
+}
+
+func apiV1Groups(w http.ResponseWriter, r *http.Request) {
+	page, err := parseAPIv1Pagination(r)
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fl, err := parseAPIv1Filters(r)
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groups := []APIv1AlertGroup{}
+	for _, ag := range filterAlerts(alertmanager.DedupAlerts(), fl) {
+		groups = append(groups, newAPIv1AlertGroup(ag))
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Receiver != groups[j].Receiver {
+			return groups[i].Receiver < groups[j].Receiver
+		}
+		if c := compareLabelMaps(groups[i].Labels, groups[j].Labels); c != 0 {
+			return c < 0
+		}
+		return groups[i].ID < groups[j].ID
+	})
+
+	apiV1Response(w, paginateAPIv1(groups, page))
+}
+
+func apiV1Silences(w http.ResponseWriter, r *http.Request) {
+	page, err := parseAPIv1Pagination(r)
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	expired, _ := lookupQueryString(r, "expired")
+	withExpired, err := strconv.ParseBool(cmp.Or(expired, "false"))
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, fmt.Sprintf("invalid expired value %q, must be true or false", expired))
+		return
+	}
+	clusterNames, _ := lookupQueryStringSlice(r, "cluster")
+	searchTerm, _ := lookupQueryString(r, "searchTerm")
+	searchTerm = strings.ToLower(searchTerm)
+	searchClusters := silenceSearchClusters(searchTerm)
+
+	dedupedSilences := alertmanager.DedupSilences()
+	countSilencedAlerts(dedupedSilences, alertmanager.DedupAlerts())
+
+	silences := []APIv1Silence{}
+	for _, ms := range dedupedSilences {
+		if ms.IsExpired && !withExpired {
+			continue
+		}
+		if len(clusterNames) > 0 && !slices.Contains(clusterNames, ms.Cluster) {
+			continue
+		}
+		if searchTerm != "" && !silenceMatchesSearchTerm(ms, searchTerm, searchClusters) {
+			continue
+		}
+		s := APIv1Silence{
+			StartsAt:   ms.Silence.StartsAt,
+			EndsAt:     ms.Silence.EndsAt,
+			CreatedAt:  ms.Silence.CreatedAt,
+			ID:         ms.Silence.ID,
+			Cluster:    ms.Cluster,
+			CreatedBy:  ms.Silence.CreatedBy,
+			Comment:    ms.Silence.Comment,
+			TicketID:   ms.Silence.TicketID,
+			TicketURL:  ms.Silence.TicketURL,
+			Matchers:   make([]APIv1SilenceMatcher, 0, len(ms.Silence.Matchers)),
+			AlertCount: ms.AlertCount,
+			IsExpired:  ms.IsExpired,
+		}
+		for _, m := range ms.Silence.Matchers {
+			s.Matchers = append(s.Matchers, APIv1SilenceMatcher{
+				Name:    m.Name,
+				Value:   m.Value,
+				IsRegex: m.IsRegex,
+				IsEqual: m.IsEqual,
+			})
+		}
+		silences = append(silences, s)
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		if silences[i].Cluster != silences[j].Cluster {
+			return silences[i].Cluster < silences[j].Cluster
+		}
+		return silences[i].ID < silences[j].ID
+	})
+
+	apiV1Response(w, paginateAPIv1(silences, page))
+}
+
+func apiV1Upstreams(w http.ResponseWriter, r *http.Request) {
+	page, err := parseAPIv1Pagination(r)
+	if err != nil {
+		apiV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	upstreams := []APIv1Upstream{}
+	for _, am := range alertmanager.GetAlertmanagers() {
+		upstreams = append(upstreams, APIv1Upstream{
+			Name:           am.Name,
+			Cluster:        am.Cluster,
+			URI:            uri.WithoutUserinfo(am.PublicURI()),
+			Version:        am.Version(),
+			Error:          am.Error(),
+			ClusterMembers: am.ClusterMemberNames(),
+			ReadOnly:       am.ReadOnly,
+			IsHealthy:      am.IsHealthy(),
+		})
+	}
+	sort.Slice(upstreams, func(i, j int) bool {
+		return upstreams[i].Name < upstreams[j].Name
+	})
+
+	apiV1Response(w, paginateAPIv1(upstreams, page))
+}
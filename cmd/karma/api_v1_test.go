@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAPIv1(t *testing.T) {
+	type testCaseT struct {
+		name       string
+		path       string
+		code       int
+		ids        []string
+		pagination APIv1Pagination
+		err        string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:       "alerts",
+			path:       "/api/v1/alerts?q=alertname=Host_Down&q=@receiver=by-name&q=cluster=dev",
+			code:       200,
+			ids:        []string{"server6", "server7", "server8"},
+			pagination: APIv1Pagination{Offset: 0, Limit: 100, Total: 3},
+		},
+		{
+			name:       "alerts with offset and limit",
+			path:       "/api/v1/alerts?q=alertname=Host_Down&q=@receiver=by-name&offset=1&limit=2",
+			code:       200,
+			ids:        []string{"server7", "server8"},
+			pagination: APIv1Pagination{Offset: 1, Limit: 2, Total: 8},
+		},
+		{
+			name:       "alerts with offset past the end",
+			path:       "/api/v1/alerts?offset=100",
+			code:       200,
+			ids:        []string{},
+			pagination: APIv1Pagination{Offset: 100, Limit: 100, Total: 24},
+		},
+		{
+			name: "alerts with invalid filter",
+			path: "/api/v1/alerts?q=alertname=~(",
+			code: 400,
+			err:  "invalid filter \"alertname=~(\": invalid regular expression \"(\": error parsing regexp: missing closing ): `(?i)(`",
+		},
+		{
+			name: "alerts with invalid limit",
+			path: "/api/v1/alerts?limit=1001",
+			code: 400,
+			err:  "invalid limit value \"1001\", must be a number between 1 and 1000",
+		},
+		{
+			name: "alerts with invalid offset",
+			path: "/api/v1/alerts?offset=foo",
+			code: 400,
+			err:  "invalid offset value \"foo\", must be a number >= 0",
+		},
+		{
+			name:       "groups",
+			path:       "/api/v1/groups?q=@receiver=by-name&limit=3",
+			code:       200,
+			ids:        []string{"Free_Disk_Space_Too_Low", "HTTP_Probe_Failed", "Host_Down"},
+			pagination: APIv1Pagination{Offset: 0, Limit: 3, Total: 4},
+		},
+		{
+			name:       "silences",
+			path:       "/api/v1/silences",
+			code:       200,
+			ids:        []string{"810ccf7f-c957-474a-b383-7e76d66a4d3b", "9bd58938-25fd-41c5-aba3-9bc373074484", "dcb3b5d0-9f10-4baa-977a-70073a1899bd"},
+			pagination: APIv1Pagination{Offset: 0, Limit: 100, Total: 3},
+		},
+		{
+			name:       "silences with search term",
+			path:       "/api/v1/silences?searchTerm=web1&expired=true",
+			code:       200,
+			ids:        []string{"810ccf7f-c957-474a-b383-7e76d66a4d3b"},
+			pagination: APIv1Pagination{Offset: 0, Limit: 100, Total: 1},
+		},
+		{
+			name:       "silences from unknown cluster",
+			path:       "/api/v1/silences?cluster=foo",
+			code:       200,
+			ids:        []string{},
+			pagination: APIv1Pagination{Offset: 0, Limit: 100, Total: 0},
+		},
+		{
+			name: "silences with invalid expired",
+			path: "/api/v1/silences?expired=foo",
+			code: 400,
+			err:  "invalid expired value \"foo\", must be true or false",
+		},
+		{
+			name:       "upstreams",
+			path:       "/api/v1/upstreams",
+			code:       200,
+			ids:        []string{"default"},
+			pagination: APIv1Pagination{Offset: 0, Limit: 100, Total: 1},
+		},
+		{
+			name: "unknown endpoint",
+			path: "/api/v1/foo",
+			code: 404,
+			err:  "not found",
+		},
+	}
+
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Fatalf("GET %s returned status %d, %d expected: %s", tc.path, resp.Code, tc.code, resp.Body.String())
+			}
+
+			if tc.err != "" {
+				var er APIv1ErrorResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &er); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(APIv1ErrorResponse{Error: APIv1Error{Code: tc.code, Message: tc.err}}, er); diff != "" {
+					t.Errorf("Incorrect error response (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			ids := []string{}
+			var pagination APIv1Pagination
+			switch {
+			case strings.HasPrefix(tc.path, "/api/v1/alerts"):
+				var list APIv1List[APIv1Alert]
+				if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+					t.Fatal(err)
+				}
+				for _, a := range list.Data {
+					ids = append(ids, a.Labels["instance"])
+				}
+				pagination = list.Pagination
+			case strings.HasPrefix(tc.path, "/api/v1/groups"):
+				var list APIv1List[APIv1AlertGroup]
+				if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+					t.Fatal(err)
+				}
+				for _, g := range list.Data {
+					ids = append(ids, g.Labels["alertname"])
+				}
+				pagination = list.Pagination
+			case strings.HasPrefix(tc.path, "/api/v1/silences"):
+				var list APIv1List[APIv1Silence]
+				if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+					t.Fatal(err)
+				}
+				for _, s := range list.Data {
+					ids = append(ids, s.ID)
+				}
+				pagination = list.Pagination
+			case strings.HasPrefix(tc.path, "/api/v1/upstreams"):
+				var list APIv1List[APIv1Upstream]
+				if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+					t.Fatal(err)
+				}
+				for _, u := range list.Data {
+					ids = append(ids, u.Name)
+				}
+				pagination = list.Pagination
+			}
+			if diff := cmp.Diff(tc.ids, ids); diff != "" {
+				t.Errorf("Incorrect items returned (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.pagination, pagination); diff != "" {
+				t.Errorf("Incorrect pagination (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPIv1Alert(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/alerts?q=instance=web1&q=@receiver=by-name", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("GET /api/v1/alerts returned status %d", resp.Code)
+	}
+	var list APIv1List[APIv1Alert]
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(list.Data))
+	}
+
+	alert := list.Data[0]
+	alert.StartsAt = alert.StartsAt.UTC()
+	for i := range alert.Alertmanagers {
+		alert.Alertmanagers[i].StartsAt = alert.StartsAt
+	}
+	expected := APIv1Alert{
+		StartsAt:    alert.StartsAt,
+		Fingerprint: "6136a06cbc37e97b",
+		State:       "suppressed",
+		Receiver:    "by-name",
+		Labels: map[string]string{
+			"alertname": "HTTP_Probe_Failed",
+			"cluster":   "dev",
+			"instance":  "web1",
+			"job":       "node_exporter",
+		},
+		Annotations: map[string]string{
+			"help":    "Example help annotation",
+			"summary": "Example summary",
+			"url":     "http://localhost/example.html",
+		},
+		Alertmanagers: []APIv1AlertmanagerInstance{
+			{
+				StartsAt:    alert.StartsAt,
+				Name:        "default",
+				Cluster:     "default",
+				State:       "suppressed",
+				Source:      "http://localhost/prometheus",
+				SilencedBy:  []string{"810ccf7f-c957-474a-b383-7e76d66a4d3b"},
+				InhibitedBy: []string{},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, alert); diff != "" {
+		t.Errorf("Incorrect alert (-want +got):\n%s", diff)
+	}
+}
+
+func TestAPIv1SilenceAlertCount(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/silences", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("GET /api/v1/silences returned status %d", resp.Code)
+	}
+	var list APIv1List[APIv1Silence]
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for _, s := range list.Data {
+		counts[s.ID] = s.AlertCount
+	}
+	expected := map[string]int{
+		"810ccf7f-c957-474a-b383-7e76d66a4d3b": 2,
+		"9bd58938-25fd-41c5-aba3-9bc373074484": 2,
+		"dcb3b5d0-9f10-4baa-977a-70073a1899bd": 6,
+	}
+	if diff := cmp.Diff(expected, counts); diff != "" {
+		t.Errorf("Incorrect silence alert counts (-want +got):\n%s", diff)
+	}
+}
+
+func TestAPIv1OpenAPI(t *testing.T) {
+	mockConfig(t.Setenv)
+	r := testRouter()
+	setupRouter(r, nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("GET /api/v1/openapi.json returned status %d", resp.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+				Required   []string       `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Wrong openapi version: %q", doc.OpenAPI)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "/api/v1" {
+		t.Errorf("Wrong servers: %v", doc.Servers)
+	}
+	for _, route := range apiV1Routes {
+		if _, ok := doc.Paths[route.path]["get"]; !ok {
+			t.Errorf("Path %q is missing from OpenAPI document", route.path)
+		}
+	}
+
+	// every $ref must point to an existing schema
+	refs := regexp.MustCompile(`"#/components/schemas/([^"]+)"`)
+	for _, ref := range refs.FindAllStringSubmatch(resp.Body.String(), -1) {
+		if _, ok := doc.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("Schema %q is referenced but missing", ref[1])
+		}
+	}
+
+	silence := doc.Components.Schemas["APIv1Silence"]
+	if diff := cmp.Diff(
+		[]string{"startsAt", "endsAt", "createdAt", "id", "cluster", "createdBy", "comment", "ticketID", "ticketURL", "matchers", "alertCount", "isExpired"},
+		silence.Required,
+	); diff != "" {
+		t.Errorf("Wrong required properties for APIv1Silence (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(
+		map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/APIv1SilenceMatcher"}},
+		silence.Properties["matchers"],
+	); diff != "" {
+		t.Errorf("Wrong schema for APIv1Silence.matchers (-want +got):\n%s", diff)
+	}
+}
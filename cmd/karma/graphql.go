@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/graph-gophers/graphql-go"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/filters"
+	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/uri"
+)
+
+const graphQLSchemaText = `
+schema {
+	query: Query
+}
+
+scalar Time
+
+type Query {
+	# deduplicated alerts matching all filters, using the same syntax as filters in the UI
+	alerts(filters: [String!]): [Alert!]!
+	# alert groups with deduplicated alerts matching all filters
+	alertGroups(filters: [String!]): [AlertGroup!]!
+	# deduplicated silences, expired silences are only included if expired is true
+	silences(cluster: [String!], searchTerm: String, expired: Boolean): [Silence!]!
+	upstreams: [Upstream!]!
+}
+
+type Label {
+	name: String!
+	value: String!
+}
+
+type Annotation {
+	name: String!
+	value: String!
+	visible: Boolean!
+	isLink: Boolean!
+	isAction: Boolean!
+}
+
+type StateCount {
+	state: String!
+	count: Int!
+}
+
+type AlertGroup {
+	id: String!
+	receiver: String!
+	labels: [Label!]!
+	stateCount: [StateCount!]!
+	alerts: [Alert!]!
+}
+
+type Alert {
+	fingerprint: String!
+	startsAt: Time!
+	state: String!
+	receiver: String!
+	# alert labels, including group labels
+	labels: [Label!]!
+	label(name: String!): String
+	annotations: [Annotation!]!
+	flapScore: Int!
+	isFlapping: Boolean!
+	alertmanagers: [AlertmanagerInstance!]!
+	# all silences matching this alert on any Alertmanager instance
+	silences: [Silence!]!
+}
+
+type AlertmanagerInstance {
+	name: String!
+	cluster: String!
+	state: String!
+	startsAt: Time!
+	source: String!
+	fingerprint: String!
+	silencedBy: [String!]!
+	inhibitedBy: [String!]!
+	silences: [Silence!]!
+	upstream: Upstream
+}
+
+type SilenceMatcher {
+	name: String!
+	value: String!
+	isRegex: Boolean!
+	isEqual: Boolean!
+}
+
+type Silence {
+	id: String!
+	cluster: String!
+	startsAt: Time!
+	endsAt: Time!
+	createdAt: Time!
+	createdBy: String!
+	comment: String!
+	ticketID: String!
+	ticketURL: String!
+	matchers: [SilenceMatcher!]!
+	alertCount: Int!
+	isExpired: Boolean!
+}
+
+type Upstream {
+	name: String!
+	cluster: String!
+	uri: String!
+	version: String!
+	error: String!
+	readonly: Boolean!
+	isHealthy: Boolean!
+	clusterMembers: [String!]!
+}
+`
+
+const (
+	graphQLMaxDepth       = 8
+	graphQLMaxParallelism = 10
+	graphQLMaxQueryLength = 8192
+	// maximum number of alerts, alertGroups and silences fields in a single
+	// query, each one needs to deduplicate and filter everything again, so
+	// it limits how many aliases of those fields can be used
+	graphQLMaxRootFields = 10
+)
+
+var graphQLSchema = graphql.MustParseSchema(
+	graphQLSchemaText,
+	&graphQLResolver{},
+	graphql.MaxDepth(graphQLMaxDepth),
+	graphql.MaxParallelism(graphQLMaxParallelism),
+	graphql.MaxQueryLength(graphQLMaxQueryLength),
+)
+
+type graphQLQueryStateKey struct{}
+
+// graphQLQueryState holds state shared by all resolvers used to respond to
+// a single query, deduplicated silences are only fetched and counted if any
+// resolver needs them
+type graphQLQueryState struct {
+	rootFields   atomic.Int32
+	silences     []models.ManagedSilence
+	silenceIndex map[string]map[string]int
+	silencesOnce sync.Once
+}
+
+func getGraphQLQueryState(ctx context.Context) *graphQLQueryState {
+	if qs, ok := ctx.Value(graphQLQueryStateKey{}).(*graphQLQueryState); ok {
+		return qs
+	}
+	return &graphQLQueryState{}
+}
+
+// useRootField returns an error once a query used more expensive root fields
+// than allowed
+func (qs *graphQLQueryState) useRootField() error {
+	if qs.rootFields.Add(1) > graphQLMaxRootFields {
+		return fmt.Errorf("query can't use more than %d alerts, alertGroups or silences fields", graphQLMaxRootFields)
+	}
+	return nil
+}
+
+func (qs *graphQLQueryState) dedupedSilences() []models.ManagedSilence {
+	qs.silencesOnce.Do(func() {
+		qs.silences = alertmanager.DedupSilences()
+		countSilencedAlerts(qs.silences, alertmanager.DedupAlerts())
+		qs.silenceIndex = map[string]map[string]int{}
+		for i, ms := range qs.silences {
+			if _, ok := qs.silenceIndex[ms.Cluster]; !ok {
+				qs.silenceIndex[ms.Cluster] = map[string]int{}
+			}
+			qs.silenceIndex[ms.Cluster][ms.Silence.ID] = i
+		}
+	})
+	return qs.silences
+}
+
+func (qs *graphQLQueryState) silence(cluster, id string) (models.ManagedSilence, bool) {
+	silences := qs.dedupedSilences()
+	i, ok := qs.silenceIndex[cluster][id]
+	if !ok {
+		return models.ManagedSilence{}, false
+	}
+	return silences[i], true
+}
+
+type graphQLResolver struct{}
+
+type graphQLFilterArgs struct {
+	Filters *[]string
+}
+
+func (args graphQLFilterArgs) filters() ([]filters.Filter, error) {
+	if args.Filters == nil {
+		return []filters.Filter{}, nil
+	}
+	fl := getFiltersFromQuery(*args.Filters)
+	for _, f := range fl {
+		if !f.Valid() {
+			return nil, fmt.Errorf("invalid filter %q: %s", f.RawText(), f.Error())
+		}
+	}
+	return fl, nil
+}
+
+func (*graphQLResolver) Alerts(ctx context.Context, args graphQLFilterArgs) ([]*graphQLAlert, error) {
+	qs := getGraphQLQueryState(ctx)
+	if err := qs.useRootField(); err != nil {
+		return nil, err
+	}
+	fl, err := args.filters()
+	if err != nil {
+		return nil, err
+	}
+	alerts := []*graphQLAlert{}
+	for _, ag := range filterAlerts(alertmanager.DedupAlerts(), fl) {
+		for _, alert := range ag.Alerts {
+			alerts = append(alerts, newGraphQLAlert(qs, ag, alert))
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if c := promlabels.Compare(alerts[i].labels, alerts[j].labels); c != 0 {
+			return c < 0
+		}
+		return alerts[i].alert.Receiver < alerts[j].alert.Receiver
+	})
+	return alerts, nil
+}
+
+func (*graphQLResolver) AlertGroups(ctx context.Context, args graphQLFilterArgs) ([]*graphQLAlertGroup, error) {
+	qs := getGraphQLQueryState(ctx)
+	if err := qs.useRootField(); err != nil {
+		return nil, err
+	}
+	fl, err := args.filters()
+	if err != nil {
+		return nil, err
+	}
+	groups := []*graphQLAlertGroup{}
+	for _, ag := range filterAlerts(alertmanager.DedupAlerts(), fl) {
+		groups = append(groups, &graphQLAlertGroup{qs: qs, group: ag})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].group.Receiver != groups[j].group.Receiver {
+			return groups[i].group.Receiver < groups[j].group.Receiver
+		}
+		if c := promlabels.Compare(groups[i].group.Labels, groups[j].group.Labels); c != 0 {
+			return c < 0
+		}
+		return groups[i].group.ID < groups[j].group.ID
+	})
+	return groups, nil
+}
+
+type graphQLSilencesArgs struct {
+	Cluster    *[]string
+	SearchTerm *string
+	Expired    *bool
+}
+
+func (*graphQLResolver) Silences(ctx context.Context, args graphQLSilencesArgs) ([]*graphQLSilence, error) {
+	qs := getGraphQLQueryState(ctx)
+	if err := qs.useRootField(); err != nil {
+		return nil, err
+	}
+	var searchTerm string
+	if args.SearchTerm != nil {
+		searchTerm = strings.ToLower(*args.SearchTerm)
+	}
+	searchClusters := silenceSearchClusters(searchTerm)
+
+	silences := []*graphQLSilence{}
+	for _, ms := range qs.dedupedSilences() {
+		if ms.IsExpired && (args.Expired == nil || !*args.Expired) {
+			continue
+		}
+		if args.Cluster != nil && !slices.Contains(*args.Cluster, ms.Cluster) {
+			continue
+		}
+		if searchTerm != "" && !silenceMatchesSearchTerm(ms, searchTerm, searchClusters) {
+			continue
+		}
+		silences = append(silences, &graphQLSilence{ms: ms})
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		if silences[i].ms.Cluster != silences[j].ms.Cluster {
+			return silences[i].ms.Cluster < silences[j].ms.Cluster
+		}
+		return silences[i].ms.Silence.ID < silences[j].ms.Silence.ID
+	})
+	return silences, nil
+}
+
+func (*graphQLResolver) Upstreams() []*graphQLUpstream {
+	upstreams := []*graphQLUpstream{}
+	for _, am := range alertmanager.GetAlertmanagers() {
+		upstreams = append(upstreams, &graphQLUpstream{am: am})
+	}
+	sort.Slice(upstreams, func(i, j int) bool {
+		return upstreams[i].am.Name < upstreams[j].am.Name
+	})
+	return upstreams
+}
+
+type graphQLLabel struct {
+	l promlabels.Label
+}
+
+func (l graphQLLabel) Name() string  { return l.l.Name }
+func (l graphQLLabel) Value() string { return l.l.Value }
+
+func newGraphQLLabels(ls promlabels.Labels) []graphQLLabel {
+	labels := make([]graphQLLabel, 0, ls.Len())
+	ls.Range(func(l promlabels.Label) {
+		labels = append(labels, graphQLLabel{l: l})
+	})
+	return labels
+}
+
+type graphQLAnnotation struct {
+	a models.Annotation
+}
+
+func (a graphQLAnnotation) Name() string   { return a.a.Name }
+func (a graphQLAnnotation) Value() string  { return a.a.Value }
+func (a graphQLAnnotation) Visible() bool  { return a.a.Visible }
+func (a graphQLAnnotation) IsLink() bool   { return a.a.IsLink }
+func (a graphQLAnnotation) IsAction() bool { return a.a.IsAction }
+
+type graphQLStateCount struct {
+	state string
+	count int32
+}
+
+func (sc graphQLStateCount) State() string { return sc.state }
+func (sc graphQLStateCount) Count() int32  { return sc.count }
+
+type graphQLAlertGroup struct {
+	qs    *graphQLQueryState
+	group models.AlertGroup
+}
+
+func (g *graphQLAlertGroup) ID() string             { return g.group.ID }
+func (g *graphQLAlertGroup) Receiver() string       { return g.group.Receiver }
+func (g *graphQLAlertGroup) Labels() []graphQLLabel { return newGraphQLLabels(g.group.Labels) }
+
+func (g *graphQLAlertGroup) StateCount() []graphQLStateCount {
+	counts := make([]graphQLStateCount, 0, len(models.AlertStateList))
+	for _, s := range models.AlertStateList {
+		counts = append(counts, graphQLStateCount{state: s.String(), count: int32(g.group.StateCount[s.String()])})
+	}
+	return counts
+}
+
+func (g *graphQLAlertGroup) Alerts() []*graphQLAlert {
+	alerts := make([]*graphQLAlert, 0, len(g.group.Alerts))
+	for _, alert := range g.group.Alerts {
+		alerts = append(alerts, newGraphQLAlert(g.qs, g.group, alert))
+	}
+	return alerts
+}
+
+type graphQLAlert struct {
+	qs     *graphQLQueryState
+	alert  models.Alert
+	labels promlabels.Labels
+}
+
+func newGraphQLAlert(qs *graphQLQueryState, ag models.AlertGroup, alert models.Alert) *graphQLAlert {
+	b := promlabels.NewBuilder(alert.Labels)
+	ag.Labels.Range(func(l promlabels.Label) {
+		b.Set(l.Name, l.Value)
+	})
+	return &graphQLAlert{qs: qs, alert: alert, labels: b.Labels()}
+}
+
+func (a *graphQLAlert) Fingerprint() string    { return a.alert.LabelsFP }
+func (a *graphQLAlert) StartsAt() graphql.Time { return graphql.Time{Time: a.alert.StartsAt} }
+func (a *graphQLAlert) State() string          { return a.alert.State.String() }
+func (a *graphQLAlert) Receiver() string       { return a.alert.Receiver }
+func (a *graphQLAlert) Labels() []graphQLLabel { return newGraphQLLabels(a.labels) }
+func (a *graphQLAlert) FlapScore() int32       { return int32(a.alert.FlapScore) }
+func (a *graphQLAlert) IsFlapping() bool       { return a.alert.IsFlapping() }
+
+func (a *graphQLAlert) Label(args struct{ Name string }) *string {
+	if !a.labels.Has(args.Name) {
+		return nil
+	}
+	v := a.labels.Get(args.Name)
+	return &v
+}
+
+func (a *graphQLAlert) Annotations() []graphQLAnnotation {
+	annotations := make([]graphQLAnnotation, 0, len(a.alert.Annotations))
+	for _, annotation := range a.alert.Annotations {
+		annotations = append(annotations, graphQLAnnotation{a: annotation})
+	}
+	return annotations
+}
+
+func (a *graphQLAlert) Alertmanagers() []*graphQLAlertmanagerInstance {
+	instances := make([]*graphQLAlertmanagerInstance, 0, len(a.alert.Alertmanager))
+	for _, am := range a.alert.Alertmanager {
+		instances = append(instances, &graphQLAlertmanagerInstance{qs: a.qs, am: am})
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].am.Name < instances[j].am.Name
+	})
+	return instances
+}
+
+func (a *graphQLAlert) Silences() []*graphQLSilence {
+	seen := map[string]struct{}{}
+	silences := []*graphQLSilence{}
+	for _, am := range a.Alertmanagers() {
+		for _, s := range am.Silences() {
+			key := s.ms.Cluster + "/" + s.ms.Silence.ID
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			silences = append(silences, s)
+		}
+	}
+	return silences
+}
+
+type graphQLAlertmanagerInstance struct {
+	qs *graphQLQueryState
+	am models.AlertmanagerInstance
+}
+
+func (i *graphQLAlertmanagerInstance) Name() string    { return i.am.Name }
+func (i *graphQLAlertmanagerInstance) Cluster() string { return i.am.Cluster }
+func (i *graphQLAlertmanagerInstance) State() string   { return i.am.State.String() }
+func (i *graphQLAlertmanagerInstance) StartsAt() graphql.Time {
+	return graphql.Time{Time: i.am.StartsAt}
+}
+func (i *graphQLAlertmanagerInstance) Source() string        { return i.am.Source }
+func (i *graphQLAlertmanagerInstance) Fingerprint() string   { return i.am.Fingerprint }
+func (i *graphQLAlertmanagerInstance) SilencedBy() []string  { return nonNilStrings(i.am.SilencedBy) }
+func (i *graphQLAlertmanagerInstance) InhibitedBy() []string { return nonNilStrings(i.am.InhibitedBy) }
+
+func (i *graphQLAlertmanagerInstance) Silences() []*graphQLSilence {
+	silences := make([]*graphQLSilence, 0, len(i.am.SilencedBy))
+	for _, id := range i.am.SilencedBy {
+		if ms, ok := i.qs.silence(i.am.Cluster, id); ok {
+			silences = append(silences, &graphQLSilence{ms: ms})
+			continue
+		}
+		// expired silences matching this alert are only tracked on the
+		// instance itself
+		if s, ok := i.am.Silences[id]; ok && s != nil {
+			silences = append(silences, &graphQLSilence{ms: models.ManagedSilence{Cluster: i.am.Cluster, Silence: *s, IsExpired: true}})
+		}
+	}
+	return silences
+}
+
+func (i *graphQLAlertmanagerInstance) Upstream() *graphQLUpstream {
+	am := alertmanager.GetAlertmanagerByName(i.am.Name)
+	if am == nil {
+		return nil
+	}
+	return &graphQLUpstream{am: am}
+}
+
+type graphQLSilenceMatcher struct {
+	m models.SilenceMatcher
+}
+
+func (m graphQLSilenceMatcher) Name() string  { return m.m.Name }
+func (m graphQLSilenceMatcher) Value() string { return m.m.Value }
+func (m graphQLSilenceMatcher) IsRegex() bool { return m.m.IsRegex }
+func (m graphQLSilenceMatcher) IsEqual() bool { return m.m.IsEqual }
+
+type graphQLSilence struct {
+	ms models.ManagedSilence
+}
+
+func (s *graphQLSilence) ID() string              { return s.ms.Silence.ID }
+func (s *graphQLSilence) Cluster() string         { return s.ms.Cluster }
+func (s *graphQLSilence) StartsAt() graphql.Time  { return graphql.Time{Time: s.ms.Silence.StartsAt} }
+func (s *graphQLSilence) EndsAt() graphql.Time    { return graphql.Time{Time: s.ms.Silence.EndsAt} }
+func (s *graphQLSilence) CreatedAt() graphql.Time { return graphql.Time{Time: s.ms.Silence.CreatedAt} }
+func (s *graphQLSilence) CreatedBy() string       { return s.ms.Silence.CreatedBy }
+func (s *graphQLSilence) Comment() string         { return s.ms.Silence.Comment }
+func (s *graphQLSilence) TicketID() string        { return s.ms.Silence.TicketID }
+func (s *graphQLSilence) TicketURL() string       { return s.ms.Silence.TicketURL }
+func (s *graphQLSilence) AlertCount() int32       { return int32(s.ms.AlertCount) }
+func (s *graphQLSilence) IsExpired() bool         { return s.ms.IsExpired }
+
+func (s *graphQLSilence) Matchers() []graphQLSilenceMatcher {
+	matchers := make([]graphQLSilenceMatcher, 0, len(s.ms.Silence.Matchers))
+	for _, m := range s.ms.Silence.Matchers {
+		matchers = append(matchers, graphQLSilenceMatcher{m: m})
+	}
+	return matchers
+}
+
+type graphQLUpstream struct {
+	am *alertmanager.Alertmanager
+}
+
+func (u *graphQLUpstream) Name() string             { return u.am.Name }
+func (u *graphQLUpstream) Cluster() string          { return u.am.Cluster }
+func (u *graphQLUpstream) URI() string              { return uri.WithoutUserinfo(u.am.PublicURI()) }
+func (u *graphQLUpstream) Version() string          { return u.am.Version() }
+func (u *graphQLUpstream) Error() string            { return u.am.Error() }
+func (u *graphQLUpstream) Readonly() bool           { return u.am.ReadOnly }
+func (u *graphQLUpstream) IsHealthy() bool          { return u.am.IsHealthy() }
+func (u *graphQLUpstream) ClusterMembers() []string { return nonNilStrings(u.am.ClusterMemberNames()) }
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func executeGraphQL(ctx context.Context, req GraphQLRequest) *graphql.Response {
+	ctx = context.WithValue(ctx, graphQLQueryStateKey{}, &graphQLQueryState{})
+	return graphQLSchema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+}
+
+// graphQLHandler responds to GraphQL queries send as JSON body of POST
+// requests, errors are returned as part of the GraphQL response
+func graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	var req GraphQLRequest
+	if err := jsonv2.UnmarshalRead(r.Body, &req); err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	data, _ := marshalJSON(executeGraphQL(r.Context(), req))
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
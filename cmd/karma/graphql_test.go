@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/prymitive/karma/internal/config"
+)
+
+func TestGraphQL(t *testing.T) {
+	type testCaseT struct {
+		name string
+		body string
+		code int
+		resp string
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "alerts with silences and upstream status",
+			body: `{"query":"{ alerts(filters: [\"instance=web1\", \"@receiver=by-name\"]) { fingerprint state job: label(name: \"job\") foo: label(name: \"foo\") silences { id cluster comment } alertmanagers { name upstream { name isHealthy } } } }"}`,
+			code: 200,
+			resp: `{"data":{"alerts":[{"fingerprint":"6136a06cbc37e97b","state":"suppressed","job":"node_exporter","foo":null,"silences":[{"id":"810ccf7f-c957-474a-b383-7e76d66a4d3b","cluster":"default","comment":"Silenced instance"}],"alertmanagers":[{"name":"default","upstream":{"name":"default","isHealthy":true}}]}]}}`,
+		},
+		{
+			name: "alert groups with variables",
+			body: `{"query":"query($f: [String!]) { alertGroups(filters: $f) { receiver labels { name value } stateCount { state count } alerts { annotations { name value } } } }","variables":{"f":["alertname=Memory_Usage_Too_High","@receiver=by-name"]}}`,
+			code: 200,
+			resp: `{"data":{"alertGroups":[{"receiver":"by-name","labels":[{"name":"alertname","value":"Memory_Usage_Too_High"}],"stateCount":[{"state":"unprocessed","count":0},{"state":"active","count":1},{"state":"suppressed","count":0}],"alerts":[{"annotations":[{"name":"alert","value":"Memory usage exceeding threshold"},{"name":"dashboard","value":"http://localhost/dashboard.html"}]}]}]}}`,
+		},
+		{
+			name: "silences and upstreams",
+			body: `{"query":"{ silences(searchTerm: \"web1\") { id matchers { name value isRegex isEqual } } upstreams { name cluster uri clusterMembers } }"}`,
+			code: 200,
+			resp: `{"data":{"silences":[{"id":"810ccf7f-c957-474a-b383-7e76d66a4d3b","matchers":[{"name":"instance","value":"web1","isRegex":false,"isEqual":true}]}],"upstreams":[{"name":"default","cluster":"default","uri":"http://localhost","clusterMembers":["default"]}]}}`,
+		},
+		{
+			name: "silences with alert counts",
+			body: `{"query":"{ silences { id alertCount } alerts(filters: [\"instance=web1\", \"@receiver=by-name\"]) { silences { id alertCount } } }"}`,
+			code: 200,
+			resp: `{"data":{"silences":[{"id":"810ccf7f-c957-474a-b383-7e76d66a4d3b","alertCount":2},{"id":"9bd58938-25fd-41c5-aba3-9bc373074484","alertCount":2},{"id":"dcb3b5d0-9f10-4baa-977a-70073a1899bd","alertCount":6}],"alerts":[{"silences":[{"id":"810ccf7f-c957-474a-b383-7e76d66a4d3b","alertCount":2}]}]}}`,
+		},
+		{
+			name: "silences from unknown cluster",
+			body: `{"query":"{ silences(cluster: [\"foo\"], expired: true) { id } }"}`,
+			code: 200,
+			resp: `{"data":{"silences":[]}}`,
+		},
+		{
+			name: "invalid filter",
+			body: `{"query":"{ alerts(filters: [\"foo=~(\"]) { fingerprint } }"}`,
+			code: 200,
+			resp: "{\"errors\":[{\"message\":\"invalid filter \\\"foo=~(\\\": invalid regular expression \\\"(\\\": error parsing regexp: missing closing ): `(?i)(`\",\"path\":[\"alerts\"]}]}",
+		},
+		{
+			name: "unknown field",
+			body: `{"query":"{ foo }"}`,
+			code: 200,
+			resp: `{"errors":[{"message":"Cannot query field \"foo\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			name: "query too long",
+			body: `{"query":"{ alerts { fingerprint ` + strings.Repeat(" ", 8192) + `} }"}`,
+			code: 200,
+			resp: `{"errors":[{"message":"query length 8218 exceeds the maximum allowed query length of 8192 bytes"}]}`,
+		},
+		{
+			name: "query too deep",
+			body: `{"query":"{ __schema { types { fields { type { ofType { ofType { ofType { ofType { name } } } } } } } } }"}`,
+			code: 200,
+			resp: `{"errors":[{"message":"Field \"name\" has depth 9 that exceeds max depth 8","locations":[{"line":1,"column":74}]}]}`,
+		},
+		{
+			name: "invalid body",
+			body: `foo`,
+			code: 400,
+			resp: `{"error":"jsontext: invalid character 'o' in literal false (expecting 'a') after offset 1"}`,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	config.Config.GraphQL.Enabled = true
+	defer func() {
+		config.Config.GraphQL.Enabled = false
+	}()
+	r := testRouter()
+	setupRouter(r, nil)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(tc.body))
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("POST /graphql returned status %d, %d expected", resp.Code, tc.code)
+			}
+			if diff := cmp.Diff(tc.resp, resp.Body.String()); diff != "" {
+				t.Errorf("Incorrect response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraphQLRootFieldsLimit(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	config.Config.GraphQL.Enabled = true
+	defer func() {
+		config.Config.GraphQL.Enabled = false
+	}()
+	r := testRouter()
+	setupRouter(r, nil)
+
+	for _, fields := range []int{graphQLMaxRootFields, graphQLMaxRootFields + 1} {
+		var query strings.Builder
+		for i := range fields {
+			fmt.Fprintf(&query, `a%d: alerts(filters: [\"foo=bar\"]) { fingerprint } `, i)
+		}
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ `+query.String()+`}"}`))
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != 200 {
+			t.Errorf("POST /graphql with %d fields returned status %d, 200 expected", fields, resp.Code)
+		}
+		rejected := strings.Contains(resp.Body.String(), `"message":"query can't use more than 10 alerts, alertGroups or silences fields"`)
+		if rejected != (fields > graphQLMaxRootFields) {
+			t.Errorf("POST /graphql with %d fields returned %s", fields, resp.Body.String())
+		}
+	}
+}
+
+func TestGraphQLDisabled(t *testing.T) {
+	mockConfig(t.Setenv)
+	r := testRouter()
+	setupRouter(r, nil)
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ upstreams { name } }"}`))
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 404 && resp.Code != 405 {
+		t.Errorf("POST /graphql returned status %d with GraphQL disabled", resp.Code)
+	}
+}
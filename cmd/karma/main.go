@@ -156,14 +156,46 @@ func setupRouter(router *chi.Mux, historyPoller *historyPoller) {
 	}))
 	router.Post(getViewURL("/alerts.json"), alerts)
 	router.Get(getViewURL("/alertList.json"), alertList)
+	router.Get(getViewURL("/alerts/export.csv"), alertExportHandler("csv"))
+	router.Get(getViewURL("/alerts/export.tsv"), alertExportHandler("tsv"))
+	router.Get(getViewURL("/alerts/export.ndjson"), alertExportHandler("ndjson"))
+	router.Get(getViewURL("/filters/explain.json"), filterExplain)
 	router.Get(getViewURL("/autocomplete.json"), autocomplete)
 	router.Get(getViewURL("/labelNames.json"), knownLabelNames)
 	router.Get(getViewURL("/labelValues.json"), knownLabelValues)
 	router.Get(getViewURL("/silences.json"), silences)
+	router.Post(getViewURL("/silences/preview.json"), silencePreview)
+	router.Get(getViewURL("/silences/analysis.json"), silenceAnalysis)
+	router.Get(getViewURL("/silences/export.json"), silenceExportHandler("json"))
+	router.Get(getViewURL("/silences/export.yaml"), silenceExportHandler("yaml"))
+	router.Post(getViewURL("/silences/import.json"), silenceImport)
+	router.Get(getViewURL("/silences/extend"), silenceExtendLinkConfirm)
+	router.Post(getViewURL("/silences/extend"), silenceExtendLink)
 	router.Get(getViewURL("/counters.json"), counters)
 	router.Post(getViewURL("/history.json"), func(w http.ResponseWriter, r *http.Request) {
 		alertHistory(historyPoller, w, r)
 	})
+	router.Get(getViewURL("/history/top.json"), func(w http.ResponseWriter, r *http.Request) {
+		alertHistoryTop(historyPoller, w, r)
+	})
+	router.Get(getViewURL("/history/knownBad.json"), func(w http.ResponseWriter, r *http.Request) {
+		alertHistoryKnownBad(historyPoller, w, r)
+	})
+	router.Get(getViewURL("/history/lifetime.json"), alertHistoryLifetime)
+	router.Get(getViewURL("/presets.json"), listFilterPresets)
+	router.Post(getViewURL("/presets.json"), saveFilterPreset)
+	router.Delete(getViewURL("/presets.json"), deleteFilterPreset)
+	router.Get(getViewURL("/silenceSchedules.json"), listSilenceSchedules)
+	router.Post(getViewURL("/silenceSchedules.json"), saveSilenceSchedule)
+	router.Delete(getViewURL("/silenceSchedules.json"), deleteSilenceSchedule)
+	router.Post(getViewURL("/api/silences"), createSilence)
+	router.Post(getViewURL("/api/silences/expire"), bulkSilenceHandler(silenceBulkExpire))
+	router.Post(getViewURL("/api/silences/extend"), bulkSilenceHandler(silenceBulkExtend))
+	router.Post(getViewURL("/api/silences/edit"), bulkSilenceHandler(silenceBulkEdit))
+	router.Route(getViewURL("/api/v1"), setupAPIv1Router)
+	if config.Config.GraphQL.Enabled {
+		router.Post(getViewURL("/graphql"), graphQLHandler)
+	}
 
 	router.Get(getViewURL("/custom.css"), serveFileOr404(config.Config.Custom.CSS, "text/css"))
 	router.Get(getViewURL("/custom.js"), serveFileOr404(config.Config.Custom.JS, "application/javascript"))
@@ -334,6 +366,31 @@ func mainSetup(errorHandling pflag.ErrorHandling) (*chi.Mux, *historyPoller, err
 		slog.Info("Parsed ACL rules", slog.Int("rules", len(silenceACLs)))
 	}
 
+	silenceSync, err = newSilenceSyncer(config.Config.Silences.Sync.Groups, config.Config.Silences.Sync.Path, alertmanager.GetAlertmanagers())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	silenceReminders, err = newSilenceReminder(config.Config.Silences.Reminders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	presetStore, err = newFilterPresetStore(config.Config.Filters.Presets.Path, config.Config.Filters.Presets.Static)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scheduleStore, err = newSilenceScheduleStore(config.Config.Silences.Schedules.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alertHistoryRecorder, err = newHistoryRecorder(config.Config.History.Recorder.Enabled, config.Config.History.Recorder.Path, config.Config.History.MaxRange)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	indexTemplate, _ = template.ParseFS(ui.StaticFiles, "dist/index.html")
 
 	router := chi.NewRouter()
@@ -399,6 +456,9 @@ func serve(errorHandling pflag.ErrorHandling) error {
 	ticker = time.NewTicker(config.Config.Alertmanager.Interval)
 	go Tick()
 
+	// background loop that will create silences for all schedules
+	go newSilenceScheduler(scheduleStore, config.Config.Silences.Schedules.Lead).run(time.NewTicker(time.Minute))
+
 	listen := fmt.Sprintf("%s:%d", config.Config.Listen.Address, config.Config.Listen.Port)
 	listener, err := net.Listen("tcp", listen)
 	if err != nil {
@@ -453,7 +513,12 @@ func serve(errorHandling pflag.ErrorHandling) error {
 
 func main() {
 	_ = log.SetupLogger("text", false)
-	err := serve(pflag.ExitOnError)
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "silences" {
+		err = silencesCommand(os.Args[2:], os.Stdout, pflag.ExitOnError)
+	} else {
+		err = serve(pflag.ExitOnError)
+	}
 	if err != nil {
 		slog.Error("Execution failed", slog.Any("error", err))
 		os.Exit(1)
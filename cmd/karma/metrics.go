@@ -16,6 +16,8 @@ type karmaCollector struct {
 	errorsTotal     *prometheus.Desc
 	alertmanagerUp  *prometheus.Desc
 	goMaxProcs      *prometheus.Desc
+	silencesOrphan  *prometheus.Desc
+	silenceConflict *prometheus.Desc
 }
 
 func newKarmaCollector() *karmaCollector {
@@ -62,6 +64,18 @@ func newKarmaCollector() *karmaCollector {
 			[]string{},
 			prometheus.Labels{},
 		),
+		silencesOrphan: prometheus.NewDesc(
+			"karma_silences_orphaned_count",
+			"Number of active silences that don't match any alert",
+			[]string{"cluster"},
+			prometheus.Labels{},
+		),
+		silenceConflict: prometheus.NewDesc(
+			"karma_silence_conflicts_count",
+			"Number of silence pairs that are duplicated, redundant or silence the same alerts",
+			[]string{"cluster", "kind"},
+			prometheus.Labels{},
+		),
 	}
 }
 
@@ -73,6 +87,8 @@ func (c *karmaCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.errorsTotal
 	ch <- c.alertmanagerUp
 	ch <- c.goMaxProcs
+	ch <- c.silencesOrphan
+	ch <- c.silenceConflict
 }
 
 func (c *karmaCollector) Collect(ch chan<- prometheus.Metric) {
@@ -155,6 +171,48 @@ func (c *karmaCollector) Collect(ch chan<- prometheus.Metric) {
 		)
 	}
 
+	// cluster -> count
+	orphaned := map[string]float64{}
+	// cluster -> kind -> count
+	conflicts := map[string]map[string]float64{}
+	for _, am := range upstreams {
+		orphaned[am.Cluster] = 0
+		conflicts[am.Cluster] = map[string]float64{}
+		for _, kind := range allSilenceConflictKinds {
+			conflicts[am.Cluster][kind] = 0
+		}
+	}
+	if analysis := lastSilenceAnalysis.Load(); analysis != nil {
+		for _, ms := range analysis.Orphaned {
+			orphaned[ms.Cluster]++
+		}
+		for _, conflict := range analysis.Conflicts {
+			if _, ok := conflicts[conflict.Cluster]; !ok {
+				conflicts[conflict.Cluster] = map[string]float64{}
+			}
+			conflicts[conflict.Cluster][conflict.Kind]++
+		}
+	}
+	for cluster, count := range orphaned {
+		ch <- prometheus.MustNewConstMetric(
+			c.silencesOrphan,
+			prometheus.GaugeValue,
+			count,
+			cluster,
+		)
+	}
+	for cluster, byKind := range conflicts {
+		for kind, count := range byKind {
+			ch <- prometheus.MustNewConstMetric(
+				c.silenceConflict,
+				prometheus.GaugeValue,
+				count,
+				cluster,
+				kind,
+			)
+		}
+	}
+
 	ch <- prometheus.MustNewConstMetric(
 		c.goMaxProcs,
 		prometheus.GaugeValue,
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPISchemas generates OpenAPI schemas from Go types, named structs are
+// added to components and referenced, everything else is inlined
+type openAPISchemas struct {
+	components map[string]any
+}
+
+func (s *openAPISchemas) schema(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		// instances of generic types have names like APIv1List[main.APIv1Alert]
+		// which are not valid component names
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return s.object(t)
+		}
+		if _, ok := s.components[t.Name()]; !ok {
+			// reserve the name first to handle recursive types
+			s.components[t.Name()] = nil
+			s.components[t.Name()] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func (s *openAPISchemas) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = s.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+func (s *openAPISchemas) param(p apiV1Param) map[string]any {
+	var schema map[string]any
+	switch p.kind {
+	case reflect.Int:
+		schema = map[string]any{"type": "integer"}
+	case reflect.Bool:
+		schema = map[string]any{"type": "boolean"}
+	default:
+		schema = map[string]any{"type": "string"}
+	}
+	if p.repeated {
+		schema = map[string]any{"type": "array", "items": schema}
+	}
+	return map[string]any{
+		"name":        p.name,
+		"in":          "query",
+		"description": p.description,
+		"required":    false,
+		"schema":      schema,
+	}
+}
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+// openAPIDocument returns OpenAPI document describing all /api/v1/ routes
+func openAPIDocument(routes []apiV1Route) map[string]any {
+	s := openAPISchemas{components: map[string]any{}}
+	errorSchema := s.schema(reflect.TypeFor[APIv1ErrorResponse]())
+
+	paths := map[string]any{}
+	for _, route := range routes {
+		params := make([]map[string]any, 0, len(route.params))
+		for _, p := range route.params {
+			params = append(params, s.param(p))
+		}
+		paths[route.path] = map[string]any{
+			"get": map[string]any{
+				"operationId": strings.TrimPrefix(route.path, "/"),
+				"summary":     route.summary,
+				"parameters":  params,
+				"responses": map[string]any{
+					"200": jsonResponse("Success", s.schema(route.response)),
+					"400": jsonResponse("Invalid request", errorSchema),
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "karma",
+			"version": "v1",
+		},
+		"servers": []map[string]any{
+			{"url": getViewURL("/api/v1")},
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": s.components,
+		},
+	}
+}
+
+func apiV1OpenAPI(w http.ResponseWriter, _ *http.Request) {
+	apiV1Response(w, openAPIDocument(apiV1Routes))
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+
+	jsonv2 "github.com/go-json-experiment/json"
+
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/filters"
+)
+
+var (
+	errPresetNotFound = errors.New("filter preset not found")
+	errPresetReadOnly = errors.New("filter preset is defined in karma configuration and cannot be modified")
+
+	presetStore *filterPresetStore
+)
+
+type FilterPreset struct {
+	Name     string   `json:"name"`
+	Owner    string   `json:"owner"`
+	Filters  []string `json:"filters"`
+	Groups   []string `json:"groups"`
+	ReadOnly bool     `json:"readonly"`
+}
+
+func (fp FilterPreset) isVisible(username string, groups []string) bool {
+	if !fp.ReadOnly && fp.Owner == username {
+		return true
+	}
+	if fp.ReadOnly && len(fp.Groups) == 0 {
+		return true
+	}
+	for _, group := range fp.Groups {
+		if slices.Contains(groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePresetFilters(name string, filterStrings []string) error {
+	if len(filterStrings) == 0 {
+		return fmt.Errorf("filter preset %q has no filters", name)
+	}
+	for _, filterExpression := range filterStrings {
+		if f := filters.NewFilter(filterExpression); !f.Valid() {
+			return fmt.Errorf("filter preset %q contains invalid filter %q", name, filterExpression)
+		}
+	}
+	return nil
+}
+
+// filterPresetStore holds all filter presets, both read-only ones defined in
+// karma configuration and those created by users. User presets are persisted
+// to a file if a path was configured.
+type filterPresetStore struct {
+	path   string
+	static []FilterPreset
+	user   []FilterPreset
+	lock   sync.RWMutex
+}
+
+func newFilterPresetStore(path string, static []config.FilterPreset) (*filterPresetStore, error) {
+	store := filterPresetStore{
+		path:   path,
+		static: make([]FilterPreset, 0, len(static)),
+		user:   []FilterPreset{},
+	}
+
+	for _, cfg := range static {
+		if err := validatePresetFilters(cfg.Name, cfg.Filters); err != nil {
+			return nil, err
+		}
+		store.static = append(store.static, FilterPreset{
+			Name:     cfg.Name,
+			Filters:  cfg.Filters,
+			Groups:   cfg.Groups,
+			ReadOnly: true,
+		})
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			slog.Info("Filter presets file doesn't exist yet", slog.String("path", path))
+		case err != nil:
+			return nil, fmt.Errorf("failed to read filter presets file %q: %w", path, err)
+		default:
+			err = jsonv2.Unmarshal(data, &store.user)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse filter presets file %q: %w", path, err)
+			}
+			slog.Info("Loaded filter presets", slog.String("path", path), slog.Int("presets", len(store.user)))
+		}
+	}
+
+	return &store, nil
+}
+
+// persist writes all user presets to disk, caller must hold the lock
+func (s *filterPresetStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	if err := writeJSONFile(s.path, s.user); err != nil {
+		return fmt.Errorf("failed to write filter presets file: %w", err)
+	}
+	return nil
+}
+
+func (s *filterPresetStore) list(username string, groups []string) []FilterPreset {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	presets := []FilterPreset{}
+	for _, fp := range s.static {
+		if fp.isVisible(username, groups) {
+			presets = append(presets, fp)
+		}
+	}
+	for _, fp := range s.user {
+		if fp.isVisible(username, groups) {
+			presets = append(presets, fp)
+		}
+	}
+
+	sort.SliceStable(presets, func(i, j int) bool {
+		if presets[i].Name == presets[j].Name {
+			return presets[i].Owner < presets[j].Owner
+		}
+		return presets[i].Name < presets[j].Name
+	})
+	return presets
+}
+
+func (s *filterPresetStore) save(preset FilterPreset) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, fp := range s.static {
+		if fp.Name == preset.Name {
+			return errPresetReadOnly
+		}
+	}
+
+	user := slices.Clone(s.user)
+	idx := slices.IndexFunc(user, func(fp FilterPreset) bool {
+		return fp.Name == preset.Name && fp.Owner == preset.Owner
+	})
+	if idx >= 0 {
+		user[idx] = preset
+	} else {
+		user = append(user, preset)
+	}
+
+	previous := s.user
+	s.user = user
+	if err := s.persist(); err != nil {
+		s.user = previous
+		return err
+	}
+	return nil
+}
+
+func (s *filterPresetStore) delete(name, owner string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, fp := range s.static {
+		if fp.Name == name {
+			return errPresetReadOnly
+		}
+	}
+
+	idx := slices.IndexFunc(s.user, func(fp FilterPreset) bool {
+		return fp.Name == name && fp.Owner == owner
+	})
+	if idx < 0 {
+		return errPresetNotFound
+	}
+
+	previous := s.user
+	s.user = slices.Delete(slices.Clone(s.user), idx, idx+1)
+	if err := s.persist(); err != nil {
+		s.user = previous
+		return err
+	}
+	return nil
+}
+
+func presetRequestAuth(r *http.Request) (string, []string) {
+	if !config.Config.Authentication.Enabled {
+		return "", []string{}
+	}
+	return getUserFromContext(r), getGroupsFromContext(r)
+}
+
+func writePresetStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errPresetNotFound):
+		errorJSON(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errPresetReadOnly):
+		badRequestJSON(w, err.Error())
+	default:
+		slog.Error("Failed to update filter presets", slog.Any("error", err))
+		errorJSON(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func listFilterPresets(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	username, groups := presetRequestAuth(r)
+
+	data, _ := marshalJSON(presetStore.list(username, groups))
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func saveFilterPreset(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	var preset FilterPreset
+	err := jsonv2.UnmarshalRead(r.Body, &preset)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	if preset.Name == "" {
+		badRequestJSON(w, "filter preset name is required")
+		return
+	}
+	if err = validatePresetFilters(preset.Name, preset.Filters); err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	username, groups := presetRequestAuth(r)
+	if preset.Groups == nil {
+		preset.Groups = []string{}
+	}
+	if config.Config.Authentication.Enabled {
+		for _, group := range preset.Groups {
+			if !slices.Contains(groups, group) {
+				badRequestJSON(w, fmt.Sprintf("filter preset cannot be shared with group %q, user %q is not a member", group, username))
+				return
+			}
+		}
+	}
+	preset.Owner = username
+	preset.ReadOnly = false
+
+	if err = presetStore.save(preset); err != nil {
+		writePresetStoreError(w, err)
+		return
+	}
+
+	data, _ := marshalJSON(preset)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func deleteFilterPreset(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	name, found := lookupQueryString(r, "name")
+	if !found || name == "" {
+		badRequestJSON(w, "missing name=<preset> parameter")
+		return
+	}
+
+	username, _ := presetRequestAuth(r)
+	if err := presetStore.delete(name, username); err != nil {
+		writePresetStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/prymitive/karma/internal/config"
+)
+
+func presetRequest(method, uri, body, user string, groups []string) *http.Request {
+	req := httptest.NewRequest(method, uri, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), authUserKey("user"), user)
+	ctx = context.WithValue(ctx, authUserKey("groups"), groups)
+	return req.WithContext(ctx)
+}
+
+func TestFilterPresetsStatic(t *testing.T) {
+	_, err := newFilterPresetStore("", []config.FilterPreset{
+		{Name: "bad", Filters: []string{"@state=foo"}},
+	})
+	if err == nil {
+		t.Errorf("newFilterPresetStore() didn't return any error for invalid filters")
+	}
+}
+
+func TestFilterPresets(t *testing.T) {
+	type requestT struct {
+		method string
+		uri    string
+		body   string
+		user   string
+		groups []string
+		code   int
+		resp   string
+	}
+
+	type testCaseT struct {
+		name     string
+		auth     bool
+		requests []requestT
+	}
+
+	static := []config.FilterPreset{
+		{Name: "active", Filters: []string{"@state=active"}},
+		{Name: "db", Filters: []string{"team=db"}, Groups: []string{"dba"}},
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "auth disabled, static presets are visible",
+			requests: []requestT{
+				{
+					method: "GET", uri: "/presets.json", code: 200,
+					resp: `[{"name":"active","owner":"","filters":["@state=active"],"groups":[],"readonly":true}]`,
+				},
+			},
+		},
+		{
+			name: "auth disabled, save and delete",
+			requests: []requestT{
+				{
+					method: "POST", uri: "/presets.json", code: 200,
+					body: `{"name":"mine","filters":["cluster=prod"]}`,
+					resp: `{"name":"mine","owner":"","filters":["cluster=prod"],"groups":[],"readonly":false}`,
+				},
+				{
+					method: "GET", uri: "/presets.json", code: 200,
+					resp: `[{"name":"active","owner":"","filters":["@state=active"],"groups":[],"readonly":true},{"name":"mine","owner":"","filters":["cluster=prod"],"groups":[],"readonly":false}]`,
+				},
+				{method: "DELETE", uri: "/presets.json?name=mine", code: 200},
+				{
+					method: "DELETE", uri: "/presets.json?name=mine", code: 404,
+					resp: `{"error":"filter preset not found"}`,
+				},
+				{
+					method: "DELETE", uri: "/presets.json?name=active", code: 400,
+					resp: `{"error":"filter preset is defined in karma configuration and cannot be modified"}`,
+				},
+			},
+		},
+		{
+			name: "invalid requests",
+			requests: []requestT{
+				{method: "POST", uri: "/presets.json", body: `{"name":"mine"}`, code: 400},
+				{method: "POST", uri: "/presets.json", body: `{"filters":["foo=bar"]}`, code: 400},
+				{method: "POST", uri: "/presets.json", body: `{"name":"mine","filters":["@state=bar"]}`, code: 400},
+				{method: "POST", uri: "/presets.json", body: `{"name":"active","filters":["foo=bar"]}`, code: 400},
+				{method: "POST", uri: "/presets.json", body: `{"name":`, code: 400},
+				{method: "DELETE", uri: "/presets.json", code: 400},
+				{method: "DELETE", uri: "/presets.json?name=active", code: 400},
+			},
+		},
+		{
+			name: "auth enabled, presets are scoped per user and group",
+			auth: true,
+			requests: []requestT{
+				{
+					method: "POST", uri: "/presets.json", user: "alice", groups: []string{"dba"}, code: 200,
+					body: `{"name":"shared","filters":["team=db"],"groups":["dba"]}`,
+					resp: `{"name":"shared","owner":"alice","filters":["team=db"],"groups":["dba"],"readonly":false}`,
+				},
+				{
+					method: "POST", uri: "/presets.json", user: "alice", groups: []string{"dba"}, code: 200,
+					body: `{"name":"private","filters":["team=db"]}`,
+					resp: `{"name":"private","owner":"alice","filters":["team=db"],"groups":[],"readonly":false}`,
+				},
+				{
+					method: "POST", uri: "/presets.json", user: "alice", groups: []string{"dba"}, code: 400,
+					body: `{"name":"other","filters":["team=db"],"groups":["admins"]}`,
+				},
+				{
+					method: "GET", uri: "/presets.json", user: "bob", groups: []string{"dba"}, code: 200,
+					resp: `[{"name":"active","owner":"","filters":["@state=active"],"groups":[],"readonly":true},{"name":"db","owner":"","filters":["team=db"],"groups":["dba"],"readonly":true},{"name":"shared","owner":"alice","filters":["team=db"],"groups":["dba"],"readonly":false}]`,
+				},
+				{
+					method: "GET", uri: "/presets.json", user: "carol", groups: []string{}, code: 200,
+					resp: `[{"name":"active","owner":"","filters":["@state=active"],"groups":[],"readonly":true}]`,
+				},
+				{
+					method: "DELETE", uri: "/presets.json?name=shared", user: "bob", groups: []string{"dba"}, code: 404,
+					resp: `{"error":"filter preset not found"}`,
+				},
+				{method: "DELETE", uri: "/presets.json?name=shared", user: "alice", groups: []string{"dba"}, code: 200},
+				{
+					method: "GET", uri: "/presets.json", user: "alice", groups: []string{}, code: 200,
+					resp: `[{"name":"active","owner":"","filters":["@state=active"],"groups":[],"readonly":true},{"name":"private","owner":"alice","filters":["team=db"],"groups":[],"readonly":false}]`,
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mockConfig(t.Setenv)
+			config.Config.Authentication.Enabled = testCase.auth
+			defer func() {
+				config.Config.Authentication.Enabled = false
+			}()
+
+			var err error
+			presetStore, err = newFilterPresetStore("", static)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, rt := range testCase.requests {
+				req := presetRequest(rt.method, rt.uri, rt.body, rt.user, rt.groups)
+				resp := httptest.NewRecorder()
+				switch rt.method {
+				case "GET":
+					listFilterPresets(resp, req)
+				case "POST":
+					saveFilterPreset(resp, req)
+				case "DELETE":
+					deleteFilterPreset(resp, req)
+				}
+				if resp.Code != rt.code {
+					t.Errorf("%s %s returned status %d, expected %d: %s", rt.method, rt.uri, resp.Code, rt.code, resp.Body.String())
+				}
+				if rt.resp != "" {
+					if diff := cmp.Diff(rt.resp, strings.TrimSpace(resp.Body.String())); diff != "" {
+						t.Errorf("Wrong response for %s %s (-want +got):\n%s", rt.method, rt.uri, diff)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFilterPresetsPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.json")
+
+	store, err := newFilterPresetStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.save(FilterPreset{Name: "mine", Owner: "bob", Filters: []string{"foo=bar"}, Groups: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = newFilterPresetStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FilterPreset{{Name: "mine", Owner: "bob", Filters: []string{"foo=bar"}, Groups: []string{}}}
+	if diff := cmp.Diff(expected, store.list("bob", nil)); diff != "" {
+		t.Errorf("Wrong presets loaded from file (-want +got):\n%s", diff)
+	}
+
+	if err = store.delete("mine", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	store, err = newFilterPresetStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]FilterPreset{}, store.list("bob", nil)); diff != "" {
+		t.Errorf("Wrong presets loaded from file (-want +got):\n%s", diff)
+	}
+
+	if err = os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newFilterPresetStore(path, nil); err == nil {
+		t.Errorf("newFilterPresetStore() didn't return any error for a corrupted file")
+	}
+}
+
+func TestFilterPresetsWriteError(t *testing.T) {
+	var err error
+	presetStore, err = newFilterPresetStore(filepath.Join(t.TempDir(), "missing", "presets.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := presetRequest("POST", "/presets.json", `{"name":"mine","filters":["foo=bar"]}`, "", nil)
+	resp := httptest.NewRecorder()
+	saveFilterPreset(resp, req)
+	if resp.Code != http.StatusInternalServerError {
+		t.Errorf("POST /presets.json returned status %d, expected %d", resp.Code, http.StatusInternalServerError)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("POST /presets.json returned Content-Type %q, expected application/json", ct)
+	}
+	if !strings.HasPrefix(resp.Body.String(), `{"error":"`) {
+		t.Errorf("POST /presets.json returned non-JSON error: %s", resp.Body.String())
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	jsonv2 "github.com/go-json-experiment/json"
 
 	"github.com/prymitive/karma/internal/alertmanager"
 	"github.com/prymitive/karma/internal/config"
@@ -74,6 +75,25 @@ func NewAlertmanagerProxy(alertmanager *alertmanager.Alertmanager) *httputil.Rev
 			// drop Content-Length header from upstream responses, gzip middleware
 			// will compress those and that could cause a mismatch
 			resp.Header.Del("Content-Length")
+
+			// track silences created via the proxy so they can be synchronised
+			// with other clusters and their authors can be reminded before
+			// they expire
+			if resp.Request != nil && resp.Request.Method == http.MethodPost && resp.StatusCode == http.StatusOK && isTrackingCreatedSilences(alertmanager.Cluster) {
+				body, err := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if err != nil {
+					return err
+				}
+				resp.Body = io.NopCloser(bytes.NewBuffer(body))
+
+				var created struct {
+					SilenceID string `json:"silenceID"`
+				}
+				if err = jsonv2.Unmarshal(body, &created); err == nil {
+					trackCreatedSilence(alertmanager.Cluster, created.SilenceID)
+				}
+			}
 			return nil
 		},
 	}
@@ -112,7 +132,7 @@ func handlePostRequest(alertmanager *alertmanager.Alertmanager, h http.Handler)
 			return
 		}
 
-		if len(silenceACLs) > 0 {
+		if len(silenceACLs) > 0 || len(config.Config.SilenceForm.Templates) > 0 {
 			silence, err := m.Unmarshal(body)
 			if err != nil {
 				slog.Error(
@@ -126,24 +146,28 @@ func handlePostRequest(alertmanager *alertmanager.Alertmanager, h http.Handler)
 				return
 			}
 
-			for i, acl := range silenceACLs {
-				groups := getGroupsFromContext(r)
-				isAllowed, err := acl.isAllowed(alertmanager.Name, silence, groups)
-				slog.Debug("ACL rule check", slog.Int("index", i), slog.Bool("allowed", isAllowed), slog.Any("error", err))
-				if err != nil {
-					slog.Warn(
-						"Proxy request was blocked by ACL rule",
-						slog.Any("error", err),
-						slog.String("alertmanager", alertmanager.Name),
-						slog.String("method", r.Method),
-						slog.String("uri", r.RequestURI),
-					)
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return
-				}
-				if isAllowed {
-					break
-				}
+			if err = checkSilenceTemplates(silence); err != nil {
+				slog.Warn(
+					"Proxy request is missing fields required by silence template",
+					slog.Any("error", err),
+					slog.String("alertmanager", alertmanager.Name),
+					slog.String("method", r.Method),
+					slog.String("uri", r.RequestURI),
+				)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err = checkSilenceACLs(alertmanager.Name, silence, getGroupsFromContext(r)); err != nil {
+				slog.Warn(
+					"Proxy request was blocked by ACL rule",
+					slog.Any("error", err),
+					slog.String("alertmanager", alertmanager.Name),
+					slog.String("method", r.Method),
+					slog.String("uri", r.RequestURI),
+				)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
 		}
 
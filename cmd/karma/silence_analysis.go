@@ -0,0 +1,229 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/models"
+)
+
+const (
+	// both silences have the same set of matchers
+	silenceConflictDuplicate = "duplicate"
+	// matchers of the second silence are a subset of the first silence
+	// matchers, so the first silence is redundant since the second one
+	// matches every alert it matches
+	silenceConflictSubset = "subset"
+	// silences have different matchers but both silence some of the same alerts
+	silenceConflictOverlap = "overlap"
+)
+
+var allSilenceConflictKinds = []string{silenceConflictDuplicate, silenceConflictSubset, silenceConflictOverlap}
+
+type SilenceConflict struct {
+	Cluster    string   `json:"cluster"`
+	Kind       string   `json:"kind"`
+	Silences   []string `json:"silences"`
+	AlertCount int      `json:"alertCount"`
+}
+
+type SilenceAnalysis struct {
+	Conflicts []SilenceConflict       `json:"conflicts"`
+	Orphaned  []models.ManagedSilence `json:"orphaned"`
+}
+
+type silenceMatcherKey struct {
+	name    string
+	value   string
+	isRegex bool
+	isEqual bool
+}
+
+func silenceMatcherSet(silence models.Silence) map[silenceMatcherKey]struct{} {
+	set := make(map[silenceMatcherKey]struct{}, len(silence.Matchers))
+	for _, m := range silence.Matchers {
+		set[silenceMatcherKey{name: m.Name, value: m.Value, isRegex: m.IsRegex, isEqual: m.IsEqual}] = struct{}{}
+	}
+	return set
+}
+
+// isMatcherSubset returns true if every matcher from a is also in b
+func isMatcherSubset(a, b map[silenceMatcherKey]struct{}) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func silencesOverlapInTime(a, b models.Silence) bool {
+	return a.StartsAt.Before(b.EndsAt) && b.StartsAt.Before(a.EndsAt)
+}
+
+type silencePair struct {
+	cluster string
+	a, b    string
+}
+
+func newSilencePair(cluster, a, b string) silencePair {
+	if a > b {
+		a, b = b, a
+	}
+	return silencePair{cluster: cluster, a: a, b: b}
+}
+
+// analyzeSilences finds silences that are duplicated, fully covered by another
+// silence or silence the same alerts as another silence, it also returns all
+// started silences that don't match any alert
+// AlertCount must be already set on all silences.
+func analyzeSilences(dedupedSilences []models.ManagedSilence, alertGroups []models.AlertGroup, now time.Time) SilenceAnalysis {
+	analysis := SilenceAnalysis{
+		Conflicts: []SilenceConflict{},
+		Orphaned:  []models.ManagedSilence{},
+	}
+
+	byCluster := map[string][]models.ManagedSilence{}
+	active := map[string]struct{}{}
+	for _, ms := range dedupedSilences {
+		if ms.IsExpired {
+			continue
+		}
+		byCluster[ms.Cluster] = append(byCluster[ms.Cluster], ms)
+		active[ms.Silence.ID] = struct{}{}
+		if ms.AlertCount == 0 && !ms.Silence.StartsAt.After(now) {
+			analysis.Orphaned = append(analysis.Orphaned, ms)
+		}
+	}
+
+	// count alerts silenced by each pair of silences, alerts can also
+	// reference recently expired silences, those are ignored
+	sharedAlerts := map[silencePair]int{}
+	for _, ag := range alertGroups {
+		for _, alert := range ag.Alerts {
+			pairDone := map[silencePair]struct{}{}
+			for _, am := range alert.Alertmanager {
+				for i, a := range am.SilencedBy {
+					for _, b := range am.SilencedBy[i+1:] {
+						if a == b {
+							continue
+						}
+						if _, ok := active[a]; !ok {
+							continue
+						}
+						if _, ok := active[b]; !ok {
+							continue
+						}
+						pair := newSilencePair(am.Cluster, a, b)
+						if _, ok := pairDone[pair]; !ok {
+							sharedAlerts[pair]++
+							pairDone[pair] = struct{}{}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	reported := map[silencePair]struct{}{}
+	for cluster, silences := range byCluster {
+		sort.Slice(silences, func(i, j int) bool {
+			return silences[i].Silence.ID < silences[j].Silence.ID
+		})
+		sets := make([]map[silenceMatcherKey]struct{}, len(silences))
+		for i, ms := range silences {
+			sets[i] = silenceMatcherSet(ms.Silence)
+		}
+		for i := range silences {
+			for j := i + 1; j < len(silences); j++ {
+				if !silencesOverlapInTime(silences[i].Silence, silences[j].Silence) {
+					continue
+				}
+				pair := newSilencePair(cluster, silences[i].Silence.ID, silences[j].Silence.ID)
+				conflict := SilenceConflict{
+					Cluster:    cluster,
+					AlertCount: sharedAlerts[pair],
+				}
+				switch {
+				case len(sets[i]) == len(sets[j]) && isMatcherSubset(sets[i], sets[j]):
+					conflict.Kind = silenceConflictDuplicate
+					conflict.Silences = []string{silences[i].Silence.ID, silences[j].Silence.ID}
+				case isMatcherSubset(sets[i], sets[j]):
+					conflict.Kind = silenceConflictSubset
+					conflict.Silences = []string{silences[j].Silence.ID, silences[i].Silence.ID}
+				case isMatcherSubset(sets[j], sets[i]):
+					conflict.Kind = silenceConflictSubset
+					conflict.Silences = []string{silences[i].Silence.ID, silences[j].Silence.ID}
+				default:
+					continue
+				}
+				analysis.Conflicts = append(analysis.Conflicts, conflict)
+				reported[pair] = struct{}{}
+			}
+		}
+	}
+
+	for pair, count := range sharedAlerts {
+		if _, ok := reported[pair]; ok {
+			continue
+		}
+		analysis.Conflicts = append(analysis.Conflicts, SilenceConflict{
+			Cluster:    pair.cluster,
+			Kind:       silenceConflictOverlap,
+			Silences:   []string{pair.a, pair.b},
+			AlertCount: count,
+		})
+	}
+
+	sort.Slice(analysis.Conflicts, func(i, j int) bool {
+		ci, cj := analysis.Conflicts[i], analysis.Conflicts[j]
+		if ci.Cluster != cj.Cluster {
+			return ci.Cluster < cj.Cluster
+		}
+		if ci.Kind != cj.Kind {
+			return slices.Index(allSilenceConflictKinds, ci.Kind) < slices.Index(allSilenceConflictKinds, cj.Kind)
+		}
+		return slices.Compare(ci.Silences, cj.Silences) < 0
+	})
+	sort.Slice(analysis.Orphaned, func(i, j int) bool {
+		if analysis.Orphaned[i].Cluster != analysis.Orphaned[j].Cluster {
+			return analysis.Orphaned[i].Cluster < analysis.Orphaned[j].Cluster
+		}
+		return analysis.Orphaned[i].Silence.ID < analysis.Orphaned[j].Silence.ID
+	})
+
+	return analysis
+}
+
+func currentSilenceAnalysis() SilenceAnalysis {
+	dedupedSilences := alertmanager.DedupSilences()
+	alertGroups := alertmanager.DedupAlerts()
+	countSilencedAlerts(dedupedSilences, alertGroups)
+	return analyzeSilences(dedupedSilences, alertGroups, time.Now())
+}
+
+// silence analysis is updated after every collection and used for metrics, so
+// we don't need to analyze all silences on every scrape
+var lastSilenceAnalysis atomic.Pointer[SilenceAnalysis]
+
+func updateSilenceAnalysis(dedupedSilences []models.ManagedSilence, alertGroups []models.AlertGroup, now time.Time) {
+	countSilencedAlerts(dedupedSilences, alertGroups)
+	analysis := analyzeSilences(dedupedSilences, alertGroups, now)
+	lastSilenceAnalysis.Store(&analysis)
+}
+
+// report overlapping and orphaned silences
+func silenceAnalysis(w http.ResponseWriter, _ *http.Request) {
+	noCache(w)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	data, _ := marshalJSON(currentSilenceAnalysis())
+	_, _ = w.Write(data)
+}
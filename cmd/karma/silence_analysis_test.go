@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+func TestAnalyzeSilences(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newSilence := func(cluster, id string, startsAt, endsAt time.Time, alertCount int, matchers ...models.SilenceMatcher) models.ManagedSilence {
+		return models.ManagedSilence{
+			Cluster:    cluster,
+			AlertCount: alertCount,
+			IsExpired:  endsAt.Before(now),
+			Silence: models.Silence{
+				ID:       id,
+				StartsAt: startsAt,
+				EndsAt:   endsAt,
+				Matchers: matchers,
+			},
+		}
+	}
+	alertnameFoo := models.NewSilenceMatcher("alertname", "Foo", false, true)
+	instanceA := models.NewSilenceMatcher("instance", "a", false, true)
+	instanceRe := models.NewSilenceMatcher("instance", "a|b", true, true)
+
+	newAlertGroup := func(cluster string, silencedBy ...[]string) models.AlertGroup {
+		ag := models.AlertGroup{}
+		for _, ids := range silencedBy {
+			ag.Alerts = append(ag.Alerts, models.Alert{
+				Alertmanager: []models.AlertmanagerInstance{
+					{Name: cluster + "1", Cluster: cluster, SilencedBy: ids},
+					{Name: cluster + "2", Cluster: cluster, SilencedBy: ids},
+				},
+			})
+		}
+		return ag
+	}
+
+	type testCaseT struct {
+		name     string
+		silences []models.ManagedSilence
+		groups   []models.AlertGroup
+		analysis SilenceAnalysis
+	}
+
+	testCases := []testCaseT{
+		{
+			name:     "no silences",
+			analysis: SilenceAnalysis{Conflicts: []SilenceConflict{}, Orphaned: []models.ManagedSilence{}},
+		},
+		{
+			name: "duplicated silences",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "2", now.Add(-time.Hour), now.Add(time.Hour), 1, instanceA, alertnameFoo),
+				newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 1, alertnameFoo, instanceA),
+				// different cluster
+				newSilence("dev", "3", now.Add(-time.Hour), now.Add(time.Hour), 1, alertnameFoo, instanceA),
+			},
+			groups: []models.AlertGroup{newAlertGroup("prod", []string{"1", "2"})},
+			analysis: SilenceAnalysis{
+				Conflicts: []SilenceConflict{
+					{Cluster: "prod", Kind: silenceConflictDuplicate, Silences: []string{"1", "2"}, AlertCount: 1},
+				},
+				Orphaned: []models.ManagedSilence{},
+			},
+		},
+		{
+			name: "silence covered by a broader silence",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 2, alertnameFoo),
+				newSilence("prod", "2", now.Add(-time.Hour), now.Add(time.Hour), 1, alertnameFoo, instanceA),
+			},
+			groups: []models.AlertGroup{newAlertGroup("prod", []string{"1", "2"}, []string{"1"})},
+			analysis: SilenceAnalysis{
+				Conflicts: []SilenceConflict{
+					{Cluster: "prod", Kind: silenceConflictSubset, Silences: []string{"2", "1"}, AlertCount: 1},
+				},
+				Orphaned: []models.ManagedSilence{},
+			},
+		},
+		{
+			name: "silences not overlapping in time are ignored",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 1, alertnameFoo),
+				newSilence("prod", "2", now.Add(time.Hour), now.Add(time.Hour*2), 0, alertnameFoo),
+			},
+			groups: []models.AlertGroup{newAlertGroup("prod", []string{"1"})},
+			analysis: SilenceAnalysis{
+				Conflicts: []SilenceConflict{},
+				Orphaned:  []models.ManagedSilence{},
+			},
+		},
+		{
+			name: "silences with different matchers silencing the same alerts",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 2, instanceA),
+				newSilence("prod", "2", now.Add(-time.Hour), now.Add(time.Hour), 2, instanceRe),
+			},
+			groups: []models.AlertGroup{newAlertGroup("prod", []string{"1", "2"}, []string{"2", "1"})},
+			analysis: SilenceAnalysis{
+				Conflicts: []SilenceConflict{
+					{Cluster: "prod", Kind: silenceConflictOverlap, Silences: []string{"1", "2"}, AlertCount: 2},
+				},
+				Orphaned: []models.ManagedSilence{},
+			},
+		},
+		{
+			name: "expired silences are ignored",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 1, instanceA),
+				newSilence("prod", "2", now.Add(-time.Hour), now.Add(-time.Minute), 1, instanceA),
+			},
+			groups: []models.AlertGroup{newAlertGroup("prod", []string{"1", "2"})},
+			analysis: SilenceAnalysis{
+				Conflicts: []SilenceConflict{},
+				Orphaned:  []models.ManagedSilence{},
+			},
+		},
+		{
+			name: "orphaned silences",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 0, instanceA),
+				// pending
+				newSilence("prod", "2", now.Add(time.Hour), now.Add(time.Hour*2), 0, alertnameFoo),
+				// expired
+				newSilence("prod", "3", now.Add(-time.Hour), now.Add(-time.Minute), 0, alertnameFoo),
+				newSilence("dev", "4", now.Add(-time.Hour), now.Add(time.Hour), 0, alertnameFoo),
+			},
+			analysis: SilenceAnalysis{
+				Conflicts: []SilenceConflict{},
+				Orphaned: []models.ManagedSilence{
+					newSilence("dev", "4", now.Add(-time.Hour), now.Add(time.Hour), 0, alertnameFoo),
+					newSilence("prod", "1", now.Add(-time.Hour), now.Add(time.Hour), 0, instanceA),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analysis := analyzeSilences(tc.silences, tc.groups, now)
+			if diff := cmp.Diff(tc.analysis, analysis, cmpopts.IgnoreUnexported(models.SilenceMatcher{})); diff != "" {
+				t.Errorf("Wrong silence analysis returned (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSilenceAnalysisAPI(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+
+	req := httptest.NewRequest("GET", "/silences/analysis.json", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("GET /silences/analysis.json returned status %d", resp.Code)
+	}
+
+	var analysis SilenceAnalysis
+	if err := json.Unmarshal(resp.Body.Bytes(), &analysis); err != nil {
+		t.Fatalf("Failed to unmarshal response: %s", err)
+	}
+	expected := []SilenceConflict{
+		{
+			Cluster:  "default",
+			Kind:     silenceConflictOverlap,
+			Silences: []string{"9bd58938-25fd-41c5-aba3-9bc373074484", "dcb3b5d0-9f10-4baa-977a-70073a1899bd"},
+			// server7 alert is sent to two receivers
+			AlertCount: 2,
+		},
+	}
+	if diff := cmp.Diff(expected, analysis.Conflicts); diff != "" {
+		t.Errorf("Wrong silence conflicts returned (-want +got):\n%s", diff)
+	}
+	if len(analysis.Orphaned) != 0 {
+		t.Errorf("Expected no orphaned silences, got %d", len(analysis.Orphaned))
+	}
+}
+
+func TestSilenceAnalysisMetrics(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+
+	// metrics are using the analysis from the last collection
+	analysis := SilenceAnalysis{
+		Orphaned: []models.ManagedSilence{
+			{Cluster: "default", Silence: models.Silence{ID: "1"}},
+			{Cluster: "default", Silence: models.Silence{ID: "2"}},
+		},
+		Conflicts: []SilenceConflict{
+			{Cluster: "default", Kind: silenceConflictDuplicate, Silences: []string{"1", "2"}},
+		},
+	}
+	lastSilenceAnalysis.Store(&analysis)
+	defer lastSilenceAnalysis.Store(nil)
+
+	expected := `# HELP karma_silences_orphaned_count Number of active silences that don't match any alert
+# TYPE karma_silences_orphaned_count gauge
+karma_silences_orphaned_count{cluster="default"} 2
+# HELP karma_silence_conflicts_count Number of silence pairs that are duplicated, redundant or silence the same alerts
+# TYPE karma_silence_conflicts_count gauge
+karma_silence_conflicts_count{cluster="default",kind="duplicate"} 1
+karma_silence_conflicts_count{cluster="default",kind="overlap"} 0
+karma_silence_conflicts_count{cluster="default",kind="subset"} 0
+`
+	if err := testutil.CollectAndCompare(newKarmaCollector(), strings.NewReader(expected), "karma_silences_orphaned_count", "karma_silence_conflicts_count"); err != nil {
+		t.Error(err)
+	}
+}
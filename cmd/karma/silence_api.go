@@ -0,0 +1,473 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/regex"
+)
+
+type SilenceRequestMatcher struct {
+	// IsEqual is a pointer so we can default to true if it's not set
+	IsEqual *bool  `json:"isEqual"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+}
+
+type SilenceRequest struct {
+	StartsAt  time.Time               `json:"startsAt"`
+	EndsAt    time.Time               `json:"endsAt"`
+	CreatedBy string                  `json:"createdBy"`
+	Comment   string                  `json:"comment"`
+	Matchers  []SilenceRequestMatcher `json:"matchers"`
+}
+
+type SilenceCreateRequest struct {
+	Silence  SilenceRequest `json:"silence"`
+	Clusters []string       `json:"clusters"`
+}
+
+type SilenceClusterResult struct {
+	Cluster      string `json:"cluster"`
+	Alertmanager string `json:"alertmanager"`
+	SilenceID    string `json:"silenceID"`
+	Error        string `json:"error"`
+}
+
+type SilenceCreateResponse struct {
+	Results []SilenceClusterResult `json:"results"`
+}
+
+// toSilenceMatchers validates all matchers and converts them to silence
+// matchers, isEqual defaults to true if not set
+func toSilenceMatchers(matchers []SilenceRequestMatcher) ([]models.SilenceMatcher, error) {
+	if len(matchers) == 0 {
+		return nil, errors.New("silence requires at least one matcher")
+	}
+	sms := make([]models.SilenceMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		if m.Name == "" {
+			return nil, errors.New("silence matcher requires a name")
+		}
+		if m.IsRegex {
+			if _, err := regex.CompileAnchored(m.Value); err != nil {
+				return nil, fmt.Errorf("silence matcher %q has invalid regex %q: %w", m.Name, m.Value, err)
+			}
+		}
+		isEqual := m.IsEqual == nil || *m.IsEqual
+		sms = append(sms, models.NewSilenceMatcher(m.Name, m.Value, m.IsRegex, isEqual))
+	}
+	return sms, nil
+}
+
+func (sr SilenceRequest) toSilence(now time.Time) (models.Silence, error) {
+	silence := models.Silence{
+		StartsAt:  sr.StartsAt,
+		EndsAt:    sr.EndsAt,
+		CreatedBy: sr.CreatedBy,
+		Comment:   sr.Comment,
+	}
+
+	var err error
+	if silence.Matchers, err = toSilenceMatchers(sr.Matchers); err != nil {
+		return silence, err
+	}
+
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	if silence.EndsAt.IsZero() {
+		return silence, errors.New("silence requires endsAt to be set")
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return silence, errors.New("silence endsAt must be after startsAt")
+	}
+	if !silence.EndsAt.After(now) {
+		return silence, errors.New("silence endsAt must be in the future")
+	}
+	if silence.Comment == "" {
+		return silence, errors.New("silence requires a comment")
+	}
+	if silence.CreatedBy == "" {
+		return silence, errors.New("silence requires createdBy to be set")
+	}
+
+	return silence, nil
+}
+
+// how long do we wait for a tracked silence to show up in Alertmanager
+// before we stop tracking it
+const silenceTrackTimeout = time.Hour
+
+type silenceKey struct {
+	cluster string
+	id      string
+}
+
+// trackCreatedSilence needs to be called for every silence created or updated
+// via karma
+func trackCreatedSilence(cluster, id string) {
+	silenceSync.track(cluster, id)
+	silenceReminders.track(cluster, id)
+}
+
+// isTrackingCreatedSilences returns true if there's anything that needs to
+// know about silences created via karma in given cluster
+func isTrackingCreatedSilences(cluster string) bool {
+	return silenceSync.groupForCluster(cluster) != nil || silenceReminders.isEnabled()
+}
+
+// writableClusterMembers returns all Alertmanager instances from given cluster
+// that can be used to manage silences, healthy instances are returned first
+func writableClusterMembers(cluster string, upstreams []*alertmanager.Alertmanager) []*alertmanager.Alertmanager {
+	members := []*alertmanager.Alertmanager{}
+	for _, am := range upstreams {
+		if am.Cluster == cluster && !am.ReadOnly {
+			members = append(members, am)
+		}
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].IsHealthy() != members[j].IsHealthy() {
+			return members[i].IsHealthy()
+		}
+		return members[i].Name < members[j].Name
+	})
+	return members
+}
+
+// checkMembersSilenceACLs returns an error if given silence is blocked by ACL
+// rules for any of the Alertmanager instances
+func checkMembersSilenceACLs(members []*alertmanager.Alertmanager, silence *models.Silence, groups []string) error {
+	for _, am := range members {
+		if err := checkSilenceACLs(am.Name, silence, groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runOnClusterMembers will call fn with each cluster member in turn, until
+// it succeeds, and return the name of the last Alertmanager that was used
+func runOnClusterMembers(cluster, action string, members []*alertmanager.Alertmanager, fn func(*alertmanager.Alertmanager) (string, error)) (amName, silenceID string, err error) {
+	for _, am := range members {
+		amName = am.Name
+		if silenceID, err = fn(am); err != nil {
+			slog.Error(
+				"Failed to "+action+" silence",
+				slog.Any("error", err),
+				slog.String("cluster", cluster),
+				slog.String("alertmanager", am.Name),
+			)
+			continue
+		}
+		return amName, silenceID, nil
+	}
+	return amName, "", err
+}
+
+// sendSilenceToCluster will try to create (or update if it has an ID set)
+// given silence using each cluster member in turn, until it succeeds
+func sendSilenceToCluster(cluster string, members []*alertmanager.Alertmanager, silence models.Silence) SilenceClusterResult {
+	result := SilenceClusterResult{Cluster: cluster}
+	var err error
+	result.Alertmanager, result.SilenceID, err = runOnClusterMembers(cluster, "send", members, func(am *alertmanager.Alertmanager) (string, error) {
+		return am.CreateSilence(silence)
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func createSilence(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	var req SilenceCreateRequest
+	err := jsonv2.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	if config.Config.Authentication.Enabled {
+		req.Silence.CreatedBy = getUserFromContext(r)
+	}
+
+	silence, err := req.Silence.toSilence(time.Now())
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+	if err = checkSilenceTemplates(&silence); err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	if len(req.Clusters) == 0 {
+		badRequestJSON(w, "at least one cluster is required")
+		return
+	}
+	req.Clusters = slices.Compact(slices.Sorted(slices.Values(req.Clusters)))
+
+	upstreams := alertmanager.GetAlertmanagers()
+	members := make(map[string][]*alertmanager.Alertmanager, len(req.Clusters))
+	for _, cluster := range req.Clusters {
+		if !slices.ContainsFunc(upstreams, func(am *alertmanager.Alertmanager) bool { return am.Cluster == cluster }) {
+			badRequestJSON(w, fmt.Sprintf("unknown cluster %q", cluster))
+			return
+		}
+		members[cluster] = writableClusterMembers(cluster, upstreams)
+		if len(members[cluster]) == 0 {
+			badRequestJSON(w, fmt.Sprintf("all Alertmanager instances in cluster %q are read-only", cluster))
+			return
+		}
+	}
+
+	// validate ACLs for all targets before sending anything, so we don't end
+	// up with a silence created only in some clusters
+	groups := getGroupsFromContext(r)
+	for _, cluster := range req.Clusters {
+		if err = checkMembersSilenceACLs(members[cluster], &silence, groups); err != nil {
+			slog.Warn(
+				"Silence request was blocked by ACL rule",
+				slog.Any("error", err),
+				slog.String("cluster", cluster),
+			)
+			badRequestJSON(w, err.Error())
+			return
+		}
+	}
+
+	resp := SilenceCreateResponse{
+		Results: make([]SilenceClusterResult, 0, len(req.Clusters)),
+	}
+	status := http.StatusOK
+	for _, cluster := range req.Clusters {
+		result := sendSilenceToCluster(cluster, members[cluster], silence)
+		if result.Error != "" {
+			status = http.StatusBadGateway
+		} else {
+			trackCreatedSilence(cluster, result.SilenceID)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	data, _ := marshalJSON(resp)
+	mimeJSON(w)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+type SilenceBulkRequest struct {
+	IDs        []string `json:"ids"`
+	SearchTerm string   `json:"searchTerm"`
+	Duration   string   `json:"duration"`
+	Comment    string   `json:"comment"`
+}
+
+type SilenceBulkResult struct {
+	ID           string `json:"id"`
+	Cluster      string `json:"cluster"`
+	Alertmanager string `json:"alertmanager"`
+	SilenceID    string `json:"silenceID"`
+	Error        string `json:"error"`
+}
+
+type SilenceBulkResponse struct {
+	Results []SilenceBulkResult `json:"results"`
+}
+
+// silenceBulkOperation describes a modification that can be applied to many
+// silences at once
+type silenceBulkOperation struct {
+	// name is used in logs
+	name string
+	// prepare validates the request and returns a function that modifies
+	// each selected silence
+	prepare func(req SilenceBulkRequest) (func(*models.Silence), error)
+	// apply sends modified silence to given Alertmanager and returns the ID
+	// of the silence after modification
+	apply func(am *alertmanager.Alertmanager, silence models.Silence) (string, error)
+}
+
+var (
+	silenceBulkExpire = silenceBulkOperation{
+		name: "expire",
+		prepare: func(_ SilenceBulkRequest) (func(*models.Silence), error) {
+			return func(*models.Silence) {}, nil
+		},
+		apply: func(am *alertmanager.Alertmanager, silence models.Silence) (string, error) {
+			return silence.ID, am.ExpireSilence(silence.ID)
+		},
+	}
+	silenceBulkExtend = silenceBulkOperation{
+		name: "extend",
+		prepare: func(req SilenceBulkRequest) (func(*models.Silence), error) {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration %q, expected a value like 10m or 1h", req.Duration)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("invalid duration %q, it must be positive", req.Duration)
+			}
+			return func(silence *models.Silence) {
+				silence.EndsAt = silence.EndsAt.Add(d)
+			}, nil
+		},
+		apply: func(am *alertmanager.Alertmanager, silence models.Silence) (string, error) {
+			return am.CreateSilence(silence)
+		},
+	}
+	silenceBulkEdit = silenceBulkOperation{
+		name: "edit",
+		prepare: func(req SilenceBulkRequest) (func(*models.Silence), error) {
+			if req.Comment == "" {
+				return nil, errors.New("comment is required")
+			}
+			return func(silence *models.Silence) {
+				silence.Comment = req.Comment
+			}, nil
+		},
+		apply: func(am *alertmanager.Alertmanager, silence models.Silence) (string, error) {
+			return am.CreateSilence(silence)
+		},
+	}
+)
+
+// selectBulkSilences returns all non-expired silences matching the request,
+// a silence must match both the list of IDs and the search term if both are set.
+// Requested IDs that don't match any silence are returned as missing.
+func selectBulkSilences(req SilenceBulkRequest) (selected []models.ManagedSilence, missing []string) {
+	searchTerm := strings.ToLower(req.SearchTerm)
+	clusters := silenceSearchClusters(searchTerm)
+	found := map[string]struct{}{}
+	for _, silence := range alertmanager.DedupSilences() {
+		if silence.IsExpired {
+			continue
+		}
+		if len(req.IDs) > 0 && !slices.Contains(req.IDs, silence.Silence.ID) {
+			continue
+		}
+		if searchTerm != "" && !silenceMatchesSearchTerm(silence, searchTerm, clusters) {
+			continue
+		}
+		found[silence.Silence.ID] = struct{}{}
+		selected = append(selected, silence)
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Cluster == selected[j].Cluster {
+			return selected[i].Silence.ID < selected[j].Silence.ID
+		}
+		return selected[i].Cluster < selected[j].Cluster
+	})
+	for _, id := range req.IDs {
+		if _, ok := found[id]; !ok && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	return selected, missing
+}
+
+func bulkSilenceHandler(op silenceBulkOperation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		noCache(w)
+
+		var req SilenceBulkRequest
+		err := jsonv2.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			badRequestJSON(w, err.Error())
+			return
+		}
+
+		if len(req.IDs) == 0 && req.SearchTerm == "" {
+			badRequestJSON(w, "ids or searchTerm is required")
+			return
+		}
+
+		modify, err := op.prepare(req)
+		if err != nil {
+			badRequestJSON(w, err.Error())
+			return
+		}
+
+		selected, missing := selectBulkSilences(req)
+		resp := SilenceBulkResponse{
+			Results: make([]SilenceBulkResult, 0, len(selected)+len(missing)),
+		}
+		for _, id := range missing {
+			resp.Results = append(resp.Results, SilenceBulkResult{ID: id, Error: "silence not found"})
+		}
+
+		upstreams := alertmanager.GetAlertmanagers()
+		groups := getGroupsFromContext(r)
+		for _, ms := range selected {
+			result := SilenceBulkResult{ID: ms.Silence.ID, Cluster: ms.Cluster}
+
+			members := writableClusterMembers(ms.Cluster, upstreams)
+			if len(members) == 0 {
+				result.Error = fmt.Sprintf("all Alertmanager instances in cluster %q are read-only", ms.Cluster)
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			silence := ms.Silence
+			silence.Matchers = slices.Clone(ms.Silence.Matchers)
+			modify(&silence)
+			if config.Config.Authentication.Enabled && op.name != silenceBulkExpire.name {
+				silence.CreatedBy = getUserFromContext(r)
+			}
+
+			var err error
+			// expiring a silence doesn't need any fields required by templates
+			// and existing silences don't have to satisfy ACL constraints
+			if op.name == silenceBulkExpire.name {
+				for _, am := range members {
+					if err = checkSilenceExpireACLs(am.Name, &silence, groups); err != nil {
+						break
+					}
+				}
+			} else if err = checkSilenceTemplates(&silence); err == nil {
+				err = checkMembersSilenceACLs(members, &silence, groups)
+			}
+			if err != nil {
+				slog.Warn(
+					"Bulk silence request was rejected",
+					slog.Any("error", err),
+					slog.String("action", op.name),
+					slog.String("cluster", ms.Cluster),
+					slog.String("silence", ms.Silence.ID),
+				)
+				result.Error = err.Error()
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			result.Alertmanager, result.SilenceID, err = runOnClusterMembers(ms.Cluster, op.name, members, func(am *alertmanager.Alertmanager) (string, error) {
+				return op.apply(am, silence)
+			})
+			if err != nil {
+				result.Error = err.Error()
+			} else if op.name != silenceBulkExpire.name {
+				trackCreatedSilence(ms.Cluster, result.SilenceID)
+			}
+			resp.Results = append(resp.Results, result)
+		}
+
+		data, _ := marshalJSON(resp)
+		mimeJSON(w)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jarcoal/httpmock"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/mock"
+)
+
+func TestCreateSilence(t *testing.T) {
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	type testCaseT struct {
+		name         string
+		body         string
+		user         string
+		acls         []*silenceACL
+		upstreamCode int
+		upstreamBody string
+		code         int
+		resp         string
+		sent         string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:         "silence is created",
+			body:         `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			upstreamCode: 200,
+			upstreamBody: `{"silenceID":"1234"}`,
+			code:         200,
+			resp:         `{"results":[{"cluster":"default","alertmanager":"default","silenceID":"1234","error":""}]}`,
+			sent:         `"comment":"foo","createdBy":"me","matchers":[{"isEqual":true,"name":"alertname","value":"Foo","isRegex":false}]`,
+		},
+		{
+			name:         "duplicated clusters are ignored",
+			body:         `{"clusters":["default","default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo","isEqual":false}]}}`,
+			upstreamCode: 200,
+			upstreamBody: `{"silenceID":"1234"}`,
+			code:         200,
+			resp:         `{"results":[{"cluster":"default","alertmanager":"default","silenceID":"1234","error":""}]}`,
+			sent:         `"matchers":[{"isEqual":false,"name":"alertname","value":"Foo","isRegex":false}]`,
+		},
+		{
+			name:         "createdBy is set to the authenticated user",
+			body:         `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			user:         "bob",
+			upstreamCode: 200,
+			upstreamBody: `{"silenceID":"1234"}`,
+			code:         200,
+			resp:         `{"results":[{"cluster":"default","alertmanager":"default","silenceID":"1234","error":""}]}`,
+			sent:         `"createdBy":"bob"`,
+		},
+		{
+			name:         "upstream error is reported",
+			body:         `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			upstreamCode: 500,
+			upstreamBody: "failed to create silence: storage error\n",
+			code:         502,
+			resp:         `{"results":[{"cluster":"default","alertmanager":"default","silenceID":"","error":"request to http://localhost/api/v2/silences returned status 500: failed to create silence: storage error"}]}`,
+		},
+		{
+			name: "blocked by ACL",
+			body: `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "no silences allowed"},
+			},
+			code: 400,
+			resp: `{"error":"silence blocked by ACL rule: no silences allowed"}`,
+		},
+		{
+			name: "unknown cluster",
+			body: `{"clusters":["foo"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			code: 400,
+			resp: `{"error":"unknown cluster \"foo\""}`,
+		},
+		{
+			name: "missing clusters",
+			body: `{"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			code: 400,
+			resp: `{"error":"at least one cluster is required"}`,
+		},
+		{
+			name: "missing matchers",
+			body: `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo"}}`,
+			code: 400,
+			resp: `{"error":"silence requires at least one matcher"}`,
+		},
+		{
+			name: "invalid regex",
+			body: `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo[","isRegex":true}]}}`,
+			code: 400,
+			resp: `{"error":"silence matcher \"alertname\" has invalid regex \"Foo[\": error parsing regexp: missing closing ]: ` + "`[$`" + `"}`,
+		},
+		{
+			name: "missing endsAt",
+			body: `{"clusters":["default"],"silence":{"createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			code: 400,
+			resp: `{"error":"silence requires endsAt to be set"}`,
+		},
+		{
+			name: "endsAt in the past",
+			body: `{"clusters":["default"],"silence":{"startsAt":"2000-01-01T00:00:00Z","endsAt":"2000-01-02T00:00:00Z","createdBy":"me","comment":"foo","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			code: 400,
+			resp: `{"error":"silence endsAt must be in the future"}`,
+		},
+		{
+			name: "missing comment",
+			body: `{"clusters":["default"],"silence":{"endsAt":"` + endsAt + `","createdBy":"me","matchers":[{"name":"alertname","value":"Foo"}]}}`,
+			code: 400,
+			resp: `{"error":"silence requires a comment"}`,
+		},
+		{
+			name: "invalid body",
+			body: `{"clusters":`,
+			code: 400,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	for _, tc := range testCases {
+		version := mock.ListAllMocks()[0]
+		t.Run(tc.name, func(t *testing.T) {
+			mockAlerts(version)
+
+			config.Config.Authentication.Enabled = tc.user != ""
+			silenceACLs = tc.acls
+			defer func() {
+				config.Config.Authentication.Enabled = false
+				silenceACLs = []*silenceACL{}
+			}()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			var sent string
+			httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				sent = string(body)
+				return httpmock.NewStringResponse(tc.upstreamCode, tc.upstreamBody), nil
+			})
+
+			r := testRouter()
+			setupRouter(r, nil)
+			req := httptest.NewRequest("POST", "/api/silences", strings.NewReader(tc.body))
+			if tc.user != "" {
+				req = req.WithContext(context.WithValue(req.Context(), authUserKey("user"), tc.user))
+			}
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("POST /api/silences returned status %d, expected %d: %s", resp.Code, tc.code, resp.Body.String())
+			}
+			if tc.resp != "" {
+				if diff := cmp.Diff(tc.resp, resp.Body.String()); diff != "" {
+					t.Errorf("Wrong response (-want +got):\n%s", diff)
+				}
+			}
+			if !strings.Contains(sent, tc.sent) {
+				t.Errorf("Silence sent to Alertmanager doesn't contain %s: %s", tc.sent, sent)
+			}
+		})
+	}
+}
+
+func TestWritableClusterMembers(t *testing.T) {
+	newAM := func(cluster, name string, readonly bool) *alertmanager.Alertmanager {
+		am, err := alertmanager.NewAlertmanager(cluster, name, "http://localhost", alertmanager.WithReadOnly(readonly))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return am
+	}
+
+	upstreams := []*alertmanager.Alertmanager{
+		newAM("prod", "am3", false),
+		newAM("prod", "am1", false),
+		newAM("prod", "am2", true),
+		newAM("dev", "am4", false),
+	}
+
+	names := []string{}
+	for _, am := range writableClusterMembers("prod", upstreams) {
+		names = append(names, am.Name)
+	}
+	if diff := cmp.Diff([]string{"am1", "am3"}, names); diff != "" {
+		t.Errorf("Wrong cluster members returned (-want +got):\n%s", diff)
+	}
+
+	if members := writableClusterMembers("staging", upstreams); len(members) != 0 {
+		t.Errorf("Got %d members for unknown cluster", len(members))
+	}
+}
+
+func TestBulkSilenceOperations(t *testing.T) {
+	const (
+		silenceWeb1     = "810ccf7f-c957-474a-b383-7e76d66a4d3b"
+		silenceHostDown = "dcb3b5d0-9f10-4baa-977a-70073a1899bd"
+		silenceServer7  = "9bd58938-25fd-41c5-aba3-9bc373074484"
+	)
+
+	type testCaseT struct {
+		name         string
+		path         string
+		body         string
+		user         string
+		acls         []*silenceACL
+		upstreamCode int
+		code         int
+		resp         string
+		requests     []string
+		sent         string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:         "expire by IDs",
+			path:         "/api/silences/expire",
+			body:         `{"ids":["` + silenceWeb1 + `","` + silenceServer7 + `"]}`,
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"default","silenceID":"` + silenceWeb1 + `","error":""},{"id":"` + silenceServer7 + `","cluster":"default","alertmanager":"default","silenceID":"` + silenceServer7 + `","error":""}]}`,
+			requests:     []string{"DELETE /api/v2/silence/" + silenceWeb1, "DELETE /api/v2/silence/" + silenceServer7},
+		},
+		{
+			name:         "expire by search term",
+			path:         "/api/silences/expire",
+			body:         `{"searchTerm":"Host_Down"}`,
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceHostDown + `","cluster":"default","alertmanager":"default","silenceID":"` + silenceHostDown + `","error":""}]}`,
+			requests:     []string{"DELETE /api/v2/silence/" + silenceHostDown},
+		},
+		{
+			name:         "IDs and search term must both match",
+			path:         "/api/silences/expire",
+			body:         `{"ids":["` + silenceWeb1 + `","` + silenceHostDown + `"],"searchTerm":"instance=web1"}`,
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceHostDown + `","cluster":"","alertmanager":"","silenceID":"","error":"silence not found"},{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"default","silenceID":"` + silenceWeb1 + `","error":""}]}`,
+			requests:     []string{"DELETE /api/v2/silence/" + silenceWeb1},
+		},
+		{
+			name:         "unknown ID",
+			path:         "/api/silences/expire",
+			body:         `{"ids":["foo"]}`,
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"foo","cluster":"","alertmanager":"","silenceID":"","error":"silence not found"}]}`,
+		},
+		{
+			name:         "expire upstream error",
+			path:         "/api/silences/expire",
+			body:         `{"ids":["` + silenceWeb1 + `"]}`,
+			upstreamCode: 500,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"default","silenceID":"","error":"request to http://localhost/api/v2/silence/` + silenceWeb1 + ` returned status 500: silence not found"}]}`,
+			requests:     []string{"DELETE /api/v2/silence/" + silenceWeb1},
+		},
+		{
+			name: "expire blocked by ACL",
+			path: "/api/silences/expire",
+			body: `{"ids":["` + silenceWeb1 + `"]}`,
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "no silences allowed"},
+			},
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"","silenceID":"","error":"silence blocked by ACL rule: no silences allowed"}]}`,
+		},
+		{
+			name: "expire ignores ACL constraints",
+			path: "/api/silences/expire",
+			body: `{"ids":["` + silenceWeb1 + `"]}`,
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "ticket required", Constraints: aclConstraints{RequireTicket: true}},
+			},
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"default","silenceID":"` + silenceWeb1 + `","error":""}]}`,
+			requests:     []string{"DELETE /api/v2/silence/" + silenceWeb1},
+		},
+		{
+			name: "extend checks ACL constraints",
+			path: "/api/silences/extend",
+			body: `{"ids":["` + silenceWeb1 + `"],"duration":"1h"}`,
+			acls: []*silenceACL{
+				{Action: aclActionBlock, Reason: "ticket required", Constraints: aclConstraints{RequireTicket: true}},
+			},
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"","silenceID":"","error":"silence blocked by ACL rule: ticket required: silence comment must include a ticket ID"}]}`,
+		},
+		{
+			name:         "extend",
+			path:         "/api/silences/extend",
+			body:         `{"ids":["` + silenceWeb1 + `"],"duration":"24h"}`,
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"default","silenceID":"1234","error":""}]}`,
+			requests:     []string{"POST /api/v2/silences"},
+			sent:         `"endsAt":"2063-01-02T00:00:00.000Z","startsAt":`,
+		},
+		{
+			name:         "extend sets createdBy to the authenticated user",
+			path:         "/api/silences/extend",
+			body:         `{"ids":["` + silenceWeb1 + `"],"duration":"1h"}`,
+			user:         "bob",
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceWeb1 + `","cluster":"default","alertmanager":"default","silenceID":"1234","error":""}]}`,
+			requests:     []string{"POST /api/v2/silences"},
+			sent:         `"createdBy":"bob"`,
+		},
+		{
+			name: "extend with invalid duration",
+			path: "/api/silences/extend",
+			body: `{"ids":["` + silenceWeb1 + `"],"duration":"foo"}`,
+			code: 400,
+			resp: `{"error":"invalid duration \"foo\", expected a value like 10m or 1h"}`,
+		},
+		{
+			name: "extend with negative duration",
+			path: "/api/silences/extend",
+			body: `{"ids":["` + silenceWeb1 + `"],"duration":"-1h"}`,
+			code: 400,
+			resp: `{"error":"invalid duration \"-1h\", it must be positive"}`,
+		},
+		{
+			name:         "edit",
+			path:         "/api/silences/edit",
+			body:         `{"searchTerm":"instance=server7","comment":"new comment"}`,
+			upstreamCode: 200,
+			code:         200,
+			resp:         `{"results":[{"id":"` + silenceServer7 + `","cluster":"default","alertmanager":"default","silenceID":"1234","error":""}]}`,
+			requests:     []string{"POST /api/v2/silences"},
+			sent:         `"comment":"new comment","createdBy":"john@example.com"`,
+		},
+		{
+			name: "edit without comment",
+			path: "/api/silences/edit",
+			body: `{"ids":["` + silenceWeb1 + `"]}`,
+			code: 400,
+			resp: `{"error":"comment is required"}`,
+		},
+		{
+			name: "missing IDs and search term",
+			path: "/api/silences/expire",
+			body: `{}`,
+			code: 400,
+			resp: `{"error":"ids or searchTerm is required"}`,
+		},
+		{
+			name: "invalid body",
+			path: "/api/silences/expire",
+			body: `{"ids":`,
+			code: 400,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAlerts("0.31.0")
+
+			config.Config.Authentication.Enabled = tc.user != ""
+			silenceACLs = tc.acls
+			defer func() {
+				config.Config.Authentication.Enabled = false
+				silenceACLs = []*silenceACL{}
+			}()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			requests := []string{}
+			var sent string
+			httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req.Method+" "+req.URL.Path)
+				body, _ := io.ReadAll(req.Body)
+				sent = string(body)
+				return httpmock.NewStringResponse(tc.upstreamCode, `{"silenceID":"1234"}`), nil
+			})
+			httpmock.RegisterRegexpResponder("DELETE", regexp.MustCompile("^http://localhost/api/v2/silence/.+"), func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req.Method+" "+req.URL.Path)
+				if tc.upstreamCode != 200 {
+					return httpmock.NewStringResponse(tc.upstreamCode, "silence not found"), nil
+				}
+				return httpmock.NewStringResponse(tc.upstreamCode, ""), nil
+			})
+
+			r := testRouter()
+			setupRouter(r, nil)
+			req := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if tc.user != "" {
+				req = req.WithContext(context.WithValue(req.Context(), authUserKey("user"), tc.user))
+			}
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("POST %s returned status %d, expected %d: %s", tc.path, resp.Code, tc.code, resp.Body.String())
+			}
+			if tc.resp != "" {
+				if diff := cmp.Diff(tc.resp, resp.Body.String()); diff != "" {
+					t.Errorf("Wrong response (-want +got):\n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(tc.requests, requests, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Wrong requests sent to Alertmanager (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(sent, tc.sent) {
+				t.Errorf("Silence sent to Alertmanager doesn't contain %s: %s", tc.sent, sent)
+			}
+		})
+	}
+}
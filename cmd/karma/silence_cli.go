@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/spf13/pflag"
+)
+
+type silenceClient struct {
+	client  *http.Client
+	uri     string
+	headers []string
+}
+
+func (sc *silenceClient) do(method, path string, query url.Values, body io.Reader) ([]byte, error) {
+	u, err := url.Parse(strings.TrimSuffix(sc.uri, "/") + path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range sc.headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q, expected 'Name: value'", header)
+		}
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	resp, err := sc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned status code %d: %s", method, u.Redacted(), resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func silencesExportCommand(sc *silenceClient, stdout io.Writer, format, output string, withExpired bool) error {
+	if format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported export format %q, must be json or yaml", format)
+	}
+
+	query := url.Values{}
+	if withExpired {
+		query.Set("expired", "true")
+	}
+	data, err := sc.do(http.MethodGet, "/silences/export."+format, query, nil)
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		return os.WriteFile(output, data, 0o644)
+	}
+	_, err = stdout.Write(data)
+	return err
+}
+
+func silencesImportCommand(sc *silenceClient, stdout io.Writer, path string, clusters, createdBy []string, dryRun bool) error {
+	if path == "" {
+		return errors.New("--import.file is required")
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query["cluster"] = clusters
+	query["createdBy"] = createdBy
+	if dryRun {
+		query.Set("dryRun", "true")
+	}
+	data, err := sc.do(http.MethodPost, "/silences/import.json", query, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	var resp SilenceImportResponse
+	if err = jsonv2.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range resp.Results {
+		switch {
+		case r.Error != "":
+			failed++
+			_, _ = fmt.Fprintf(stdout, "failed to import silence %s into %s: %s\n", r.ID, r.Cluster, r.Error)
+		case r.Skipped != "":
+			_, _ = fmt.Fprintf(stdout, "skipped silence %s in %s: %s\n", r.ID, r.Cluster, r.Skipped)
+		case dryRun:
+			_, _ = fmt.Fprintf(stdout, "would import silence %s into %s\n", r.ID, r.Cluster)
+		default:
+			_, _ = fmt.Fprintf(stdout, "imported silence %s into %s as %s\n", r.ID, r.Cluster, r.SilenceID)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to import %d silence(s)", failed)
+	}
+	return nil
+}
+
+// silencesCommand handles 'karma silences export|import' subcommands, both
+// talk to a running karma instance, so all authentication and ACL rules
+// are enforced by it
+func silencesCommand(args []string, stdout io.Writer, errorHandling pflag.ErrorHandling) error {
+	if len(args) == 0 {
+		return errors.New("silences command requires a subcommand: export or import")
+	}
+
+	f := pflag.NewFlagSet("karma silences "+args[0], errorHandling)
+	uri := f.String("karma.uri", "http://localhost:8080", "URI of the karma instance to talk to")
+	headers := f.StringArray("karma.headers", nil, "Extra HTTP header to send with each request, as 'Name: value'")
+	timeout := f.Duration("karma.timeout", time.Minute, "Timeout for requests sent to karma")
+
+	var run func(sc *silenceClient) error
+	switch args[0] {
+	case "export":
+		format := f.String("export.format", "yaml", "Export format, json or yaml")
+		output := f.String("export.output", "", "Write exported silences to this file instead of stdout")
+		withExpired := f.Bool("export.expired", false, "Include expired silences")
+		run = func(sc *silenceClient) error {
+			return silencesExportCommand(sc, stdout, *format, *output, *withExpired)
+		}
+	case "import":
+		file := f.String("import.file", "", "File with silences to import")
+		clusters := f.StringSlice("import.cluster", nil, "Import silences into this cluster instead of the cluster they were exported from, can be repeated")
+		createdBy := f.StringArray("import.createdBy", nil, "Rename silence author, as 'old=new', can be repeated")
+		dryRun := f.Bool("import.dryRun", false, "Only check which silences would be imported")
+		run = func(sc *silenceClient) error {
+			return silencesImportCommand(sc, stdout, *file, *clusters, *createdBy, *dryRun)
+		}
+	default:
+		return fmt.Errorf("unknown silences subcommand %q, must be export or import", args[0])
+	}
+
+	if err := f.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	return run(&silenceClient{
+		client:  &http.Client{Timeout: *timeout},
+		uri:     *uri,
+		headers: *headers,
+	})
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "go.yaml.in/yaml/v3"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+type SilenceExportMatcher struct {
+	// IsEqual is a pointer so we can default to true if it's not set
+	IsEqual *bool  `json:"isEqual" yaml:"isEqual"`
+	Name    string `json:"name" yaml:"name"`
+	Value   string `json:"value" yaml:"value"`
+	IsRegex bool   `json:"isRegex" yaml:"isRegex"`
+}
+
+type SilenceExportEntry struct {
+	StartsAt  time.Time              `json:"startsAt" yaml:"startsAt"`
+	EndsAt    time.Time              `json:"endsAt" yaml:"endsAt"`
+	Cluster   string                 `json:"cluster" yaml:"cluster"`
+	ID        string                 `json:"id" yaml:"id"`
+	CreatedBy string                 `json:"createdBy" yaml:"createdBy"`
+	Comment   string                 `json:"comment" yaml:"comment"`
+	Matchers  []SilenceExportMatcher `json:"matchers" yaml:"matchers"`
+}
+
+type SilenceExport struct {
+	Silences []SilenceExportEntry `json:"silences" yaml:"silences"`
+}
+
+type SilenceImportResult struct {
+	ID           string `json:"id"`
+	Cluster      string `json:"cluster"`
+	Alertmanager string `json:"alertmanager"`
+	SilenceID    string `json:"silenceID"`
+	Skipped      string `json:"skipped"`
+	Error        string `json:"error"`
+}
+
+type SilenceImportResponse struct {
+	Results []SilenceImportResult `json:"results"`
+}
+
+type silenceImportOptions struct {
+	// clusters to import silences into, if empty then each silence is
+	// imported into the cluster it was exported from
+	clusters  []string
+	createdBy map[string]string
+	// if set then this user is the author of all imported silences
+	user   string
+	groups []string
+	dryRun bool
+}
+
+// exportSilences returns all silences, sorted by cluster and ID, expired
+// silences are only included if withExpired is true
+func exportSilences(dedupedSilences []models.ManagedSilence, withExpired bool) SilenceExport {
+	export := SilenceExport{Silences: []SilenceExportEntry{}}
+	for _, ms := range dedupedSilences {
+		if ms.IsExpired && !withExpired {
+			continue
+		}
+		entry := SilenceExportEntry{
+			StartsAt:  ms.Silence.StartsAt,
+			EndsAt:    ms.Silence.EndsAt,
+			Cluster:   ms.Cluster,
+			ID:        ms.Silence.ID,
+			CreatedBy: ms.Silence.CreatedBy,
+			Comment:   ms.Silence.Comment,
+			Matchers:  make([]SilenceExportMatcher, 0, len(ms.Silence.Matchers)),
+		}
+		for _, m := range ms.Silence.Matchers {
+			entry.Matchers = append(entry.Matchers, SilenceExportMatcher{
+				Name:    m.Name,
+				Value:   m.Value,
+				IsRegex: m.IsRegex,
+				IsEqual: &m.IsEqual,
+			})
+		}
+		export.Silences = append(export.Silences, entry)
+	}
+	sort.Slice(export.Silences, func(i, j int) bool {
+		if export.Silences[i].Cluster != export.Silences[j].Cluster {
+			return export.Silences[i].Cluster < export.Silences[j].Cluster
+		}
+		return export.Silences[i].ID < export.Silences[j].ID
+	})
+	return export
+}
+
+// parseSilenceExport decodes YAML or JSON exported silences, JSON is valid
+// YAML so we can use a single decoder for both
+func parseSilenceExport(r io.Reader) (SilenceExport, error) {
+	var export SilenceExport
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&export); err != nil {
+		if errors.Is(err, io.EOF) {
+			return export, errors.New("no silences to import")
+		}
+		return export, fmt.Errorf("failed to parse exported silences: %w", err)
+	}
+	return export, nil
+}
+
+// findDuplicatedSilence returns the ID of an active silence from given cluster
+// with the same matchers that overlaps in time with given silence
+func findDuplicatedSilence(cluster string, silence models.Silence, dedupedSilences []models.ManagedSilence) string {
+	set := silenceMatcherSet(silence)
+	for _, ms := range dedupedSilences {
+		if ms.Cluster != cluster || ms.IsExpired {
+			continue
+		}
+		other := silenceMatcherSet(ms.Silence)
+		if len(set) == len(other) && isMatcherSubset(set, other) && silencesOverlapInTime(silence, ms.Silence) {
+			return ms.Silence.ID
+		}
+	}
+	return ""
+}
+
+func importSilences(export SilenceExport, opts silenceImportOptions, upstreams []*alertmanager.Alertmanager, dedupedSilences []models.ManagedSilence, now time.Time) SilenceImportResponse {
+	resp := SilenceImportResponse{Results: []SilenceImportResult{}}
+	// silences created during this import are tracked so we don't create
+	// them twice if the exported file has duplicates
+	existing := slices.Clone(dedupedSilences)
+
+	for _, entry := range export.Silences {
+		clusters := opts.clusters
+		if len(clusters) == 0 {
+			clusters = []string{entry.Cluster}
+		}
+		for _, cluster := range clusters {
+			result := SilenceImportResult{ID: entry.ID, Cluster: cluster}
+
+			if !entry.EndsAt.After(now) {
+				result.Skipped = "silence is expired"
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			req := SilenceRequest{
+				StartsAt:  entry.StartsAt,
+				EndsAt:    entry.EndsAt,
+				CreatedBy: entry.CreatedBy,
+				Comment:   entry.Comment,
+				Matchers:  make([]SilenceRequestMatcher, 0, len(entry.Matchers)),
+			}
+			for _, m := range entry.Matchers {
+				req.Matchers = append(req.Matchers, SilenceRequestMatcher{
+					Name:    m.Name,
+					Value:   m.Value,
+					IsRegex: m.IsRegex,
+					IsEqual: m.IsEqual,
+				})
+			}
+			if newName, ok := opts.createdBy[req.CreatedBy]; ok {
+				req.CreatedBy = newName
+			}
+			if opts.user != "" {
+				req.CreatedBy = opts.user
+			}
+
+			silence, err := req.toSilence(now)
+			if err == nil {
+				err = checkSilenceTemplates(&silence)
+			}
+			if err != nil {
+				result.Error = err.Error()
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			if !slices.ContainsFunc(upstreams, func(am *alertmanager.Alertmanager) bool { return am.Cluster == cluster }) {
+				result.Error = fmt.Sprintf("unknown cluster %q", cluster)
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+			members := writableClusterMembers(cluster, upstreams)
+			if len(members) == 0 {
+				result.Error = fmt.Sprintf("all Alertmanager instances in cluster %q are read-only", cluster)
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			if id := findDuplicatedSilence(cluster, silence, existing); id != "" {
+				result.Skipped = "duplicate of silence " + id
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			if err = checkMembersSilenceACLs(members, &silence, opts.groups); err != nil {
+				result.Error = err.Error()
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+
+			if opts.dryRun {
+				resp.Results = append(resp.Results, result)
+				silence.ID = entry.ID
+				existing = append(existing, models.ManagedSilence{Cluster: cluster, Silence: silence})
+				continue
+			}
+
+			sent := sendSilenceToCluster(cluster, members, silence)
+			result.Alertmanager, result.SilenceID, result.Error = sent.Alertmanager, sent.SilenceID, sent.Error
+			if result.Error == "" {
+				silence.ID = result.SilenceID
+				existing = append(existing, models.ManagedSilence{Cluster: cluster, Silence: silence})
+				trackCreatedSilence(cluster, result.SilenceID)
+				slog.Info(
+					"Imported silence",
+					slog.String("cluster", cluster),
+					slog.String("alertmanager", result.Alertmanager),
+					slog.String("source", entry.ID),
+					slog.String("silence", result.SilenceID),
+				)
+			}
+			resp.Results = append(resp.Results, result)
+		}
+	}
+
+	return resp
+}
+
+func silenceExportHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		noCache(w)
+
+		export := exportSilences(alertmanager.DedupSilences(), r.URL.Query().Get("expired") == "true")
+
+		var data []byte
+		if format == "yaml" {
+			var buf bytes.Buffer
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			_ = enc.Encode(export)
+			data = buf.Bytes()
+			w.Header().Set("Content-Type", "application/yaml")
+		} else {
+			data, _ = marshalJSON(export)
+			mimeJSON(w)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	}
+}
+
+// import silences exported via silenceExportHandler, silences are imported
+// into the cluster they were exported from unless ?cluster=... is passed
+// ?createdBy=old=new can be used to rename silence authors
+func silenceImport(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	export, err := parseSilenceExport(r.Body)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	opts := silenceImportOptions{
+		clusters:  slices.Compact(slices.Sorted(slices.Values(q["cluster"]))),
+		createdBy: map[string]string{},
+		groups:    getGroupsFromContext(r),
+		dryRun:    q.Get("dryRun") == "true",
+	}
+	for _, rename := range q["createdBy"] {
+		oldName, newName, ok := strings.Cut(rename, "=")
+		if !ok || oldName == "" || newName == "" {
+			badRequestJSON(w, fmt.Sprintf("invalid createdBy value %q, expected old=new", rename))
+			return
+		}
+		opts.createdBy[oldName] = newName
+	}
+	if config.Config.Authentication.Enabled {
+		opts.user = getUserFromContext(r)
+	}
+
+	resp := importSilences(export, opts, alertmanager.GetAlertmanagers(), alertmanager.DedupSilences(), time.Now())
+
+	data, _ := marshalJSON(resp)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/pflag"
+)
+
+func TestSilenceExport(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+
+	exports := map[string]SilenceExport{}
+	for _, format := range []string{"json", "yaml"} {
+		req := httptest.NewRequest("GET", "/silences/export."+format, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != 200 {
+			t.Fatalf("GET /silences/export.%s returned status %d", format, resp.Code)
+		}
+		export, err := parseSilenceExport(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse /silences/export.%s response: %s", format, err)
+		}
+		exports[format] = export
+	}
+
+	if diff := cmp.Diff(exports["json"], exports["yaml"]); diff != "" {
+		t.Errorf("JSON and YAML exports are different (-json +yaml):\n%s", diff)
+	}
+
+	ids := []string{}
+	for _, s := range exports["json"].Silences {
+		if s.Cluster != "default" {
+			t.Errorf("Wrong cluster on exported silence %s: %s", s.ID, s.Cluster)
+		}
+		ids = append(ids, s.ID)
+	}
+	expected := []string{
+		"810ccf7f-c957-474a-b383-7e76d66a4d3b",
+		"9bd58938-25fd-41c5-aba3-9bc373074484",
+		"dcb3b5d0-9f10-4baa-977a-70073a1899bd",
+	}
+	if diff := cmp.Diff(expected, ids); diff != "" {
+		t.Errorf("Wrong silences exported (-want +got):\n%s", diff)
+	}
+}
+
+func TestSilenceImport(t *testing.T) {
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	type testCaseT struct {
+		name     string
+		query    string
+		body     string
+		code     int
+		resp     string
+		requests []string
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "invalid body",
+			body: `silences: 1`,
+			code: 400,
+			resp: `{"error":"failed to parse exported silences: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!int ` + "`1`" + ` into []main.SilenceExportEntry"}`,
+		},
+		{
+			name: "unknown field",
+			body: `{"silences":[{"foo":"bar"}]}`,
+			code: 400,
+			resp: `{"error":"failed to parse exported silences: yaml: unmarshal errors:\n  line 1: field foo not found in type main.SilenceExportEntry"}`,
+		},
+		{
+			name: "empty body",
+			code: 400,
+			resp: `{"error":"no silences to import"}`,
+		},
+		{
+			name:  "invalid createdBy",
+			query: "?createdBy=foo",
+			body:  `silences: []`,
+			code:  400,
+			resp:  `{"error":"invalid createdBy value \"foo\", expected old=new"}`,
+		},
+		{
+			name: "duplicated silence is skipped",
+			body: `{"silences":[{"cluster":"default","id":"1","startsAt":"2000-01-01T00:00:00Z","endsAt":"2063-01-01T00:00:00Z","createdBy":"me","comment":"foo","matchers":[{"name":"instance","value":"web1"}]}]}`,
+			code: 200,
+			resp: `{"results":[{"id":"1","cluster":"default","alertmanager":"","silenceID":"","skipped":"duplicate of silence 810ccf7f-c957-474a-b383-7e76d66a4d3b","error":""}]}`,
+		},
+		{
+			name: "expired silence is skipped",
+			body: `{"silences":[{"cluster":"default","id":"1","startsAt":"2000-01-01T00:00:00Z","endsAt":"2000-01-01T01:00:00Z","createdBy":"me","comment":"foo","matchers":[{"name":"instance","value":"web1"}]}]}`,
+			code: 200,
+			resp: `{"results":[{"id":"1","cluster":"default","alertmanager":"","silenceID":"","skipped":"silence is expired","error":""}]}`,
+		},
+		{
+			name: "silence from unknown cluster",
+			body: `{"silences":[{"cluster":"foo","id":"1","endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"instance","value":"web2"}]}]}`,
+			code: 200,
+			resp: `{"results":[{"id":"1","cluster":"foo","alertmanager":"","silenceID":"","skipped":"","error":"unknown cluster \"foo\""}]}`,
+		},
+		{
+			name: "silence without matchers",
+			body: `{"silences":[{"cluster":"default","id":"1","endsAt":"` + endsAt + `","createdBy":"me","comment":"foo"}]}`,
+			code: 200,
+			resp: `{"results":[{"id":"1","cluster":"default","alertmanager":"","silenceID":"","skipped":"","error":"silence requires at least one matcher"}]}`,
+		},
+		{
+			name:  "silence is imported into another cluster with renamed author",
+			query: "?cluster=default&createdBy=me=you",
+			body: "silences:\n" +
+				"  - cluster: foo\n" +
+				"    id: \"1\"\n" +
+				"    endsAt: " + endsAt + "\n" +
+				"    createdBy: me\n" +
+				"    comment: foo\n" +
+				"    matchers:\n" +
+				"      - name: instance\n" +
+				"        value: web2\n" +
+				// duplicate of the silence above
+				"  - cluster: foo\n" +
+				"    id: \"2\"\n" +
+				"    endsAt: " + endsAt + "\n" +
+				"    createdBy: me\n" +
+				"    comment: foo\n" +
+				"    matchers:\n" +
+				"      - name: instance\n" +
+				"        value: web2\n",
+			code: 200,
+			resp: `{"results":[` +
+				`{"id":"1","cluster":"default","alertmanager":"default","silenceID":"1234","skipped":"","error":""},` +
+				`{"id":"2","cluster":"default","alertmanager":"","silenceID":"","skipped":"duplicate of silence 1234","error":""}]}`,
+			requests: []string{`"createdBy":"you"`},
+		},
+		{
+			name:  "dry run",
+			query: "?dryRun=true",
+			body:  `{"silences":[{"cluster":"default","id":"1","endsAt":"` + endsAt + `","createdBy":"me","comment":"foo","matchers":[{"name":"instance","value":"web2","isEqual":false}]}]}`,
+			code:  200,
+			resp:  `{"results":[{"id":"1","cluster":"default","alertmanager":"","silenceID":"","skipped":"","error":""}]}`,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAlerts("0.31.0")
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			requests := []string{}
+			httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				requests = append(requests, string(body))
+				return httpmock.NewStringResponse(200, `{"silenceID":"1234"}`), nil
+			})
+
+			r := testRouter()
+			setupRouter(r, nil)
+			req := httptest.NewRequest("POST", "/silences/import.json"+tc.query, strings.NewReader(tc.body))
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("POST /silences/import.json returned status %d, expected %d", resp.Code, tc.code)
+			}
+			if diff := cmp.Diff(tc.resp, resp.Body.String()); diff != "" {
+				t.Errorf("Wrong response (-want +got):\n%s", diff)
+			}
+			if len(requests) != len(tc.requests) {
+				t.Fatalf("Expected %d request(s) sent to Alertmanager, got %d", len(tc.requests), len(requests))
+			}
+			for i, body := range requests {
+				if !strings.Contains(body, tc.requests[i]) {
+					t.Errorf("Silence sent to Alertmanager doesn't contain %s: %s", tc.requests[i], body)
+				}
+			}
+		})
+	}
+}
+
+func TestSilencesCommand(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	r := testRouter()
+	setupRouter(r, nil)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	exportPath := path.Join(dir, "silences.yaml")
+
+	type testCaseT struct {
+		name   string
+		args   []string
+		stdout string
+		err    string
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "no subcommand",
+			err:  "silences command requires a subcommand: export or import",
+		},
+		{
+			name: "unknown subcommand",
+			args: []string{"foo"},
+			err:  `unknown silences subcommand "foo", must be export or import`,
+		},
+		{
+			name: "invalid export format",
+			args: []string{"export", "--karma.uri", srv.URL, "--export.format", "xml"},
+			err:  `unsupported export format "xml", must be json or yaml`,
+		},
+		{
+			name: "invalid header",
+			args: []string{"export", "--karma.uri", srv.URL, "--karma.headers", "foo"},
+			err:  `invalid header "foo", expected 'Name: value'`,
+		},
+		{
+			name: "export",
+			args: []string{"export", "--karma.uri", srv.URL, "--export.output", exportPath, "--karma.headers", "X-Foo: bar"},
+		},
+		{
+			name: "import without file",
+			args: []string{"import", "--karma.uri", srv.URL},
+			err:  "--import.file is required",
+		},
+		{
+			name: "import",
+			args: []string{"import", "--karma.uri", srv.URL, "--import.file", exportPath, "--import.dryRun"},
+			stdout: "skipped silence 810ccf7f-c957-474a-b383-7e76d66a4d3b in default: duplicate of silence 810ccf7f-c957-474a-b383-7e76d66a4d3b\n" +
+				"skipped silence 9bd58938-25fd-41c5-aba3-9bc373074484 in default: duplicate of silence 9bd58938-25fd-41c5-aba3-9bc373074484\n" +
+				"skipped silence dcb3b5d0-9f10-4baa-977a-70073a1899bd in default: duplicate of silence dcb3b5d0-9f10-4baa-977a-70073a1899bd\n",
+		},
+		{
+			name:   "import into unknown cluster",
+			args:   []string{"import", "--karma.uri", srv.URL, "--import.file", exportPath, "--import.cluster", "foo", "--import.dryRun"},
+			stdout: "failed to import silence 810ccf7f-c957-474a-b383-7e76d66a4d3b into foo: unknown cluster \"foo\"\nfailed to import silence 9bd58938-25fd-41c5-aba3-9bc373074484 into foo: unknown cluster \"foo\"\nfailed to import silence dcb3b5d0-9f10-4baa-977a-70073a1899bd into foo: unknown cluster \"foo\"\n",
+			err:    "failed to import 3 silence(s)",
+		},
+		{
+			name: "server error",
+			args: []string{"import", "--karma.uri", srv.URL, "--import.file", exportPath, "--import.createdBy", "foo"},
+			err:  "POST " + srv.URL + "/silences/import.json?createdBy=foo returned status code 400: {\"error\":\"invalid createdBy value \\\"foo\\\", expected old=new\"}",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			var errMsg string
+			if err := silencesCommand(tc.args, &stdout, pflag.ContinueOnError); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.err {
+				t.Errorf("silencesCommand() returned %q, expected %q", errMsg, tc.err)
+			}
+			if diff := cmp.Diff(tc.stdout, stdout.String()); diff != "" {
+				t.Errorf("Wrong output (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export SilenceExport
+	if err = json.Unmarshal(data, &export); err == nil {
+		t.Errorf("Default export format should be YAML")
+	}
+	if export, err = parseSilenceExport(bytes.NewReader(data)); err != nil || len(export.Silences) != 3 {
+		t.Errorf("Wrong export written to %s: %v", exportPath, err)
+	}
+}
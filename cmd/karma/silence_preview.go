@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+type SilencePreviewRequest struct {
+	Clusters []string                `json:"clusters"`
+	Matchers []SilenceRequestMatcher `json:"matchers"`
+}
+
+type SilencePreviewAlert struct {
+	Labels        models.OrderedLabels `json:"labels"`
+	State         string               `json:"state"`
+	Receivers     []string             `json:"receivers"`
+	Alertmanagers []string             `json:"alertmanagers"`
+	SilencedBy    []string             `json:"silencedBy"`
+	InhibitedBy   []string             `json:"inhibitedBy"`
+}
+
+type SilencePreviewCluster struct {
+	Cluster string                `json:"cluster"`
+	Alerts  []SilencePreviewAlert `json:"alerts"`
+}
+
+type SilencePreviewResponse struct {
+	Clusters []SilencePreviewCluster `json:"clusters"`
+	Warning  string                  `json:"warning"`
+	Matched  int                     `json:"matched"`
+	Total    int                     `json:"total"`
+}
+
+// appendUnique adds all values missing from dst and returns dst sorted
+func appendUnique(dst []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(dst, v) {
+			dst = append(dst, v)
+		}
+	}
+	slices.Sort(dst)
+	return dst
+}
+
+// previewSilence returns all alerts that would be muted by a silence with
+// given matchers, grouped by cluster, alerts that are already silenced or
+// inhibited are included
+func previewSilence(groups []models.AlertGroup, matchers []models.SilenceMatcher, clusters []string) SilencePreviewResponse {
+	silence := models.Silence{Matchers: matchers}
+
+	type clusterAlert struct {
+		cluster string
+		labels  string
+	}
+	matched := map[clusterAlert]*SilencePreviewAlert{}
+	total := map[clusterAlert]struct{}{}
+
+	for _, ag := range groups {
+		for _, alert := range ag.Alerts {
+			b := promlabels.NewBuilder(ag.Labels)
+			alert.Labels.Range(func(l promlabels.Label) {
+				b.Set(l.Name, l.Value)
+			})
+			lbls := b.Labels()
+			isMatch := silence.IsMatch(lbls.Map())
+
+			for _, am := range alert.Alertmanager {
+				if len(clusters) > 0 && !slices.Contains(clusters, am.Cluster) {
+					continue
+				}
+				key := clusterAlert{cluster: am.Cluster, labels: lbls.String()}
+				total[key] = struct{}{}
+				if !isMatch {
+					continue
+				}
+
+				pa, ok := matched[key]
+				if !ok {
+					pa = &SilencePreviewAlert{
+						Labels:        models.LabelsToOrderedLabels(lbls),
+						State:         am.State.String(),
+						Receivers:     []string{},
+						Alertmanagers: []string{},
+						SilencedBy:    []string{},
+						InhibitedBy:   []string{},
+					}
+					matched[key] = pa
+				}
+				// alert is active if it's active on any instance
+				if am.State == models.AlertStateActive {
+					pa.State = am.State.String()
+				}
+				pa.Receivers = appendUnique(pa.Receivers, alert.Receiver)
+				pa.Alertmanagers = appendUnique(pa.Alertmanagers, am.Name)
+				pa.SilencedBy = appendUnique(pa.SilencedBy, am.SilencedBy...)
+				pa.InhibitedBy = appendUnique(pa.InhibitedBy, am.InhibitedBy...)
+			}
+		}
+	}
+
+	keys := make([]clusterAlert, 0, len(matched))
+	for key := range matched {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		return keys[i].labels < keys[j].labels
+	})
+
+	resp := SilencePreviewResponse{
+		Clusters: []SilencePreviewCluster{},
+		Matched:  len(matched),
+		Total:    len(total),
+	}
+	for _, key := range keys {
+		if n := len(resp.Clusters); n == 0 || resp.Clusters[n-1].Cluster != key.cluster {
+			resp.Clusters = append(resp.Clusters, SilencePreviewCluster{Cluster: key.cluster})
+		}
+		pc := &resp.Clusters[len(resp.Clusters)-1]
+		pc.Alerts = append(pc.Alerts, *matched[key])
+	}
+
+	warnPercent := config.Config.Silences.Preview.WarnPercent
+	if warnPercent > 0 && resp.Total > 0 && resp.Matched*100 > resp.Total*warnPercent {
+		resp.Warning = fmt.Sprintf(
+			"this silence would match %d out of %d alerts (%d%%), which is more than %d%%, consider using more specific matchers",
+			resp.Matched, resp.Total, resp.Matched*100/resp.Total, warnPercent)
+	}
+
+	return resp
+}
+
+// show which alerts would be muted by a silence with matchers passed in the
+// request body
+func silencePreview(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	var req SilencePreviewRequest
+	err := jsonv2.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	matchers, err := toSilenceMatchers(req.Matchers)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	upstreams := alertmanager.GetAlertmanagers()
+	for _, cluster := range req.Clusters {
+		if !slices.ContainsFunc(upstreams, func(am *alertmanager.Alertmanager) bool { return am.Cluster == cluster }) {
+			badRequestJSON(w, fmt.Sprintf("unknown cluster %q", cluster))
+			return
+		}
+	}
+
+	resp := previewSilence(alertmanager.DedupAlerts(), matchers, req.Clusters)
+
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	data, _ := marshalJSON(resp)
+	_, _ = w.Write(data)
+}
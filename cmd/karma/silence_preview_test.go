@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+func TestSilencePreview(t *testing.T) {
+	type testCaseT struct {
+		name string
+		body string
+		code int
+		resp SilencePreviewResponse
+		err  string
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "invalid body",
+			body: `{"matchers":`,
+			code: 400,
+			err:  `{"error":"jsontext: unexpected EOF within \"/matchers\" after offset 12"}`,
+		},
+		{
+			name: "no matchers",
+			body: `{"matchers":[]}`,
+			code: 400,
+			err:  `{"error":"silence requires at least one matcher"}`,
+		},
+		{
+			name: "unknown cluster",
+			body: `{"clusters":["foo"],"matchers":[{"name":"alertname","value":"Host_Down"}]}`,
+			code: 400,
+			err:  `{"error":"unknown cluster \"foo\""}`,
+		},
+		{
+			name: "no alerts matched",
+			body: `{"matchers":[{"name":"alertname","value":"Foo"}]}`,
+			code: 200,
+			resp: SilencePreviewResponse{Clusters: []SilencePreviewCluster{}, Total: 12},
+		},
+		{
+			name: "silenced alert is included",
+			body: `{"clusters":["default"],"matchers":[{"name":"instance","value":"server7"}]}`,
+			code: 200,
+			resp: SilencePreviewResponse{
+				Clusters: []SilencePreviewCluster{
+					{
+						Cluster: "default",
+						Alerts: []SilencePreviewAlert{
+							{
+								Labels: models.OrderedLabels{
+									{Name: "alertname", Value: "Host_Down"},
+									{Name: "cluster", Value: "dev"},
+									{Name: "instance", Value: "server7"},
+									{Name: "ip", Value: "127.0.0.7"},
+									{Name: "job", Value: "node_ping"},
+								},
+								State:         "suppressed",
+								Receivers:     []string{"by-cluster-service", "by-name"},
+								Alertmanagers: []string{"default"},
+								SilencedBy:    []string{"9bd58938-25fd-41c5-aba3-9bc373074484", "dcb3b5d0-9f10-4baa-977a-70073a1899bd"},
+								InhibitedBy:   []string{},
+							},
+						},
+					},
+				},
+				Matched: 1,
+				Total:   12,
+			},
+		},
+	}
+
+	mockConfig(t.Setenv)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAlerts("0.31.0")
+			r := testRouter()
+			setupRouter(r, nil)
+
+			req := httptest.NewRequest("POST", "/silences/preview.json", bytes.NewBufferString(tc.body))
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("POST /silences/preview.json returned status %d, expected %d", resp.Code, tc.code)
+			}
+
+			if tc.err != "" {
+				if diff := cmp.Diff(tc.err, resp.Body.String()); diff != "" {
+					t.Errorf("Wrong error returned (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			var ur SilencePreviewResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &ur); err != nil {
+				t.Fatalf("Failed to unmarshal response: %s", err)
+			}
+			if diff := cmp.Diff(tc.resp, ur); diff != "" {
+				t.Errorf("Wrong preview returned (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSilencePreviewWarning(t *testing.T) {
+	type testCaseT struct {
+		name        string
+		warnPercent int
+		matchers    []models.SilenceMatcher
+		warning     string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:        "broad matchers",
+			warnPercent: 25,
+			matchers:    []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Host_Down", false, true)},
+			warning:     "this silence would match 8 out of 12 alerts (66%), which is more than 25%, consider using more specific matchers",
+		},
+		{
+			name:        "broad matchers with warning disabled",
+			warnPercent: 0,
+			matchers:    []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Host_Down", false, true)},
+		},
+		{
+			name:        "matchers below threshold",
+			warnPercent: 25,
+			matchers:    []models.SilenceMatcher{models.NewSilenceMatcher("cluster", "prod", false, true)},
+		},
+		{
+			name:        "negative matcher",
+			warnPercent: 50,
+			matchers:    []models.SilenceMatcher{models.NewSilenceMatcher("cluster", "prod", false, false)},
+			warning:     "this silence would match 9 out of 12 alerts (75%), which is more than 50%, consider using more specific matchers",
+		},
+	}
+
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	defer func() { config.Config.Silences.Preview.WarnPercent = 0 }()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Config.Silences.Preview.WarnPercent = tc.warnPercent
+			resp := previewSilence(alertmanager.DedupAlerts(), tc.matchers, nil)
+			if resp.Warning != tc.warning {
+				t.Errorf("Wrong warning returned, got %q, expected %q", resp.Warning, tc.warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+// SilenceReminder is the body of each webhook request sent for silences that
+// are about to expire
+type SilenceReminder struct {
+	Cluster   string         `json:"cluster"`
+	Silence   models.Silence `json:"silence"`
+	ExtendURL string         `json:"extendURL"`
+}
+
+type silenceReminder struct {
+	now      func() time.Time
+	client   *http.Client
+	tracked  map[silenceKey]time.Time
+	reminded map[silenceKey]time.Time
+	webhook  string
+	baseURL  string
+	secret   []byte
+	extendBy time.Duration
+	selector []silenceFilter
+	before   time.Duration
+	lock     sync.Mutex
+}
+
+var silenceReminders = &silenceReminder{}
+
+func newSilenceReminder(cfg config.SilenceRemindersConfig) (*silenceReminder, error) {
+	r := silenceReminder{
+		now:      time.Now,
+		client:   &http.Client{Timeout: cfg.Webhook.Timeout},
+		tracked:  map[silenceKey]time.Time{},
+		reminded: map[silenceKey]time.Time{},
+		webhook:  cfg.Webhook.URI,
+		baseURL:  strings.TrimSuffix(cfg.ExtendLink.BaseURL, "/"),
+		secret:   []byte(cfg.ExtendLink.Secret),
+		extendBy: cfg.ExtendLink.Duration,
+		before:   cfg.Before,
+	}
+	for _, filter := range cfg.Selector {
+		f, err := newSilenceFilterFromConfig(filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid silence reminders selector: %w", err)
+		}
+		r.selector = append(r.selector, f)
+	}
+	return &r, nil
+}
+
+func (r *silenceReminder) isEnabled() bool {
+	return r.before > 0
+}
+
+// isMatch returns true if silence matches all selector filters, empty
+// selector doesn't match anything
+func (r *silenceReminder) isMatch(silence *models.Silence) bool {
+	if len(r.selector) == 0 {
+		return false
+	}
+	for _, f := range r.selector {
+		if !f.isMatch(silence) {
+			return false
+		}
+	}
+	return true
+}
+
+// track marks a silence created via karma so its author will be reminded
+// before it expires
+func (r *silenceReminder) track(cluster, id string) {
+	if id == "" || !r.isEnabled() {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.tracked[silenceKey{cluster: cluster, id: id}]; !ok {
+		r.tracked[silenceKey{cluster: cluster, id: id}] = r.now()
+	}
+}
+
+func (r *silenceReminder) signature(cluster, id string, endsAt int64) string {
+	mac := hmac.New(sha256.New, r.secret)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%d", cluster, id, endsAt)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// extendURL returns a signed link to a page that allows to extend given
+// silence, link is only valid until the silence is modified
+func (r *silenceReminder) extendURL(cluster string, silence models.Silence) string {
+	if len(r.secret) == 0 {
+		return ""
+	}
+	endsAt := silence.EndsAt.Unix()
+	q := url.Values{}
+	q.Set("cluster", cluster)
+	q.Set("id", silence.ID)
+	q.Set("endsAt", strconv.FormatInt(endsAt, 10))
+	q.Set("signature", r.signature(cluster, silence.ID, endsAt))
+	return r.baseURL + "/silences/extend?" + q.Encode()
+}
+
+// due returns all silences that are expiring soon and their authors
+// weren't notified yet
+func (r *silenceReminder) due(silences []models.ManagedSilence) []models.ManagedSilence {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	seen := map[silenceKey]struct{}{}
+	due := []models.ManagedSilence{}
+	for _, ms := range silences {
+		key := silenceKey{cluster: ms.Cluster, id: ms.Silence.ID}
+		if ms.IsExpired {
+			continue
+		}
+		seen[key] = struct{}{}
+		// the author of the source silence will get a reminder
+		if _, _, ok := parseSilenceSyncTag(ms.Silence); ok {
+			continue
+		}
+		if _, ok := r.tracked[key]; !ok && !r.isMatch(&ms.Silence) {
+			continue
+		}
+		if ms.Silence.EndsAt.Sub(now) > r.before {
+			continue
+		}
+		if endsAt, ok := r.reminded[key]; ok && endsAt.Equal(ms.Silence.EndsAt) {
+			continue
+		}
+		due = append(due, ms)
+	}
+
+	for key, trackedAt := range r.tracked {
+		if _, ok := seen[key]; !ok && now.Sub(trackedAt) > silenceTrackTimeout {
+			delete(r.tracked, key)
+		}
+	}
+	for key := range r.reminded {
+		if _, ok := seen[key]; !ok {
+			delete(r.reminded, key)
+		}
+	}
+
+	return due
+}
+
+func (r *silenceReminder) send(ms models.ManagedSilence) error {
+	body, err := marshalJSON(SilenceReminder{
+		Cluster:   ms.Cluster,
+		Silence:   ms.Silence,
+		ExtendURL: r.extendURL(ms.Cluster, ms.Silence),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, r.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// check sends a reminder for every silence that is about to expire, failed
+// reminders will be retried on the next call
+func (r *silenceReminder) check(silences []models.ManagedSilence) {
+	if !r.isEnabled() {
+		return
+	}
+
+	for _, ms := range r.due(silences) {
+		if err := r.send(ms); err != nil {
+			slog.Error(
+				"Failed to send silence expiry reminder",
+				slog.Any("error", err),
+				slog.String("cluster", ms.Cluster),
+				slog.String("silence", ms.Silence.ID),
+			)
+			continue
+		}
+		slog.Info(
+			"Sent silence expiry reminder",
+			slog.String("cluster", ms.Cluster),
+			slog.String("silence", ms.Silence.ID),
+			slog.String("createdBy", ms.Silence.CreatedBy),
+			slog.Time("endsAt", ms.Silence.EndsAt),
+		)
+		r.lock.Lock()
+		r.reminded[silenceKey{cluster: ms.Cluster, id: ms.Silence.ID}] = ms.Silence.EndsAt
+		r.lock.Unlock()
+	}
+}
+
+var silenceExtendTemplate = template.Must(template.New("extend").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Extend silence</title>
+</head>
+<body>
+<h1>Extend silence {{ .ID }}</h1>
+<p>Cluster: {{ .Cluster }}</p>
+<p>Created by: {{ .CreatedBy }}</p>
+<p>Comment: {{ .Comment }}</p>
+<p>Ends at: {{ .EndsAt }}</p>
+<p>New end time: {{ .NewEndsAt }}</p>
+<form method="post" action="{{ .Action }}">
+<input type="hidden" name="cluster" value="{{ .Cluster }}">
+<input type="hidden" name="id" value="{{ .ID }}">
+<input type="hidden" name="endsAt" value="{{ .EndsAtUnix }}">
+<input type="hidden" name="signature" value="{{ .Signature }}">
+<button type="submit">Extend by {{ .Duration }}</button>
+</form>
+</body>
+</html>
+`))
+
+// silenceExtendLinkLookup verifies the signature of an extension link and
+// returns the silence it was created for
+func silenceExtendLinkLookup(r *http.Request) (*models.ManagedSilence, error) {
+	if len(silenceReminders.secret) == 0 {
+		return nil, errors.New("silence extension links are not enabled")
+	}
+
+	cluster, id := r.FormValue("cluster"), r.FormValue("id")
+	endsAt, err := strconv.ParseInt(r.FormValue("endsAt"), 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid endsAt value")
+	}
+	if !hmac.Equal([]byte(r.FormValue("signature")), []byte(silenceReminders.signature(cluster, id, endsAt))) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var found *models.ManagedSilence
+	for _, ms := range alertmanager.DedupSilences() {
+		if ms.Cluster == cluster && ms.Silence.ID == id {
+			found = &ms
+			break
+		}
+	}
+	switch {
+	case found == nil:
+		return nil, errors.New("silence not found")
+	case found.IsExpired:
+		return nil, errors.New("silence is already expired")
+	case found.Silence.EndsAt.Unix() != endsAt:
+		return nil, errors.New("silence was modified after this link was created")
+	}
+	return found, nil
+}
+
+// silences are extended by their original duration, the configured duration
+// is only used for silences that don't have a valid one
+func (sr *silenceReminder) extendDuration(silence models.Silence) time.Duration {
+	if d := silence.EndsAt.Sub(silence.StartsAt); !silence.StartsAt.IsZero() && d > 0 {
+		return d
+	}
+	return sr.extendBy
+}
+
+// show a confirmation page for a signed link sent with the expiry reminder,
+// links can be opened by chat or email previews so they must not modify
+// anything
+func silenceExtendLinkConfirm(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	found, err := silenceExtendLinkLookup(r)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	extendBy := silenceReminders.extendDuration(found.Silence)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = silenceExtendTemplate.Execute(w, map[string]any{
+		"Action":     getViewURL("/silences/extend"),
+		"Cluster":    found.Cluster,
+		"ID":         found.Silence.ID,
+		"CreatedBy":  found.Silence.CreatedBy,
+		"Comment":    found.Silence.Comment,
+		"EndsAt":     found.Silence.EndsAt.UTC().Format(time.RFC3339),
+		"NewEndsAt":  found.Silence.EndsAt.Add(extendBy).UTC().Format(time.RFC3339),
+		"EndsAtUnix": found.Silence.EndsAt.Unix(),
+		"Signature":  r.FormValue("signature"),
+		"Duration":   extendBy.String(),
+	})
+}
+
+// extend a silence using a signed link sent with the expiry reminder, this is
+// submitted from the confirmation page
+func silenceExtendLink(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	found, err := silenceExtendLinkLookup(r)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+	cluster, id := found.Cluster, found.Silence.ID
+
+	silence := found.Silence
+	silence.Matchers = slices.Clone(found.Silence.Matchers)
+	silence.EndsAt = silence.EndsAt.Add(silenceReminders.extendDuration(found.Silence))
+	if config.Config.Authentication.Enabled {
+		silence.CreatedBy = getUserFromContext(r)
+	}
+
+	members := writableClusterMembers(cluster, alertmanager.GetAlertmanagers())
+	if len(members) == 0 {
+		badRequestJSON(w, fmt.Sprintf("all Alertmanager instances in cluster %q are read-only", cluster))
+		return
+	}
+	if err = checkSilenceTemplates(&silence); err == nil {
+		err = checkMembersSilenceACLs(members, &silence, getGroupsFromContext(r))
+	}
+	if err != nil {
+		slog.Warn(
+			"Silence extension was rejected",
+			slog.Any("error", err),
+			slog.String("cluster", cluster),
+			slog.String("silence", id),
+		)
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	result := SilenceBulkResult{ID: id, Cluster: cluster}
+	status := http.StatusOK
+	result.Alertmanager, result.SilenceID, err = runOnClusterMembers(cluster, "extend", members, func(am *alertmanager.Alertmanager) (string, error) {
+		return am.CreateSilence(silence)
+	})
+	if err != nil {
+		result.Error = err.Error()
+		status = http.StatusBadGateway
+	} else {
+		trackCreatedSilence(cluster, result.SilenceID)
+		slog.Info(
+			"Silence extended using a reminder link",
+			slog.String("cluster", cluster),
+			slog.String("silence", result.SilenceID),
+			slog.Time("endsAt", silence.EndsAt),
+		)
+	}
+
+	data, _ := marshalJSON(result)
+	mimeJSON(w)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jarcoal/httpmock"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+func mockSilenceReminder(t *testing.T, now time.Time, selector ...config.SilenceFilters) *silenceReminder {
+	cfg := config.SilenceRemindersConfig{Before: time.Hour, Selector: selector}
+	cfg.Webhook.URI = "http://webhook.example.com/remind"
+	cfg.Webhook.Timeout = time.Second
+	cfg.ExtendLink.Secret = "secret"
+	cfg.ExtendLink.BaseURL = "https://karma.example.com/"
+	cfg.ExtendLink.Duration = time.Hour * 24
+	r, err := newSilenceReminder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return now }
+	return r
+}
+
+func TestNewSilenceReminder(t *testing.T) {
+	cfg := config.SilenceRemindersConfig{Selector: []config.SilenceFilters{{Name: "alertname"}}}
+	_, err := newSilenceReminder(cfg)
+	expected := "invalid silence reminders selector: silence ACL rule filter requires 'value' or 'value_re' to be set"
+	if err == nil || err.Error() != expected {
+		t.Errorf("newSilenceReminder() returned %v, expected %q", err, expected)
+	}
+}
+
+func TestSilenceReminderDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newSilence := func(cluster, id, comment string, endsAt time.Time, matchers ...models.SilenceMatcher) models.ManagedSilence {
+		return models.ManagedSilence{
+			Cluster:   cluster,
+			IsExpired: endsAt.Before(now),
+			Silence: models.Silence{
+				ID:       id,
+				StartsAt: now.Add(-time.Hour),
+				EndsAt:   endsAt,
+				Comment:  comment,
+				Matchers: matchers,
+			},
+		}
+	}
+	alertnameFoo := models.NewSilenceMatcher("alertname", "Foo", false, true)
+
+	type testCaseT struct {
+		name     string
+		silences []models.ManagedSilence
+		tracked  []silenceKey
+		reminded map[silenceKey]time.Time
+		selector []config.SilenceFilters
+		due      []string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:     "untracked silence is ignored",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "", now.Add(time.Minute))},
+			due:      []string{},
+		},
+		{
+			name:     "tracked silence expiring soon",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "", now.Add(time.Minute))},
+			tracked:  []silenceKey{{cluster: "prod", id: "1"}},
+			due:      []string{"1"},
+		},
+		{
+			name:     "tracked silence not expiring soon",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "", now.Add(time.Hour*2))},
+			tracked:  []silenceKey{{cluster: "prod", id: "1"}},
+			due:      []string{},
+		},
+		{
+			name:     "expired silence is ignored",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "", now.Add(-time.Minute))},
+			tracked:  []silenceKey{{cluster: "prod", id: "1"}},
+			due:      []string{},
+		},
+		{
+			name: "silence matching selector",
+			silences: []models.ManagedSilence{
+				newSilence("prod", "1", "", now.Add(time.Minute), alertnameFoo),
+				newSilence("prod", "2", "", now.Add(time.Minute)),
+			},
+			selector: []config.SilenceFilters{{Name: "alertname", Value: "Foo"}},
+			due:      []string{"1"},
+		},
+		{
+			name:     "replica is ignored",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "foo [karma sync: dev/2]", now.Add(time.Minute), alertnameFoo)},
+			selector: []config.SilenceFilters{{Name: "alertname", Value: "Foo"}},
+			due:      []string{},
+		},
+		{
+			name:     "already reminded",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "", now.Add(time.Minute))},
+			tracked:  []silenceKey{{cluster: "prod", id: "1"}},
+			reminded: map[silenceKey]time.Time{{cluster: "prod", id: "1"}: now.Add(time.Minute)},
+			due:      []string{},
+		},
+		{
+			name:     "reminded before it was extended",
+			silences: []models.ManagedSilence{newSilence("prod", "1", "", now.Add(time.Minute*30))},
+			tracked:  []silenceKey{{cluster: "prod", id: "1"}},
+			reminded: map[silenceKey]time.Time{{cluster: "prod", id: "1"}: now.Add(time.Minute)},
+			due:      []string{"1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := mockSilenceReminder(t, now, tc.selector...)
+			for _, key := range tc.tracked {
+				r.track(key.cluster, key.id)
+			}
+			for key, endsAt := range tc.reminded {
+				r.reminded[key] = endsAt
+			}
+			due := []string{}
+			for _, ms := range r.due(tc.silences) {
+				due = append(due, ms.Silence.ID)
+			}
+			if diff := cmp.Diff(tc.due, due); diff != "" {
+				t.Errorf("Wrong silences due for a reminder (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSilenceReminderCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := mockSilenceReminder(t, now)
+	r.track("prod", "1")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	requests := []string{}
+	code := 500
+	httpmock.RegisterResponder("POST", "http://webhook.example.com/remind", func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		requests = append(requests, string(body))
+		return httpmock.NewStringResponse(code, ""), nil
+	})
+
+	silences := []models.ManagedSilence{
+		{
+			Cluster: "prod",
+			Silence: models.Silence{
+				ID:        "1",
+				StartsAt:  now.Add(-time.Hour),
+				EndsAt:    now.Add(time.Minute),
+				CreatedBy: "me",
+				Comment:   "foo",
+				Matchers:  []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Foo", false, true)},
+			},
+		},
+	}
+
+	// failed reminders are retried
+	r.check(silences)
+	code = 200
+	r.check(silences)
+	r.check(silences)
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 webhook requests, got %d", len(requests))
+	}
+
+	expected := `{"cluster":"prod","silence":{"startsAt":"2024-01-01T11:00:00Z","endsAt":"2024-01-01T12:01:00Z","createdAt":"0001-01-01T00:00:00Z",` +
+		`"id":"1","createdBy":"me","comment":"foo","ticketID":"","ticketURL":"","matchers":[{"name":"alertname","value":"Foo","isRegex":false,"isEqual":true}]},` +
+		`"extendURL":"https://karma.example.com/silences/extend?cluster=prod\u0026endsAt=1704110460\u0026id=1\u0026signature=` + r.signature("prod", "1", 1704110460) + `"}`
+	if diff := cmp.Diff(expected, requests[1]); diff != "" {
+		t.Errorf("Wrong webhook request body (-want +got):\n%s", diff)
+	}
+}
+
+func TestSilenceExtendLink(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+	silenceACLs = []*silenceACL{}
+
+	var silence models.Silence
+	for _, ms := range alertmanager.DedupSilences() {
+		if ms.Silence.ID == "810ccf7f-c957-474a-b383-7e76d66a4d3b" {
+			silence = ms.Silence
+		}
+	}
+	// silences are extended by their original duration
+	extendBy := silence.EndsAt.Sub(silence.StartsAt)
+	newEndsAt := silence.EndsAt.Add(extendBy).UTC().Format("2006-01-02T15:04:05.000Z")
+
+	type testCaseT struct {
+		name      string
+		reminders *silenceReminder
+		method    string
+		url       func(r *silenceReminder) string
+		code      int
+		resp      string
+		html      []string
+		sent      string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:      "links are disabled",
+			reminders: &silenceReminder{},
+			url:       func(_ *silenceReminder) string { return "/silences/extend?cluster=default&id=1&endsAt=1&signature=foo" },
+			code:      400,
+			resp:      `{"error":"silence extension links are not enabled"}`,
+		},
+		{
+			name:      "invalid endsAt",
+			reminders: mockSilenceReminder(t, time.Now()),
+			url: func(_ *silenceReminder) string {
+				return "/silences/extend?cluster=default&id=1&endsAt=foo&signature=foo"
+			},
+			code: 400,
+			resp: `{"error":"invalid endsAt value"}`,
+		},
+		{
+			name:      "invalid signature",
+			reminders: mockSilenceReminder(t, time.Now()),
+			url: func(r *silenceReminder) string {
+				return strings.Replace(r.extendURL("default", silence), "id=810ccf7f", "id=910ccf7f", 1)
+			},
+			code: 400,
+			resp: `{"error":"invalid signature"}`,
+		},
+		{
+			name:      "silence not found",
+			reminders: mockSilenceReminder(t, time.Now()),
+			url: func(r *silenceReminder) string {
+				return strings.TrimPrefix(r.extendURL("foo", silence), "https://karma.example.com")
+			},
+			code: 400,
+			resp: `{"error":"silence not found"}`,
+		},
+		{
+			name:      "silence was modified",
+			reminders: mockSilenceReminder(t, time.Now()),
+			url: func(r *silenceReminder) string {
+				s := silence
+				s.EndsAt = s.EndsAt.Add(time.Hour)
+				return strings.TrimPrefix(r.extendURL("default", s), "https://karma.example.com")
+			},
+			code: 400,
+			resp: `{"error":"silence was modified after this link was created"}`,
+		},
+		{
+			name:      "link shows confirmation page",
+			reminders: mockSilenceReminder(t, time.Now()),
+			url: func(r *silenceReminder) string {
+				return strings.TrimPrefix(r.extendURL("default", silence), "https://karma.example.com")
+			},
+			code: 200,
+			html: []string{
+				`<form method="post" action="/silences/extend">`,
+				`<input type="hidden" name="id" value="810ccf7f-c957-474a-b383-7e76d66a4d3b">`,
+				`<p>New end time: ` + silence.EndsAt.Add(extendBy).UTC().Format(time.RFC3339) + `</p>`,
+				`<button type="submit">Extend by ` + extendBy.String() + `</button>`,
+			},
+		},
+		{
+			name:      "invalid signature on submit",
+			reminders: mockSilenceReminder(t, time.Now()),
+			method:    "POST",
+			url: func(r *silenceReminder) string {
+				return strings.Replace(r.extendURL("default", silence), "id=810ccf7f", "id=910ccf7f", 1)
+			},
+			code: 400,
+			resp: `{"error":"invalid signature"}`,
+		},
+		{
+			name:      "silence is extended",
+			reminders: mockSilenceReminder(t, time.Now()),
+			method:    "POST",
+			url: func(r *silenceReminder) string {
+				return strings.TrimPrefix(r.extendURL("default", silence), "https://karma.example.com")
+			},
+			code: 200,
+			resp: `{"id":"810ccf7f-c957-474a-b383-7e76d66a4d3b","cluster":"default","alertmanager":"default","silenceID":"1234","error":""}`,
+			sent: `"endsAt":"` + newEndsAt + `"`,
+		},
+	}
+
+	defer func() { silenceReminders = &silenceReminder{} }()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			silenceReminders = tc.reminders
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			var sent string
+			httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				sent = string(body)
+				return httpmock.NewStringResponse(200, `{"silenceID":"1234"}`), nil
+			})
+
+			r := testRouter()
+			setupRouter(r, nil)
+			var req *http.Request
+			if tc.method == "POST" {
+				// confirmation page submits link arguments as a form
+				path, query, _ := strings.Cut(tc.url(tc.reminders), "?")
+				req = httptest.NewRequest("POST", path, strings.NewReader(query))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest("GET", tc.url(tc.reminders), nil)
+			}
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("%s /silences/extend returned status %d, expected %d", req.Method, resp.Code, tc.code)
+			}
+			if len(tc.html) > 0 {
+				for _, line := range tc.html {
+					if !strings.Contains(resp.Body.String(), line) {
+						t.Errorf("Response doesn't contain %s: %s", line, resp.Body.String())
+					}
+				}
+			} else if diff := cmp.Diff(tc.resp, resp.Body.String()); diff != "" {
+				t.Errorf("Wrong response (-want +got):\n%s", diff)
+			}
+			switch {
+			case tc.sent == "" && sent != "":
+				t.Errorf("Silence was sent to Alertmanager: %s", sent)
+			case !strings.Contains(sent, tc.sent):
+				t.Errorf("Silence sent to Alertmanager doesn't contain %s: %s", tc.sent, sent)
+			}
+		})
+	}
+}
+
+func TestSilenceReminderExtendDuration(t *testing.T) {
+	r := mockSilenceReminder(t, time.Now())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if d := r.extendDuration(models.Silence{StartsAt: start, EndsAt: start.Add(time.Hour * 2)}); d != time.Hour*2 {
+		t.Errorf("extendDuration() returned %s, expected 2h0m0s", d)
+	}
+	if d := r.extendDuration(models.Silence{EndsAt: start}); d != time.Hour*24 {
+		t.Errorf("extendDuration() returned %s for silence without start time, expected 24h0m0s", d)
+	}
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/cron"
+)
+
+// silenceScheduleTag is appended to the comment of every silence created from
+// a schedule, so those are easy to find using silence search
+const silenceScheduleTag = "[karma schedule: %s]"
+
+var (
+	errScheduleNotFound = errors.New("silence schedule not found")
+
+	scheduleStore *silenceScheduleStore
+
+	weekdayNames = map[string]time.Weekday{
+		"sunday":    time.Sunday,
+		"monday":    time.Monday,
+		"tuesday":   time.Tuesday,
+		"wednesday": time.Wednesday,
+		"thursday":  time.Thursday,
+		"friday":    time.Friday,
+		"saturday":  time.Saturday,
+	}
+)
+
+type SilenceSchedule struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	// OwnerGroups holds authentication groups of the owner at the time the
+	// schedule was saved, silence ACL rules are checked using those
+	OwnerGroups []string                `json:"ownerGroups"`
+	Clusters    []string                `json:"clusters"`
+	Matchers    []SilenceRequestMatcher `json:"matchers"`
+	Comment     string                  `json:"comment"`
+	// Cron is a cron expression for the start of each window, it can be
+	// used instead of Weekdays & Time
+	Cron     string   `json:"cron"`
+	Weekdays []string `json:"weekdays"`
+	Time     string   `json:"time"`
+	Duration string   `json:"duration"`
+	Timezone string   `json:"timezone"`
+	// Created holds the start time of the last window a silence was created
+	// for, per cluster
+	Created map[string]time.Time `json:"created"`
+}
+
+// compiledSilenceSchedule is a validated and parsed silence schedule
+type compiledSilenceSchedule struct {
+	cron     *cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+func (ss SilenceSchedule) cronExpression() (string, error) {
+	if ss.Cron != "" {
+		if len(ss.Weekdays) > 0 || ss.Time != "" {
+			return "", errors.New("cron cannot be used together with weekdays and time")
+		}
+		return ss.Cron, nil
+	}
+
+	if len(ss.Weekdays) == 0 || ss.Time == "" {
+		return "", errors.New("either cron or both weekdays and time must be set")
+	}
+	start, err := time.Parse("15:04", ss.Time)
+	if err != nil {
+		return "", fmt.Errorf("invalid time %q, expected a value like 02:00", ss.Time)
+	}
+	days := make([]string, 0, len(ss.Weekdays))
+	for _, name := range ss.Weekdays {
+		day, ok := weekdayNames[strings.ToLower(name)]
+		if !ok {
+			return "", fmt.Errorf("invalid weekday %q", name)
+		}
+		days = append(days, strconv.Itoa(int(day)))
+	}
+	return fmt.Sprintf("%d %d * * %s", start.Minute(), start.Hour(), strings.Join(days, ",")), nil
+}
+
+func (ss SilenceSchedule) compile() (*compiledSilenceSchedule, error) {
+	expr, err := ss.cronExpression()
+	if err != nil {
+		return nil, err
+	}
+	cs := compiledSilenceSchedule{}
+	if cs.cron, err = cron.Parse(expr); err != nil {
+		return nil, err
+	}
+	if cs.duration, err = time.ParseDuration(ss.Duration); err != nil || cs.duration <= 0 {
+		return nil, fmt.Errorf("invalid duration %q, expected a value like 10m or 1h", ss.Duration)
+	}
+	if cs.location, err = time.LoadLocation(ss.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", ss.Timezone, err)
+	}
+	return &cs, nil
+}
+
+// window returns the start and the end of the window that is currently
+// active or, if there's none, of the next window
+func (cs *compiledSilenceSchedule) window(now time.Time) (time.Time, time.Time) {
+	start := cs.cron.Next(now.In(cs.location).Add(-cs.duration))
+	if start.IsZero() {
+		return start, start
+	}
+	return start, start.Add(cs.duration)
+}
+
+func (ss SilenceSchedule) silenceRequest(startsAt, endsAt time.Time) SilenceRequest {
+	createdBy := ss.Owner
+	if createdBy == "" {
+		createdBy = "karma"
+	}
+	return SilenceRequest{
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		CreatedBy: createdBy,
+		Comment:   strings.TrimSpace(ss.Comment + " " + fmt.Sprintf(silenceScheduleTag, ss.Name)),
+		Matchers:  ss.Matchers,
+	}
+}
+
+// silenceScheduleStore holds all silence schedules, those are persisted to
+// a file if a path was configured.
+type silenceScheduleStore struct {
+	path      string
+	schedules []SilenceSchedule
+	lock      sync.RWMutex
+}
+
+func newSilenceScheduleStore(path string) (*silenceScheduleStore, error) {
+	store := silenceScheduleStore{
+		path:      path,
+		schedules: []SilenceSchedule{},
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			slog.Info("Silence schedules file doesn't exist yet", slog.String("path", path))
+		case err != nil:
+			return nil, fmt.Errorf("failed to read silence schedules file %q: %w", path, err)
+		default:
+			err = jsonv2.Unmarshal(data, &store.schedules)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse silence schedules file %q: %w", path, err)
+			}
+			slog.Info("Loaded silence schedules", slog.String("path", path), slog.Int("schedules", len(store.schedules)))
+		}
+	}
+
+	return &store, nil
+}
+
+// persist writes all schedules to disk, caller must hold the lock
+func (s *silenceScheduleStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	if err := writeJSONFile(s.path, s.schedules); err != nil {
+		return fmt.Errorf("failed to write silence schedules file: %w", err)
+	}
+	return nil
+}
+
+// list returns all schedules owned by given user
+func (s *silenceScheduleStore) list(owner string) []SilenceSchedule {
+	return slices.DeleteFunc(s.all(), func(ss SilenceSchedule) bool {
+		return ss.Owner != owner
+	})
+}
+
+func (s *silenceScheduleStore) all() []SilenceSchedule {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	schedules := slices.Clone(s.schedules)
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if schedules[i].Name == schedules[j].Name {
+			return schedules[i].Owner < schedules[j].Owner
+		}
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules
+}
+
+func (s *silenceScheduleStore) update(name, owner string, fn func([]SilenceSchedule, int) ([]SilenceSchedule, error)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	schedules := slices.Clone(s.schedules)
+	idx := slices.IndexFunc(schedules, func(ss SilenceSchedule) bool {
+		return ss.Name == name && ss.Owner == owner
+	})
+	schedules, err := fn(schedules, idx)
+	if err != nil {
+		return err
+	}
+
+	previous := s.schedules
+	s.schedules = schedules
+	if err = s.persist(); err != nil {
+		s.schedules = previous
+		return err
+	}
+	return nil
+}
+
+func (s *silenceScheduleStore) save(schedule SilenceSchedule) error {
+	return s.update(schedule.Name, schedule.Owner, func(schedules []SilenceSchedule, idx int) ([]SilenceSchedule, error) {
+		if idx < 0 {
+			return append(schedules, schedule), nil
+		}
+		// keep track of already created silences so we don't create those again
+		if schedule.Created == nil {
+			schedule.Created = schedules[idx].Created
+		}
+		schedules[idx] = schedule
+		return schedules, nil
+	})
+}
+
+func (s *silenceScheduleStore) delete(name, owner string) error {
+	return s.update(name, owner, func(schedules []SilenceSchedule, idx int) ([]SilenceSchedule, error) {
+		if idx < 0 {
+			return nil, errScheduleNotFound
+		}
+		return slices.Delete(schedules, idx, idx+1), nil
+	})
+}
+
+// markCreated records the start time of the last window a silence was created
+// for, it's kept in memory even if persisting it fails, otherwise a new
+// silence would be created on every reconcile run
+func (s *silenceScheduleStore) markCreated(name, owner, cluster string, startsAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx := slices.IndexFunc(s.schedules, func(ss SilenceSchedule) bool {
+		return ss.Name == name && ss.Owner == owner
+	})
+	if idx < 0 {
+		// schedule was deleted in the meantime
+		return nil
+	}
+
+	schedules := slices.Clone(s.schedules)
+	created := maps.Clone(schedules[idx].Created)
+	if created == nil {
+		created = map[string]time.Time{}
+	}
+	created[cluster] = startsAt
+	schedules[idx].Created = created
+	s.schedules = schedules
+	return s.persist()
+}
+
+// silenceScheduler creates silences for all schedules, ahead of each window
+type silenceScheduler struct {
+	store *silenceScheduleStore
+	lead  time.Duration
+	now   func() time.Time
+}
+
+func newSilenceScheduler(store *silenceScheduleStore, lead time.Duration) *silenceScheduler {
+	return &silenceScheduler{store: store, lead: lead, now: time.Now}
+}
+
+func (s *silenceScheduler) reconcile() {
+	now := s.now()
+	upstreams := alertmanager.GetAlertmanagers()
+	for _, schedule := range s.store.all() {
+		cs, err := schedule.compile()
+		if err != nil {
+			slog.Error("Invalid silence schedule", slog.Any("error", err), slog.String("schedule", schedule.Name))
+			continue
+		}
+
+		startsAt, endsAt := cs.window(now)
+		if startsAt.IsZero() || startsAt.Sub(now) > s.lead {
+			continue
+		}
+
+		silence, err := schedule.silenceRequest(startsAt, endsAt).toSilence(now)
+		if err != nil {
+			slog.Error("Invalid silence schedule", slog.Any("error", err), slog.String("schedule", schedule.Name))
+			continue
+		}
+
+		for _, cluster := range schedule.Clusters {
+			if !schedule.Created[cluster].Before(startsAt) {
+				continue
+			}
+
+			members := writableClusterMembers(cluster, upstreams)
+			if len(members) == 0 {
+				slog.Error(
+					"No writable Alertmanager instances for scheduled silence",
+					slog.String("schedule", schedule.Name),
+					slog.String("cluster", cluster),
+				)
+				continue
+			}
+			// ACL rules might have changed since the schedule was saved
+			if err = checkMembersSilenceACLs(members, &silence, schedule.OwnerGroups); err != nil {
+				slog.Error(
+					"Scheduled silence was rejected by ACL rules",
+					slog.Any("error", err),
+					slog.String("schedule", schedule.Name),
+					slog.String("cluster", cluster),
+				)
+				continue
+			}
+
+			result := sendSilenceToCluster(cluster, members, silence)
+			if result.Error != "" {
+				continue
+			}
+			slog.Info(
+				"Created scheduled silence",
+				slog.String("schedule", schedule.Name),
+				slog.String("cluster", cluster),
+				slog.String("alertmanager", result.Alertmanager),
+				slog.String("silence", result.SilenceID),
+				slog.Time("startsAt", startsAt),
+				slog.Time("endsAt", endsAt),
+			)
+			trackCreatedSilence(cluster, result.SilenceID)
+			if err = s.store.markCreated(schedule.Name, schedule.Owner, cluster, startsAt); err != nil {
+				slog.Error("Failed to update silence schedules", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (s *silenceScheduler) run(ticker *time.Ticker) {
+	for range ticker.C {
+		s.reconcile()
+	}
+}
+
+func listSilenceSchedules(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	var owner string
+	if config.Config.Authentication.Enabled {
+		owner = getUserFromContext(r)
+	}
+
+	data, _ := marshalJSON(scheduleStore.list(owner))
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func saveSilenceSchedule(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	var schedule SilenceSchedule
+	err := jsonv2.UnmarshalRead(r.Body, &schedule)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	if schedule.Name == "" {
+		badRequestJSON(w, "silence schedule name is required")
+		return
+	}
+
+	cs, err := schedule.compile()
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	if len(schedule.Clusters) == 0 {
+		badRequestJSON(w, "at least one cluster is required")
+		return
+	}
+	schedule.Clusters = slices.Compact(slices.Sorted(slices.Values(schedule.Clusters)))
+
+	schedule.Owner = ""
+	if config.Config.Authentication.Enabled {
+		schedule.Owner = getUserFromContext(r)
+	}
+	schedule.OwnerGroups = getGroupsFromContext(r)
+	schedule.Created = nil
+
+	// validate the silence for the next window, so we know that it's going
+	// to be accepted once it's time to create it
+	now := time.Now()
+	startsAt, endsAt := cs.window(now)
+	if startsAt.IsZero() {
+		badRequestJSON(w, "silence schedule never starts")
+		return
+	}
+	silence, err := schedule.silenceRequest(startsAt, endsAt).toSilence(now)
+	if err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+	if err = checkSilenceTemplates(&silence); err != nil {
+		badRequestJSON(w, err.Error())
+		return
+	}
+
+	upstreams := alertmanager.GetAlertmanagers()
+	for _, cluster := range schedule.Clusters {
+		if !slices.ContainsFunc(upstreams, func(am *alertmanager.Alertmanager) bool { return am.Cluster == cluster }) {
+			badRequestJSON(w, fmt.Sprintf("unknown cluster %q", cluster))
+			return
+		}
+		members := writableClusterMembers(cluster, upstreams)
+		if len(members) == 0 {
+			badRequestJSON(w, fmt.Sprintf("all Alertmanager instances in cluster %q are read-only", cluster))
+			return
+		}
+		if err = checkMembersSilenceACLs(members, &silence, schedule.OwnerGroups); err != nil {
+			badRequestJSON(w, err.Error())
+			return
+		}
+	}
+
+	if err = scheduleStore.save(schedule); err != nil {
+		slog.Error("Failed to update silence schedules", slog.Any("error", err))
+		errorJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data, _ := marshalJSON(schedule)
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func deleteSilenceSchedule(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	name, found := lookupQueryString(r, "name")
+	if !found || name == "" {
+		badRequestJSON(w, "missing name=<schedule> parameter")
+		return
+	}
+
+	var owner string
+	if config.Config.Authentication.Enabled {
+		owner = getUserFromContext(r)
+	}
+
+	err := scheduleStore.delete(name, owner)
+	switch {
+	case errors.Is(err, errScheduleNotFound):
+		errorJSON(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		slog.Error("Failed to update silence schedules", slog.Any("error", err))
+		errorJSON(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jarcoal/httpmock"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+func TestSilenceScheduleWindow(t *testing.T) {
+	type testCaseT struct {
+		name     string
+		schedule SilenceSchedule
+		now      time.Time
+		startsAt time.Time
+		endsAt   time.Time
+		err      string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:     "weekdays before the window",
+			schedule: SilenceSchedule{Weekdays: []string{"Sunday"}, Time: "02:00", Duration: "2h"},
+			now:      time.Date(2026, 1, 3, 12, 0, 0, 0, time.UTC),
+			startsAt: time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC),
+			endsAt:   time.Date(2026, 1, 4, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekdays during the window",
+			schedule: SilenceSchedule{Weekdays: []string{"sunday", "wednesday"}, Time: "02:00", Duration: "2h"},
+			now:      time.Date(2026, 1, 4, 3, 59, 0, 0, time.UTC),
+			startsAt: time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC),
+			endsAt:   time.Date(2026, 1, 4, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekdays after the window",
+			schedule: SilenceSchedule{Weekdays: []string{"sunday", "wednesday"}, Time: "02:00", Duration: "2h"},
+			now:      time.Date(2026, 1, 4, 4, 0, 0, 0, time.UTC),
+			startsAt: time.Date(2026, 1, 7, 2, 0, 0, 0, time.UTC),
+			endsAt:   time.Date(2026, 1, 7, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron with timezone",
+			schedule: SilenceSchedule{Cron: "30 22 * * fri", Duration: "30m", Timezone: "America/New_York"},
+			now:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			startsAt: time.Date(2026, 1, 3, 3, 30, 0, 0, time.UTC),
+			endsAt:   time.Date(2026, 1, 3, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "missing schedule",
+			schedule: SilenceSchedule{Duration: "1h"},
+			err:      "either cron or both weekdays and time must be set",
+		},
+		{
+			name:     "cron and weekdays",
+			schedule: SilenceSchedule{Cron: "* * * * *", Weekdays: []string{"monday"}, Duration: "1h"},
+			err:      "cron cannot be used together with weekdays and time",
+		},
+		{
+			name:     "invalid weekday",
+			schedule: SilenceSchedule{Weekdays: []string{"funday"}, Time: "02:00", Duration: "1h"},
+			err:      `invalid weekday "funday"`,
+		},
+		{
+			name:     "invalid time",
+			schedule: SilenceSchedule{Weekdays: []string{"monday"}, Time: "25:00", Duration: "1h"},
+			err:      `invalid time "25:00", expected a value like 02:00`,
+		},
+		{
+			name:     "invalid cron",
+			schedule: SilenceSchedule{Cron: "* * *", Duration: "1h"},
+			err:      `invalid cron expression "* * *", expected 5 fields, got 3`,
+		},
+		{
+			name:     "invalid duration",
+			schedule: SilenceSchedule{Cron: "* * * * *", Duration: "-1h"},
+			err:      `invalid duration "-1h", expected a value like 10m or 1h`,
+		},
+		{
+			name:     "invalid timezone",
+			schedule: SilenceSchedule{Cron: "* * * * *", Duration: "1h", Timezone: "Mars/Olympus"},
+			err:      `invalid timezone "Mars/Olympus": unknown time zone Mars/Olympus`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs, err := tc.schedule.compile()
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("compile() returned error %v, expected %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compile() returned an error: %s", err)
+			}
+			startsAt, endsAt := cs.window(tc.now)
+			if !startsAt.Equal(tc.startsAt) || !endsAt.Equal(tc.endsAt) {
+				t.Errorf("window(%s) returned %s - %s, expected %s - %s", tc.now, startsAt, endsAt, tc.startsAt, tc.endsAt)
+			}
+		})
+	}
+}
+
+func TestSilenceScheduler(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var upstreamCode int
+	sent := []string{}
+	httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sent = append(sent, string(body))
+		return httpmock.NewStringResponse(upstreamCode, `{"silenceID":"1234"}`), nil
+	})
+
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	store, err := newSilenceScheduleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.save(SilenceSchedule{
+		Name:     "maintenance",
+		Owner:    "bob",
+		Clusters: []string{"default"},
+		Matchers: []SilenceRequestMatcher{{Name: "cluster", Value: "prod"}},
+		Comment:  "Weekly maintenance",
+		Weekdays: []string{"sunday"},
+		Time:     "02:00",
+		Duration: "2h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var now time.Time
+	scheduler := newSilenceScheduler(store, time.Hour)
+	scheduler.now = func() time.Time { return now }
+
+	type stepT struct {
+		now          time.Time
+		upstreamCode int
+		sent         []string
+	}
+
+	steps := []stepT{
+		{
+			// too early
+			now:          time.Date(2026, 1, 3, 12, 0, 0, 0, time.UTC),
+			upstreamCode: 200,
+			sent:         []string{},
+		},
+		{
+			// upstream error, silence should be retried
+			now:          time.Date(2026, 1, 4, 1, 0, 0, 0, time.UTC),
+			upstreamCode: 500,
+			sent:         []string{`{"endsAt":"2026-01-04T04:00:00.000Z","startsAt":"2026-01-04T02:00:00.000Z","comment":"Weekly maintenance [karma schedule: maintenance]","createdBy":"bob","matchers":[{"isEqual":true,"name":"cluster","value":"prod","isRegex":false}]}`},
+		},
+		{
+			now:          time.Date(2026, 1, 4, 1, 1, 0, 0, time.UTC),
+			upstreamCode: 200,
+			sent:         []string{`{"endsAt":"2026-01-04T04:00:00.000Z","startsAt":"2026-01-04T02:00:00.000Z","comment":"Weekly maintenance [karma schedule: maintenance]","createdBy":"bob","matchers":[{"isEqual":true,"name":"cluster","value":"prod","isRegex":false}]}`},
+		},
+		{
+			// already created
+			now:          time.Date(2026, 1, 4, 1, 2, 0, 0, time.UTC),
+			upstreamCode: 200,
+			sent:         []string{},
+		},
+		{
+			// window is active and was already created
+			now:          time.Date(2026, 1, 4, 3, 0, 0, 0, time.UTC),
+			upstreamCode: 200,
+			sent:         []string{},
+		},
+		{
+			now:          time.Date(2026, 1, 11, 1, 30, 0, 0, time.UTC),
+			upstreamCode: 200,
+			sent:         []string{`{"endsAt":"2026-01-11T04:00:00.000Z","startsAt":"2026-01-11T02:00:00.000Z","comment":"Weekly maintenance [karma schedule: maintenance]","createdBy":"bob","matchers":[{"isEqual":true,"name":"cluster","value":"prod","isRegex":false}]}`},
+		},
+	}
+
+	for _, step := range steps {
+		now = step.now
+		upstreamCode = step.upstreamCode
+		sent = []string{}
+		scheduler.reconcile()
+		if diff := cmp.Diff(step.sent, sent); diff != "" {
+			t.Errorf("Wrong silences sent at %s (-want +got):\n%s", step.now, diff)
+		}
+	}
+
+	// created silences are persisted, so we don't create those again after restart
+	store, err = newSilenceScheduleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedules := store.list("bob")
+	if len(schedules) != 1 {
+		t.Fatalf("Got %d schedules after reload, expected 1", len(schedules))
+	}
+	if created := schedules[0].Created["default"]; !created.Equal(time.Date(2026, 1, 11, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong created time after reload: %s", created)
+	}
+
+	// created silences are tagged and can be found using silence search
+	silence := models.ManagedSilence{Cluster: "default", Silence: models.Silence{Comment: "Weekly maintenance [karma schedule: maintenance]"}}
+	if !silenceMatchesSearchTerm(silence, "[karma schedule: maintenance]", []string{}) {
+		t.Errorf("Scheduled silence doesn't match search for its tag")
+	}
+}
+
+func TestSilenceSchedulerChecksACLs(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	sent := []string{}
+	httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sent = append(sent, string(body))
+		return httpmock.NewStringResponse(200, `{"silenceID":"1234"}`), nil
+	})
+
+	store, err := newSilenceScheduleStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.save(SilenceSchedule{
+		Name:        "maintenance",
+		Owner:       "bob",
+		OwnerGroups: []string{"dev"},
+		Clusters:    []string{"default"},
+		Matchers:    []SilenceRequestMatcher{{Name: "cluster", Value: "prod"}},
+		Cron:        "0 2 * * 0",
+		Duration:    "2h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ACL rules added after the schedule was saved still apply
+	silenceACLs = []*silenceACL{
+		{Action: aclActionBlock, Reason: "no silences for dev", Scope: silenceACLScope{Groups: []string{"dev"}}},
+	}
+	defer func() { silenceACLs = []*silenceACL{} }()
+
+	scheduler := newSilenceScheduler(store, time.Hour)
+	scheduler.now = func() time.Time { return time.Date(2026, 1, 4, 1, 30, 0, 0, time.UTC) }
+	scheduler.reconcile()
+	if len(sent) != 0 {
+		t.Errorf("Scheduled silence blocked by ACL rules was sent: %v", sent)
+	}
+	if created := store.list("bob")[0].Created; len(created) != 0 {
+		t.Errorf("Scheduled silence blocked by ACL rules was marked as created: %v", created)
+	}
+}
+
+func TestSilenceScheduleStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newSilenceScheduleStore(filepath.Join(dir, "schedules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, owner := range []string{"alice", "bob"} {
+		if err = store.save(SilenceSchedule{Name: "weekly", Owner: owner}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// users can only see their own schedules
+	for _, owner := range []string{"alice", "bob"} {
+		schedules := store.list(owner)
+		if len(schedules) != 1 || schedules[0].Owner != owner {
+			t.Errorf("list(%q) returned %v", owner, schedules)
+		}
+	}
+	if schedules := store.list("john"); len(schedules) != 0 {
+		t.Errorf("list(\"john\") returned %v", schedules)
+	}
+	if schedules := store.all(); len(schedules) != 2 {
+		t.Errorf("all() returned %d schedules, expected 2", len(schedules))
+	}
+
+	// created silences are remembered even if we fail to write the file
+	store.path = filepath.Join(dir, "missing", "schedules.json")
+	startsAt := time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC)
+	if err = store.markCreated("weekly", "bob", "default", startsAt); err == nil {
+		t.Errorf("markCreated() didn't return any error for a path that can't be written")
+	}
+	if created := store.list("bob")[0].Created["default"]; !created.Equal(startsAt) {
+		t.Errorf("Wrong created time after failed write: %s", created)
+	}
+}
+
+func TestSilenceScheduleAPI(t *testing.T) {
+	mockConfig(t.Setenv)
+	mockAlerts("0.31.0")
+
+	var err error
+	scheduleStore, err = newSilenceScheduleStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type requestT struct {
+		method string
+		uri    string
+		body   string
+		code   int
+		resp   string
+	}
+
+	requests := []requestT{
+		{method: "GET", uri: "/silenceSchedules.json", code: 200, resp: `[]`},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"name":"weekly","clusters":["default"],"matchers":[{"name":"cluster","value":"prod"}],"weekdays":["sunday"],"time":"02:00","duration":"2h"}`,
+			code:   200,
+			resp:   `{"name":"weekly","owner":"","ownerGroups":[],"clusters":["default"],"matchers":[{"isEqual":null,"name":"cluster","value":"prod","isRegex":false}],"comment":"","cron":"","weekdays":["sunday"],"time":"02:00","duration":"2h","timezone":"","created":{}}`,
+		},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"name":"weekly","clusters":["foo"],"matchers":[{"name":"cluster","value":"prod"}],"cron":"0 2 * * 0","duration":"2h"}`,
+			code:   400,
+			resp:   `{"error":"unknown cluster \"foo\""}`,
+		},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"name":"weekly","matchers":[{"name":"cluster","value":"prod"}],"cron":"0 2 * * 0","duration":"2h"}`,
+			code:   400,
+			resp:   `{"error":"at least one cluster is required"}`,
+		},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"name":"weekly","clusters":["default"],"cron":"0 2 * * 0","duration":"2h"}`,
+			code:   400,
+			resp:   `{"error":"silence requires at least one matcher"}`,
+		},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"clusters":["default"],"cron":"0 2 * * 0","duration":"2h"}`,
+			code:   400,
+			resp:   `{"error":"silence schedule name is required"}`,
+		},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"name":"weekly","clusters":["default"],"cron":"0 2 31 2 *","duration":"2h","matchers":[{"name":"cluster","value":"prod"}]}`,
+			code:   400,
+			resp:   `{"error":"silence schedule never starts"}`,
+		},
+		{
+			method: "POST",
+			uri:    "/silenceSchedules.json",
+			body:   `{"name":"weekly","clusters":["default"],"cron":"0 2 * * 0","duration":"foo"}`,
+			code:   400,
+			resp:   `{"error":"invalid duration \"foo\", expected a value like 10m or 1h"}`,
+		},
+		{
+			method: "GET",
+			uri:    "/silenceSchedules.json",
+			code:   200,
+			resp:   `[{"name":"weekly","owner":"","ownerGroups":[],"clusters":["default"],"matchers":[{"isEqual":null,"name":"cluster","value":"prod","isRegex":false}],"comment":"","cron":"","weekdays":["sunday"],"time":"02:00","duration":"2h","timezone":"","created":{}}]`,
+		},
+		{method: "DELETE", uri: "/silenceSchedules.json", code: 400, resp: `{"error":"missing name=\u003cschedule\u003e parameter"}`},
+		{method: "DELETE", uri: "/silenceSchedules.json?name=foo", code: 404, resp: `{"error":"silence schedule not found"}`},
+		{method: "DELETE", uri: "/silenceSchedules.json?name=weekly", code: 200},
+		{method: "GET", uri: "/silenceSchedules.json", code: 200, resp: `[]`},
+	}
+
+	r := testRouter()
+	setupRouter(r, nil)
+	for _, request := range requests {
+		req := httptest.NewRequest(request.method, request.uri, strings.NewReader(request.body))
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != request.code {
+			t.Errorf("%s %s returned status %d, expected %d: %s", request.method, request.uri, resp.Code, request.code, resp.Body.String())
+		}
+		if diff := cmp.Diff(request.resp, resp.Body.String()); diff != "" {
+			t.Errorf("%s %s returned wrong response (-want +got):\n%s", request.method, request.uri, diff)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+const (
+	silenceSyncCreate = "create"
+	silenceSyncUpdate = "update"
+	silenceSyncExpire = "expire"
+)
+
+// replicas are tagged with the cluster and ID of the source silence, this is
+// how we map silences between clusters without storing the mapping
+var silenceSyncTagRegex = regexp.MustCompile(`\[karma sync: (.+)/([^/\]]+)\]$`)
+
+func silenceSyncTag(cluster, id string) string {
+	return fmt.Sprintf("[karma sync: %s/%s]", cluster, id)
+}
+
+// parseSilenceSyncTag returns the cluster and ID of the source silence if
+// given silence is a replica
+func parseSilenceSyncTag(silence models.Silence) (cluster, id string, ok bool) {
+	m := silenceSyncTagRegex.FindStringSubmatch(silence.Comment)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+type silenceSyncGroup struct {
+	name     string
+	clusters []string
+	selector []silenceFilter
+}
+
+// isMatch returns true if silence matches all selector filters, groups
+// without any selector don't match anything
+func (g *silenceSyncGroup) isMatch(silence *models.Silence) bool {
+	if len(g.selector) == 0 {
+		return false
+	}
+	for _, f := range g.selector {
+		if !f.isMatch(silence) {
+			return false
+		}
+	}
+	return true
+}
+
+type silenceSyncAction struct {
+	Kind    string
+	Group   string
+	Cluster string
+	Source  silenceKey
+	Silence models.Silence
+}
+
+// silenceSyncExpiredReplica records that the replica of a source silence was
+// expired by hand in given cluster, Alertmanager will eventually forget about
+// expired silences so we need to remember it to avoid creating it again
+type silenceSyncExpiredReplica struct {
+	SourceCluster string `json:"sourceCluster"`
+	SourceID      string `json:"sourceID"`
+	Cluster       string `json:"cluster"`
+}
+
+type silenceSyncer struct {
+	now     func() time.Time
+	tracked map[silenceKey]time.Time
+	expired map[silenceSyncExpiredReplica]struct{}
+	path    string
+	groups  []silenceSyncGroup
+	lock    sync.Mutex
+}
+
+var silenceSync = &silenceSyncer{}
+
+func newSilenceSyncer(groups []config.SilenceSyncGroup, path string, upstreams []*alertmanager.Alertmanager) (*silenceSyncer, error) {
+	s := silenceSyncer{
+		now:     time.Now,
+		tracked: map[silenceKey]time.Time{},
+		expired: map[silenceSyncExpiredReplica]struct{}{},
+		path:    path,
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			slog.Info("Silence sync file doesn't exist yet", slog.String("path", path))
+		case err != nil:
+			return nil, fmt.Errorf("failed to read silence sync file %q: %w", path, err)
+		default:
+			var expired []silenceSyncExpiredReplica
+			if err = jsonv2.Unmarshal(data, &expired); err != nil {
+				return nil, fmt.Errorf("failed to parse silence sync file %q: %w", path, err)
+			}
+			for _, er := range expired {
+				s.expired[er] = struct{}{}
+			}
+			slog.Info("Loaded expired silence replicas", slog.String("path", path), slog.Int("replicas", len(s.expired)))
+		}
+	}
+	for _, cfg := range groups {
+		group := silenceSyncGroup{
+			name:     cfg.Name,
+			clusters: slices.Compact(slices.Sorted(slices.Values(cfg.Clusters))),
+		}
+		for _, cluster := range group.clusters {
+			if !slices.ContainsFunc(upstreams, func(am *alertmanager.Alertmanager) bool { return am.Cluster == cluster }) {
+				return nil, fmt.Errorf("silence sync group %q references unknown cluster %q", cfg.Name, cluster)
+			}
+		}
+		for _, filter := range cfg.Selector {
+			f, err := newSilenceFilterFromConfig(filter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector in silence sync group %q: %w", cfg.Name, err)
+			}
+			group.selector = append(group.selector, f)
+		}
+		s.groups = append(s.groups, group)
+	}
+	return &s, nil
+}
+
+func (s *silenceSyncer) groupForCluster(cluster string) *silenceSyncGroup {
+	for i := range s.groups {
+		if slices.Contains(s.groups[i].clusters, cluster) {
+			return &s.groups[i]
+		}
+	}
+	return nil
+}
+
+// track marks a silence created via karma so it will be replicated to other
+// clusters in the same sync group, it's a no-op for clusters not in any group
+func (s *silenceSyncer) track(cluster, id string) {
+	if id == "" || s.groupForCluster(cluster) == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.tracked[silenceKey{cluster: cluster, id: id}]; !ok {
+		slog.Debug("Tracking silence for synchronisation", slog.String("cluster", cluster), slog.String("silence", id))
+		s.tracked[silenceKey{cluster: cluster, id: id}] = s.now()
+	}
+}
+
+// persist writes all replicas expired by hand to disk, caller must hold the lock
+func (s *silenceSyncer) persist() {
+	if s.path == "" {
+		return
+	}
+
+	expired := make([]silenceSyncExpiredReplica, 0, len(s.expired))
+	for er := range s.expired {
+		expired = append(expired, er)
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].SourceCluster != expired[j].SourceCluster {
+			return expired[i].SourceCluster < expired[j].SourceCluster
+		}
+		if expired[i].SourceID != expired[j].SourceID {
+			return expired[i].SourceID < expired[j].SourceID
+		}
+		return expired[i].Cluster < expired[j].Cluster
+	})
+	if err := writeJSONFile(s.path, expired); err != nil {
+		slog.Error("Failed to write silence sync file", slog.Any("error", err), slog.String("path", s.path))
+	}
+}
+
+func newSilenceReplica(source models.ManagedSilence, replica *models.Silence, now time.Time) models.Silence {
+	silence := models.Silence{
+		StartsAt:  source.Silence.StartsAt,
+		EndsAt:    source.Silence.EndsAt,
+		CreatedBy: source.Silence.CreatedBy,
+		Comment:   silenceSyncTag(source.Cluster, source.Silence.ID),
+		Matchers:  slices.Clone(source.Silence.Matchers),
+	}
+	if source.Silence.Comment != "" {
+		silence.Comment = source.Silence.Comment + " " + silence.Comment
+	}
+	if replica != nil {
+		silence.ID = replica.ID
+		// start time of a started silence cannot be modified
+		if !replica.StartsAt.After(now) {
+			silence.StartsAt = replica.StartsAt
+		}
+	}
+	return silence
+}
+
+func isReplicaOutdated(replica, expected models.Silence) bool {
+	if !replica.EndsAt.Equal(expected.EndsAt) || !replica.StartsAt.Equal(expected.StartsAt) {
+		return true
+	}
+	if replica.Comment != expected.Comment || replica.CreatedBy != expected.CreatedBy {
+		return true
+	}
+	a, b := silenceMatcherSet(replica), silenceMatcherSet(expected)
+	return len(a) != len(b) || !isMatcherSubset(a, b)
+}
+
+// plan compares silences from all clusters in every sync group and returns
+// the list of changes needed to bring all replicas up to date
+// healthyClusters is used to decide if we can trust that a source silence is
+// gone, replicas are only expired if the source cluster was pulled successfully
+func (s *silenceSyncer) plan(silences []models.ManagedSilence, healthyClusters []string) []silenceSyncAction {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	sources := map[silenceKey]models.ManagedSilence{}
+	// cluster -> source -> replicas
+	replicas := map[string]map[silenceKey][]models.Silence{}
+	for _, ms := range silences {
+		if cluster, id, ok := parseSilenceSyncTag(ms.Silence); ok {
+			if _, found := replicas[ms.Cluster]; !found {
+				replicas[ms.Cluster] = map[silenceKey][]models.Silence{}
+			}
+			key := silenceKey{cluster: cluster, id: id}
+			replicas[ms.Cluster][key] = append(replicas[ms.Cluster][key], ms.Silence)
+			continue
+		}
+		sources[silenceKey{cluster: ms.Cluster, id: ms.Silence.ID}] = ms
+	}
+
+	for key, trackedAt := range s.tracked {
+		ms, ok := sources[key]
+		if (ok && ms.IsExpired) || (!ok && now.Sub(trackedAt) > silenceTrackTimeout) {
+			delete(s.tracked, key)
+		}
+	}
+
+	var expiredChanged bool
+	actions := []silenceSyncAction{}
+	for _, group := range s.groups {
+		for key, ms := range sources {
+			if ms.IsExpired || !slices.Contains(group.clusters, key.cluster) {
+				continue
+			}
+
+			_, isTracked := s.tracked[key]
+			hasReplicas := false
+			for _, cluster := range group.clusters {
+				for _, replica := range replicas[cluster][key] {
+					if !replica.EndsAt.Before(now) {
+						hasReplicas = true
+					}
+				}
+			}
+			if !isTracked && !hasReplicas && !group.isMatch(&ms.Silence) {
+				continue
+			}
+
+			for _, cluster := range group.clusters {
+				if cluster == key.cluster {
+					continue
+				}
+				var active *models.Silence
+				var expired bool
+				for _, replica := range replicas[cluster][key] {
+					if replica.EndsAt.Before(now) {
+						expired = true
+					} else {
+						active = &replica
+					}
+				}
+				er := silenceSyncExpiredReplica{SourceCluster: key.cluster, SourceID: key.id, Cluster: cluster}
+				_, wasExpired := s.expired[er]
+				switch {
+				case active != nil:
+					expected := newSilenceReplica(ms, active, now)
+					if isReplicaOutdated(*active, expected) {
+						actions = append(actions, silenceSyncAction{Kind: silenceSyncUpdate, Group: group.name, Cluster: cluster, Source: key, Silence: expected})
+					}
+				case expired:
+					// replica was expired by someone, don't recreate it
+					if !wasExpired {
+						s.expired[er] = struct{}{}
+						expiredChanged = true
+					}
+				case wasExpired:
+					// expired replica is already gone from Alertmanager
+				default:
+					actions = append(actions, silenceSyncAction{Kind: silenceSyncCreate, Group: group.name, Cluster: cluster, Source: key, Silence: newSilenceReplica(ms, nil, now)})
+				}
+			}
+		}
+
+		for _, cluster := range group.clusters {
+			for key, silences := range replicas[cluster] {
+				if !slices.Contains(group.clusters, key.cluster) || !slices.Contains(healthyClusters, key.cluster) {
+					continue
+				}
+				if ms, ok := sources[key]; ok && !ms.IsExpired {
+					continue
+				}
+				for _, replica := range silences {
+					if !replica.EndsAt.Before(now) {
+						actions = append(actions, silenceSyncAction{Kind: silenceSyncExpire, Group: group.name, Cluster: cluster, Source: key, Silence: replica})
+					}
+				}
+			}
+		}
+	}
+
+	// forget about replicas expired by hand once the source silence is gone
+	for er := range s.expired {
+		if !slices.Contains(healthyClusters, er.SourceCluster) {
+			continue
+		}
+		if ms, ok := sources[silenceKey{cluster: er.SourceCluster, id: er.SourceID}]; ok && !ms.IsExpired {
+			continue
+		}
+		delete(s.expired, er)
+		expiredChanged = true
+	}
+	if expiredChanged {
+		s.persist()
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Cluster != actions[j].Cluster {
+			return actions[i].Cluster < actions[j].Cluster
+		}
+		if actions[i].Source != actions[j].Source {
+			if actions[i].Source.cluster != actions[j].Source.cluster {
+				return actions[i].Source.cluster < actions[j].Source.cluster
+			}
+			return actions[i].Source.id < actions[j].Source.id
+		}
+		return actions[i].Silence.ID < actions[j].Silence.ID
+	})
+
+	return actions
+}
+
+// reconcile replicates silences between clusters in all sync groups
+func (s *silenceSyncer) reconcile(upstreams []*alertmanager.Alertmanager, silences []models.ManagedSilence) {
+	if len(s.groups) == 0 {
+		return
+	}
+
+	healthyClusters := []string{}
+	for _, am := range upstreams {
+		if am.IsHealthy() && !slices.Contains(healthyClusters, am.Cluster) {
+			healthyClusters = append(healthyClusters, am.Cluster)
+		}
+	}
+
+	for _, action := range s.plan(silences, healthyClusters) {
+		members := writableClusterMembers(action.Cluster, upstreams)
+		if len(members) == 0 {
+			slog.Warn(
+				"Cannot synchronise silence, all Alertmanager instances are read-only",
+				slog.String("group", action.Group),
+				slog.String("cluster", action.Cluster),
+				slog.String("source", silenceSyncTag(action.Source.cluster, action.Source.id)),
+			)
+			continue
+		}
+
+		amName, silenceID, err := runOnClusterMembers(action.Cluster, action.Kind, members, func(am *alertmanager.Alertmanager) (string, error) {
+			if action.Kind == silenceSyncExpire {
+				return action.Silence.ID, am.ExpireSilence(action.Silence.ID)
+			}
+			return am.CreateSilence(action.Silence)
+		})
+		if err != nil {
+			continue
+		}
+		slog.Info(
+			"Synchronised silence",
+			slog.String("action", action.Kind),
+			slog.String("group", action.Group),
+			slog.String("cluster", action.Cluster),
+			slog.String("alertmanager", amName),
+			slog.String("silence", silenceID),
+			slog.String("source", silenceSyncTag(action.Source.cluster, action.Source.id)),
+		)
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jarcoal/httpmock"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+func mockSilenceSyncUpstreams(t *testing.T) []*alertmanager.Alertmanager {
+	upstreams := []*alertmanager.Alertmanager{}
+	for _, cluster := range []string{"eu", "us", "dev"} {
+		am, err := alertmanager.NewAlertmanager(cluster, cluster, "http://"+cluster+".example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		upstreams = append(upstreams, am)
+	}
+	return upstreams
+}
+
+func mockSilenceSyncer(t *testing.T, now time.Time, selector ...config.SilenceFilters) *silenceSyncer {
+	s, err := newSilenceSyncer(
+		[]config.SilenceSyncGroup{{Name: "global", Clusters: []string{"eu", "us"}, Selector: selector}},
+		"",
+		mockSilenceSyncUpstreams(t),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestNewSilenceSyncer(t *testing.T) {
+	type testCaseT struct {
+		name  string
+		group config.SilenceSyncGroup
+		err   string
+	}
+
+	testCases := []testCaseT{
+		{
+			name:  "valid group",
+			group: config.SilenceSyncGroup{Name: "global", Clusters: []string{"eu", "us"}},
+		},
+		{
+			name:  "unknown cluster",
+			group: config.SilenceSyncGroup{Name: "global", Clusters: []string{"eu", "asia"}},
+			err:   `silence sync group "global" references unknown cluster "asia"`,
+		},
+		{
+			name: "invalid selector",
+			group: config.SilenceSyncGroup{
+				Name:     "global",
+				Clusters: []string{"eu", "us"},
+				Selector: []config.SilenceFilters{{Name: "alertname"}},
+			},
+			err: `invalid selector in silence sync group "global": silence ACL rule filter requires 'value' or 'value_re' to be set`,
+		},
+		{
+			name: "invalid selector regex",
+			group: config.SilenceSyncGroup{
+				Name:     "global",
+				Clusters: []string{"eu", "us"},
+				Selector: []config.SilenceFilters{{Name: "alertname", ValueRegex: "foo("}},
+			},
+			err: "invalid selector in silence sync group \"global\": invalid ACL rule, failed to parse value_re \"foo(\": error parsing regexp: missing closing ): `^foo($`",
+		},
+	}
+
+	upstreams := mockSilenceSyncUpstreams(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errMsg string
+			if _, err := newSilenceSyncer([]config.SilenceSyncGroup{tc.group}, "", upstreams); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.err {
+				t.Errorf("newSilenceSyncer() returned %q, expected %q", errMsg, tc.err)
+			}
+		})
+	}
+}
+
+func TestSilenceSyncPlan(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	matchers := []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Foo", false, true)}
+	newSilence := func(cluster, id, comment string, startsAt, endsAt time.Time) models.ManagedSilence {
+		return models.ManagedSilence{
+			Cluster:   cluster,
+			IsExpired: endsAt.Before(now),
+			Silence: models.Silence{
+				ID:        id,
+				StartsAt:  startsAt,
+				EndsAt:    endsAt,
+				CreatedBy: "me",
+				Comment:   comment,
+				Matchers:  matchers,
+			},
+		}
+	}
+	source := newSilence("eu", "1", "foo", now.Add(-time.Hour), now.Add(time.Hour))
+	replica := newSilence("us", "2", "foo [karma sync: eu/1]", now.Add(-time.Hour), now.Add(time.Hour))
+
+	type testCaseT struct {
+		name     string
+		silences []models.ManagedSilence
+		tracked  []silenceKey
+		selector []config.SilenceFilters
+		healthy  []string
+		actions  []silenceSyncAction
+	}
+
+	testCases := []testCaseT{
+		{
+			name:     "untracked silence is ignored",
+			silences: []models.ManagedSilence{source},
+			actions:  []silenceSyncAction{},
+		},
+		{
+			name:     "tracked silence is replicated",
+			silences: []models.ManagedSilence{source},
+			tracked:  []silenceKey{{cluster: "eu", id: "1"}},
+			actions: []silenceSyncAction{
+				{
+					Kind:    silenceSyncCreate,
+					Group:   "global",
+					Cluster: "us",
+					Source:  silenceKey{cluster: "eu", id: "1"},
+					Silence: models.Silence{
+						StartsAt:  now.Add(-time.Hour),
+						EndsAt:    now.Add(time.Hour),
+						CreatedBy: "me",
+						Comment:   "foo [karma sync: eu/1]",
+						Matchers:  matchers,
+					},
+				},
+			},
+		},
+		{
+			name:     "tracked silence from a cluster outside of sync groups is ignored",
+			silences: []models.ManagedSilence{newSilence("dev", "1", "foo", now.Add(-time.Hour), now.Add(time.Hour))},
+			tracked:  []silenceKey{{cluster: "dev", id: "1"}},
+			actions:  []silenceSyncAction{},
+		},
+		{
+			name:     "silence matching selector is replicated",
+			silences: []models.ManagedSilence{source},
+			selector: []config.SilenceFilters{{Name: "alertname", Value: "Foo"}},
+			actions: []silenceSyncAction{
+				{
+					Kind:    silenceSyncCreate,
+					Group:   "global",
+					Cluster: "us",
+					Source:  silenceKey{cluster: "eu", id: "1"},
+					Silence: models.Silence{
+						StartsAt:  now.Add(-time.Hour),
+						EndsAt:    now.Add(time.Hour),
+						CreatedBy: "me",
+						Comment:   "foo [karma sync: eu/1]",
+						Matchers:  matchers,
+					},
+				},
+			},
+		},
+		{
+			name:     "silence not matching selector is ignored",
+			silences: []models.ManagedSilence{source},
+			selector: []config.SilenceFilters{{Name: "alertname", Value: "Bar"}},
+			actions:  []silenceSyncAction{},
+		},
+		{
+			name:     "replica that is up to date is not modified",
+			silences: []models.ManagedSilence{source, replica},
+			actions:  []silenceSyncAction{},
+		},
+		{
+			name: "replica is updated when source is extended",
+			silences: []models.ManagedSilence{
+				newSilence("eu", "1", "foo", now.Add(-time.Hour), now.Add(time.Hour*2)),
+				replica,
+			},
+			actions: []silenceSyncAction{
+				{
+					Kind:    silenceSyncUpdate,
+					Group:   "global",
+					Cluster: "us",
+					Source:  silenceKey{cluster: "eu", id: "1"},
+					Silence: models.Silence{
+						ID:        "2",
+						StartsAt:  now.Add(-time.Hour),
+						EndsAt:    now.Add(time.Hour * 2),
+						CreatedBy: "me",
+						Comment:   "foo [karma sync: eu/1]",
+						Matchers:  matchers,
+					},
+				},
+			},
+		},
+		{
+			name: "replica is not recreated after it was expired",
+			silences: []models.ManagedSilence{
+				source,
+				newSilence("us", "2", "foo [karma sync: eu/1]", now.Add(-time.Hour), now.Add(-time.Minute)),
+			},
+			tracked: []silenceKey{{cluster: "eu", id: "1"}},
+			actions: []silenceSyncAction{},
+		},
+		{
+			name: "replica is expired when source is expired",
+			silences: []models.ManagedSilence{
+				newSilence("eu", "1", "foo", now.Add(-time.Hour), now.Add(-time.Minute)),
+				replica,
+			},
+			healthy: []string{"eu", "us"},
+			actions: []silenceSyncAction{
+				{
+					Kind:    silenceSyncExpire,
+					Group:   "global",
+					Cluster: "us",
+					Source:  silenceKey{cluster: "eu", id: "1"},
+					Silence: replica.Silence,
+				},
+			},
+		},
+		{
+			name:     "replica is expired when source is gone",
+			silences: []models.ManagedSilence{replica},
+			healthy:  []string{"eu", "us"},
+			actions: []silenceSyncAction{
+				{
+					Kind:    silenceSyncExpire,
+					Group:   "global",
+					Cluster: "us",
+					Source:  silenceKey{cluster: "eu", id: "1"},
+					Silence: replica.Silence,
+				},
+			},
+		},
+		{
+			name:     "replica is not expired when source cluster is unhealthy",
+			silences: []models.ManagedSilence{replica},
+			healthy:  []string{"us"},
+			actions:  []silenceSyncAction{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := mockSilenceSyncer(t, now, tc.selector...)
+			for _, key := range tc.tracked {
+				s.track(key.cluster, key.id)
+			}
+			actions := s.plan(tc.silences, tc.healthy)
+			if diff := cmp.Diff(tc.actions, actions, cmp.AllowUnexported(silenceKey{}), cmpopts.IgnoreUnexported(models.SilenceMatcher{})); diff != "" {
+				t.Errorf("Wrong sync actions returned (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSilenceSyncTracking(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := mockSilenceSyncer(t, now)
+
+	s.track("eu", "1")
+	s.track("eu", "2")
+	s.track("dev", "3")
+	s.track("us", "")
+	if len(s.tracked) != 2 {
+		t.Fatalf("Expected 2 tracked silences, got %d", len(s.tracked))
+	}
+
+	// expired silences are no longer tracked
+	s.plan([]models.ManagedSilence{
+		{Cluster: "eu", IsExpired: true, Silence: models.Silence{ID: "1", EndsAt: now.Add(-time.Minute)}},
+	}, nil)
+	if _, ok := s.tracked[silenceKey{cluster: "eu", id: "1"}]; ok {
+		t.Errorf("Expired silence is still tracked")
+	}
+	if _, ok := s.tracked[silenceKey{cluster: "eu", id: "2"}]; !ok {
+		t.Errorf("Missing silence should be tracked until timeout")
+	}
+
+	s.now = func() time.Time { return now.Add(silenceTrackTimeout + time.Minute) }
+	s.plan(nil, nil)
+	if len(s.tracked) != 0 {
+		t.Errorf("Expected no tracked silences, got %d", len(s.tracked))
+	}
+}
+
+func TestSilenceSyncExpiredReplicas(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "sync.json")
+	matchers := []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Foo", false, true)}
+	source := models.ManagedSilence{
+		Cluster: "eu",
+		Silence: models.Silence{ID: "1", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Comment: "foo", Matchers: matchers},
+	}
+	expiredReplica := models.ManagedSilence{
+		Cluster:   "us",
+		IsExpired: true,
+		Silence:   models.Silence{ID: "2", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(-time.Minute), Comment: "foo [karma sync: eu/1]", Matchers: matchers},
+	}
+	newSyncer := func() *silenceSyncer {
+		s, err := newSilenceSyncer(
+			[]config.SilenceSyncGroup{{Name: "global", Clusters: []string{"eu", "us"}, Selector: []config.SilenceFilters{{Name: "alertname", Value: "Foo"}}}},
+			path,
+			mockSilenceSyncUpstreams(t),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.now = func() time.Time { return now }
+		return s
+	}
+
+	s := newSyncer()
+	if actions := s.plan([]models.ManagedSilence{source, expiredReplica}, []string{"eu", "us"}); len(actions) != 0 {
+		t.Errorf("Expected no actions, got %v", actions)
+	}
+
+	// Alertmanager no longer returns the expired replica after a restart
+	s = newSyncer()
+	if actions := s.plan([]models.ManagedSilence{source}, []string{"eu", "us"}); len(actions) != 0 {
+		t.Errorf("Replica expired by hand was recreated: %v", actions)
+	}
+
+	// source is gone, but its cluster is unhealthy so we don't know that yet
+	s.plan([]models.ManagedSilence{}, []string{"us"})
+	if len(s.expired) != 1 {
+		t.Errorf("Expired replica was forgotten while source cluster is unhealthy")
+	}
+
+	s.plan([]models.ManagedSilence{}, []string{"eu", "us"})
+	s = newSyncer()
+	if len(s.expired) != 0 {
+		t.Errorf("Expired replica wasn't forgotten after source silence was gone: %v", s.expired)
+	}
+
+	if err := os.WriteFile(path, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSilenceSyncer(nil, path, nil); err == nil {
+		t.Errorf("newSilenceSyncer() didn't return an error for invalid file")
+	}
+}
+
+func TestSilenceSyncReconcile(t *testing.T) {
+	now := time.Now()
+	s := mockSilenceSyncer(t, now)
+	s.track("eu", "1")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	requests := []string{}
+	httpmock.RegisterResponder("POST", "http://us.example.com/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		requests = append(requests, req.Method+" "+req.URL.Host+req.URL.Path+" "+string(body))
+		return httpmock.NewStringResponse(200, `{"silenceID":"3"}`), nil
+	})
+	httpmock.RegisterRegexpResponder("DELETE", regexp.MustCompile("^http://us.example.com/api/v2/silence/.+"), func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.Method+" "+req.URL.Host+req.URL.Path)
+		return httpmock.NewStringResponse(200, ""), nil
+	})
+
+	startsAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	endsAt := now.Add(time.Hour).UTC().Truncate(time.Second)
+	silences := []models.ManagedSilence{
+		{
+			Cluster: "eu",
+			Silence: models.Silence{
+				ID:        "1",
+				StartsAt:  startsAt,
+				EndsAt:    endsAt,
+				CreatedBy: "me",
+				Comment:   "foo",
+				Matchers:  []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Foo", false, true)},
+			},
+		},
+		{
+			Cluster: "us",
+			Silence: models.Silence{
+				ID:       "2",
+				StartsAt: startsAt,
+				EndsAt:   endsAt,
+				Comment:  "[karma sync: eu/9]",
+				Matchers: []models.SilenceMatcher{models.NewSilenceMatcher("alertname", "Bar", false, true)},
+			},
+		},
+	}
+	s.reconcile(mockSilenceSyncUpstreams(t), silences)
+
+	expected := []string{
+		`POST us.example.com/api/v2/silences {"endsAt":"` + endsAt.Format("2006-01-02T15:04:05.000Z") +
+			`","startsAt":"2024-01-01T12:00:00.000Z","comment":"foo [karma sync: eu/1]","createdBy":"me",` +
+			`"matchers":[{"isEqual":true,"name":"alertname","value":"Foo","isRegex":false}]}`,
+		"DELETE us.example.com/api/v2/silence/2",
+	}
+	if diff := cmp.Diff(expected, requests); diff != "" {
+		t.Errorf("Wrong requests sent to Alertmanager (-want +got):\n%s", diff)
+	}
+}
+
+func TestSilenceSyncProxyTracking(t *testing.T) {
+	mockConfig(t.Setenv)
+	silenceACLs = []*silenceACL{}
+	s := mockSilenceSyncer(t, time.Now())
+	silenceSync = s
+	defer func() { silenceSync = &silenceSyncer{} }()
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://eu.example.com/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+		resp := httpmock.NewStringResponse(200, `{"silenceID":"5"}`)
+		resp.Request = req
+		return resp, nil
+	})
+
+	am, err := alertmanager.NewAlertmanager("eu", "eu", "http://eu.example.com", alertmanager.WithProxy(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testRouter()
+	setupRouter(r, nil)
+	setupRouterProxyHandlers(r, am)
+
+	body := `{"comment":"foo","createdBy":"me","startsAt":"2000-02-01T00:00:00.000Z","endsAt":"2000-02-01T00:02:03.000Z","matchers":[{"name":"alertname","value":"Foo","isEqual":true}]}`
+	req := httptest.NewRequest("POST", "/proxy/alertmanager/eu/api/v2/silences", strings.NewReader(body))
+	resp := newCloseNotifyingRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != 200 {
+		t.Fatalf("Got response code %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp.Body.String() != `{"silenceID":"5"}` {
+		t.Errorf("Wrong response body: %s", resp.Body.String())
+	}
+	if _, ok := s.tracked[silenceKey{cluster: "eu", id: "5"}]; !ok {
+		t.Errorf("Silence created via proxy is not tracked")
+	}
+}
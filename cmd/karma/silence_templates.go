@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/transform"
+)
+
+// silenceTemplateSettings returns all silence templates from karma
+// configuration in the format used by the UI
+func silenceTemplateSettings() []models.SilenceTemplate {
+	templates := make([]models.SilenceTemplate, 0, len(config.Config.SilenceForm.Templates))
+	for _, tmpl := range config.Config.SilenceForm.Templates {
+		t := models.SilenceTemplate{
+			Name:             tmpl.Name,
+			Matchers:         make([]models.SilenceTemplateMatcher, 0, len(tmpl.Matchers)),
+			DurationSeconds:  int(tmpl.Duration.Seconds()),
+			Comment:          tmpl.Comment,
+			RequireTicket:    tmpl.Require.Ticket,
+			RequiredMatchers: tmpl.Require.Matchers,
+		}
+		if t.RequiredMatchers == nil {
+			t.RequiredMatchers = []string{}
+		}
+		for _, m := range tmpl.Matchers {
+			t.Matchers = append(t.Matchers, models.SilenceTemplateMatcher{
+				Name:    m.Name,
+				Value:   m.Value,
+				IsRegex: m.IsRegex,
+			})
+		}
+		templates = append(templates, t)
+	}
+	return templates
+}
+
+// isSilenceFromTemplate returns true if silence has all the matchers from
+// given template, template matchers without a value only require a matcher
+// with the same label name to be present
+func isSilenceFromTemplate(tmpl config.SilenceTemplate, silence *models.Silence) bool {
+	for _, tm := range tmpl.Matchers {
+		if !slices.ContainsFunc(silence.Matchers, func(m models.SilenceMatcher) bool {
+			if m.Name != tm.Name || !m.IsEqual {
+				return false
+			}
+			return tm.Value == "" || (m.Value == tm.Value && m.IsRegex == tm.IsRegex)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSilenceTemplates will return an error if given silence was created
+// from any template but it's missing some of the fields required by it
+func checkSilenceTemplates(silence *models.Silence) error {
+	for _, tmpl := range config.Config.SilenceForm.Templates {
+		if !isSilenceFromTemplate(tmpl, silence) {
+			continue
+		}
+		for _, name := range tmpl.Require.Matchers {
+			if !slices.ContainsFunc(silence.Matchers, func(m models.SilenceMatcher) bool {
+				return m.Name == name && m.Value != ""
+			}) {
+				return fmt.Errorf("silence template %q requires a matcher for %q label", tmpl.Name, name)
+			}
+		}
+		if tmpl.Require.Ticket {
+			if ticketID, _ := transform.DetectLinks(silence); ticketID == "" {
+				return fmt.Errorf("silence template %q requires a ticket ID in the comment", tmpl.Name)
+			}
+		}
+	}
+	return nil
+}
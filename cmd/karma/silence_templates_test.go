@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jarcoal/httpmock"
+
+	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+	"github.com/prymitive/karma/internal/transform"
+)
+
+func mockSilenceTemplates(t *testing.T) {
+	tmpl := config.SilenceTemplate{
+		Name: "maintenance",
+		Matchers: []config.SilenceTemplateMatcher{
+			{Name: "alertname", Value: "Maintenance"},
+			{Name: "instance"},
+		},
+		Duration: time.Hour * 2,
+		Comment:  "Maintenance window for TICKET-",
+	}
+	tmpl.Require.Ticket = true
+	tmpl.Require.Matchers = []string{"instance"}
+	config.Config.SilenceForm.Templates = []config.SilenceTemplate{tmpl}
+	transform.SetLinkRules([]models.LinkDetectRule{
+		{Regex: regexp.MustCompile("(TICKET-[0-9]+)"), URITemplate: "https://tickets.example.com/$1"},
+	})
+	t.Cleanup(func() {
+		config.Config.SilenceForm.Templates = nil
+		transform.SetLinkRules(nil)
+	})
+}
+
+func TestCheckSilenceTemplates(t *testing.T) {
+	type testCaseT struct {
+		name    string
+		silence models.Silence
+		err     string
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "silence not matching any template is allowed",
+			silence: models.Silence{
+				Matchers: []models.SilenceMatcher{
+					{Name: "alertname", Value: "Foo", IsEqual: true},
+				},
+			},
+		},
+		{
+			name: "template matcher with a different value doesn't match",
+			silence: models.Silence{
+				Matchers: []models.SilenceMatcher{
+					{Name: "alertname", Value: "Maintenance", IsEqual: true, IsRegex: true},
+					{Name: "instance", Value: "web1", IsEqual: true},
+				},
+			},
+		},
+		{
+			name: "negative template matcher doesn't match",
+			silence: models.Silence{
+				Matchers: []models.SilenceMatcher{
+					{Name: "alertname", Value: "Maintenance", IsEqual: false},
+					{Name: "instance", Value: "web1", IsEqual: true},
+				},
+			},
+		},
+		{
+			name: "missing ticket is rejected",
+			silence: models.Silence{
+				Comment: "Maintenance window for TICKET-",
+				Matchers: []models.SilenceMatcher{
+					{Name: "alertname", Value: "Maintenance", IsEqual: true},
+					{Name: "instance", Value: "web1", IsEqual: true},
+				},
+			},
+			err: `silence template "maintenance" requires a ticket ID in the comment`,
+		},
+		{
+			name: "empty required matcher is rejected",
+			silence: models.Silence{
+				Comment: "Maintenance window for TICKET-123",
+				Matchers: []models.SilenceMatcher{
+					{Name: "alertname", Value: "Maintenance", IsEqual: true},
+					{Name: "instance", Value: "", IsEqual: true},
+				},
+			},
+			err: `silence template "maintenance" requires a matcher for "instance" label`,
+		},
+		{
+			name: "silence with all required fields is allowed",
+			silence: models.Silence{
+				Comment: "Maintenance window for TICKET-123",
+				Matchers: []models.SilenceMatcher{
+					{Name: "alertname", Value: "Maintenance", IsEqual: true},
+					{Name: "instance", Value: "web.+", IsEqual: true, IsRegex: true},
+				},
+			},
+		},
+	}
+
+	mockSilenceTemplates(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errMsg string
+			if err := checkSilenceTemplates(&tc.silence); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.err {
+				t.Errorf("checkSilenceTemplates() returned %q, expected %q", errMsg, tc.err)
+			}
+		})
+	}
+}
+
+func TestSilenceTemplateSettings(t *testing.T) {
+	mockSilenceTemplates(t)
+
+	expected := []models.SilenceTemplate{
+		{
+			Name: "maintenance",
+			Matchers: []models.SilenceTemplateMatcher{
+				{Name: "alertname", Value: "Maintenance"},
+				{Name: "instance"},
+			},
+			DurationSeconds:  7200,
+			Comment:          "Maintenance window for TICKET-",
+			RequireTicket:    true,
+			RequiredMatchers: []string{"instance"},
+		},
+	}
+	if diff := cmp.Diff(expected, silenceTemplateSettings()); diff != "" {
+		t.Errorf("Wrong silence templates returned (-want +got):\n%s", diff)
+	}
+}
+
+func TestProxySilenceTemplates(t *testing.T) {
+	type testCaseT struct {
+		name string
+		body string
+		code int
+	}
+
+	testCases := []testCaseT{
+		{
+			name: "silence without a ticket is rejected",
+			body: `{"comment":"maintenance","createdBy":"me","startsAt":"2000-02-01T00:00:00.000Z","endsAt":"2000-02-01T00:02:03.000Z","matchers":[{"name":"alertname","value":"Maintenance","isEqual":true},{"name":"instance","value":"web1","isEqual":true}]}`,
+			code: 400,
+		},
+		{
+			name: "silence with a ticket is allowed",
+			body: `{"comment":"maintenance TICKET-1","createdBy":"me","startsAt":"2000-02-01T00:00:00.000Z","endsAt":"2000-02-01T00:02:03.000Z","matchers":[{"name":"alertname","value":"Maintenance","isEqual":true},{"name":"instance","value":"web1","isEqual":true}]}`,
+			code: 200,
+		},
+	}
+
+	mockConfig(t.Setenv)
+	mockSilenceTemplates(t)
+	silenceACLs = []*silenceACL{}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := testRouter()
+			setupRouter(r, nil)
+			am, err := alertmanager.NewAlertmanager(
+				"cluster",
+				"proxyTemplates",
+				"http://localhost",
+				alertmanager.WithRequestTimeout(time.Second*5),
+				alertmanager.WithProxy(true),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setupRouterProxyHandlers(r, am)
+
+			httpmock.Reset()
+			httpmock.RegisterResponder("POST", "http://localhost/api/v2/silences", func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				return httpmock.NewBytesResponse(200, body), nil
+			})
+
+			req := httptest.NewRequest("POST", "/proxy/alertmanager/proxyTemplates/api/v2/silences", io.NopCloser(bytes.NewBufferString(tc.body)))
+			resp := newCloseNotifyingRecorder()
+			r.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("Got response code %d instead of %d: %s", resp.Code, tc.code, resp.Body.String())
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/prymitive/karma/internal/alertmanager"
 )
@@ -46,6 +47,15 @@ func pullFromAlertmanager() {
 	wg.Wait()
 
 	slog.Info("Collection completed")
+
+	silences := alertmanager.DedupSilences()
+	silenceSync.reconcile(upstreams, silences)
+	silenceReminders.check(silences)
+	groups := alertmanager.DedupAlerts()
+	alertmanager.TrackFlapping(groups, time.Now())
+	updateSilenceAnalysis(silences, groups, time.Now())
+	historySources.record(groups, time.Now())
+	alertHistoryRecorder.record(groups, alertmanager.UnhealthyAlertmanagers(), time.Now())
 	runtime.GC()
 }
 
@@ -39,8 +39,12 @@ func mimeJSON(w http.ResponseWriter) {
 }
 
 func badRequestJSON(w http.ResponseWriter, err string) {
+	errorJSON(w, http.StatusBadRequest, err)
+}
+
+func errorJSON(w http.ResponseWriter, code int, err string) {
 	mimeJSON(w)
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(code)
 	out, _ := marshalJSON(map[string]string{"error": err})
 	_, _ = w.Write(out)
 }
@@ -147,6 +151,7 @@ func alerts(w http.ResponseWriter, r *http.Request) {
 				Labels: config.Config.SilenceForm.Strip.Labels,
 			},
 			DefaultAlertmanagers: config.Config.SilenceForm.DefaultAlertmanagers,
+			Templates:            silenceTemplateSettings(),
 		},
 		AlertAcknowledgement: models.AlertAcknowledgementSettings{
 			Enabled:         config.Config.AlertAcknowledgement.Enabled,
@@ -383,8 +388,10 @@ func alerts(w http.ResponseWriter, r *http.Request) {
 				if alertLimit > totalAlerts {
 					alertLimit = totalAlerts
 				}
+				lifetime := alertHistoryRecorder.groupLifetime(*ag)
 				ag.Alerts = ag.Alerts[0:alertLimit]
 				apiAG := models.NewAPIAlertGroup(*ag, shared, allLabels, totalAlerts)
+				apiAG.Lifetime = lifetime
 
 				grid, found := grids[gridLabelValue]
 				if !found {
@@ -545,6 +552,82 @@ func autocomplete(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
+// silenceSearchClusters returns the list of clusters with an Alertmanager
+// instance or cluster name equal to given search term
+func silenceSearchClusters(searchTerm string) []string {
+	clusters := []string{}
+	if searchTerm == "" {
+		return clusters
+	}
+	upstreams := getUpstreams()
+	for _, u := range upstreams.Instances {
+		if strings.ToLower(u.Name) == searchTerm || strings.ToLower(u.Cluster) == searchTerm {
+			if !slices.Contains(clusters, u.Cluster) {
+				clusters = append(clusters, strings.ToLower(u.Cluster))
+			}
+		}
+	}
+	return clusters
+}
+
+// silenceMatchesSearchTerm returns true if silence matches lower cased search
+// term, clusters should be the result of silenceSearchClusters(searchTerm)
+func silenceMatchesSearchTerm(silence models.ManagedSilence, searchTerm string, clusters []string) bool {
+	switch {
+	case strings.ToLower(silence.Silence.ID) == searchTerm:
+		return true
+	case "@cluster="+strings.ToLower(silence.Cluster) == searchTerm:
+		return true
+	case slices.Contains(clusters, strings.ToLower(silence.Cluster)):
+		return true
+	case strings.Contains(strings.ToLower(silence.Silence.Comment), searchTerm):
+		return true
+	case strings.Contains(strings.ToLower(silence.Silence.CreatedBy), searchTerm):
+		return true
+	}
+	for _, match := range silence.Silence.Matchers {
+		eq := "="
+		if match.IsRegex {
+			eq = "=~"
+		}
+		if searchTerm == fmt.Sprintf("%s%s\"%s\"", strings.ToLower(match.Name), eq, strings.ToLower(match.Value)) {
+			return true
+		} else if strings.Contains(strings.ToLower(fmt.Sprintf("%s%s%s", match.Name, eq, match.Value)), searchTerm) {
+			return true
+		}
+	}
+	return false
+}
+
+// countSilencedAlerts sets AlertCount on each silence to the number of alerts
+// silenced by it
+func countSilencedAlerts(dedupedSilences []models.ManagedSilence, alertGroups []models.AlertGroup) {
+	silenceCounters := make(map[string]int, len(dedupedSilences))
+	for _, silence := range dedupedSilences {
+		silenceCounters[silence.Silence.ID] = 0
+	}
+	for _, alertGroup := range alertGroups {
+		for _, alert := range alertGroup.Alerts {
+			sidDone := map[string]struct{}{}
+			for _, am := range alert.Alertmanager {
+				for _, sID := range am.SilencedBy {
+					if _, found := sidDone[sID]; !found {
+						if _, ok := silenceCounters[sID]; ok {
+							silenceCounters[sID]++
+							sidDone[sID] = struct{}{}
+						}
+					}
+				}
+			}
+		}
+	}
+	for i := range dedupedSilences {
+		if counter, ok := silenceCounters[dedupedSilences[i].Silence.ID]; ok {
+			dedupedSilences[i].AlertCount = counter
+		}
+	}
+}
+
 func silences(w http.ResponseWriter, r *http.Request) {
 	noCache(w)
 
@@ -572,51 +655,13 @@ func silences(w http.ResponseWriter, r *http.Request) {
 		searchTerm = strings.ToLower(searchTermValue)
 	}
 
-	clusters := []string{}
-	if searchTerm != "" {
-		upstreams := getUpstreams()
-		for _, u := range upstreams.Instances {
-			if strings.ToLower(u.Name) == searchTerm || strings.ToLower(u.Cluster) == searchTerm {
-				if !slices.Contains(clusters, u.Cluster) {
-					clusters = append(clusters, strings.ToLower(u.Cluster))
-				}
-			}
-		}
-	}
-
+	clusters := silenceSearchClusters(searchTerm)
 	for _, silence := range alertmanager.DedupSilences() {
 		if silence.IsExpired && !showExpired {
 			continue
 		}
-		if searchTerm != "" {
-			isMatch := false
-			switch {
-			case strings.ToLower(silence.Silence.ID) == searchTerm:
-				isMatch = true
-			case "@cluster="+strings.ToLower(silence.Cluster) == searchTerm:
-				isMatch = true
-			case slices.Contains(clusters, strings.ToLower(silence.Cluster)):
-				isMatch = true
-			case strings.Contains(strings.ToLower(silence.Silence.Comment), searchTerm):
-				isMatch = true
-			case strings.Contains(strings.ToLower(silence.Silence.CreatedBy), searchTerm):
-				isMatch = true
-			default:
-				for _, match := range silence.Silence.Matchers {
-					eq := "="
-					if match.IsRegex {
-						eq = "=~"
-					}
-					if searchTerm == fmt.Sprintf("%s%s\"%s\"", strings.ToLower(match.Name), eq, strings.ToLower(match.Value)) {
-						isMatch = true
-					} else if strings.Contains(strings.ToLower(fmt.Sprintf("%s%s%s", match.Name, eq, match.Value)), searchTerm) {
-						isMatch = true
-					}
-				}
-			}
-			if !isMatch {
-				continue
-			}
+		if searchTerm != "" && !silenceMatchesSearchTerm(silence, searchTerm, clusters) {
+			continue
 		}
 		dedupedSilences = append(dedupedSilences, silence)
 	}
@@ -637,30 +682,7 @@ func silences(w http.ResponseWriter, r *http.Request) {
 		return dedupedSilences[i].Silence.EndsAt.Before(dedupedSilences[j].Silence.EndsAt) == recentFirst
 	})
 
-	silenceCounters := make(map[string]int, len(dedupedSilences))
-	for _, silence := range dedupedSilences {
-		silenceCounters[silence.Silence.ID] = 0
-	}
-	for _, alertGroup := range alertmanager.DedupAlerts() {
-		for _, alert := range alertGroup.Alerts {
-			sidDone := map[string]struct{}{}
-			for _, am := range alert.Alertmanager {
-				for _, sID := range am.SilencedBy {
-					if _, found := sidDone[sID]; !found {
-						if _, ok := silenceCounters[sID]; ok {
-							silenceCounters[sID]++
-							sidDone[sID] = struct{}{}
-						}
-					}
-				}
-			}
-		}
-	}
-	for i := range dedupedSilences {
-		if counter, ok := silenceCounters[dedupedSilences[i].Silence.ID]; ok {
-			dedupedSilences[i].AlertCount = counter
-		}
-	}
+	countSilencedAlerts(dedupedSilences, alertmanager.DedupAlerts())
 
 	data, _ = marshalJSON(dedupedSilences)
 	_ = apiCache.Add(cacheKey, data)
@@ -732,6 +754,61 @@ func alertList(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
+type FilterExplanation struct {
+	Text    string `json:"text"`
+	Name    string `json:"name"`
+	Matcher string `json:"matcher"`
+	Value   string `json:"value"`
+	Error   string `json:"error"`
+	Matches int    `json:"matches"`
+	IsValid bool   `json:"isValid"`
+}
+
+type FilterExplainResponse struct {
+	Filters []FilterExplanation `json:"filters"`
+	Matches int                 `json:"matches"`
+}
+
+func countFilteredAlerts(dedupedAlerts []models.AlertGroup, fl []filters.Filter) (total int) {
+	for _, ag := range filterAlerts(dedupedAlerts, fl) {
+		total += len(ag.Alerts)
+	}
+	return total
+}
+
+// explain how each filter passed via q= parameter is parsed and how many
+// alerts it matches, both alone and combined with all other filters
+func filterExplain(w http.ResponseWriter, r *http.Request) {
+	noCache(w)
+
+	q, _ := lookupQueryStringSlice(r, "q")
+	dedupedAlerts := alertmanager.DedupAlerts()
+
+	resp := FilterExplainResponse{
+		Filters: make([]FilterExplanation, 0, len(q)),
+	}
+	for _, filter := range getFiltersFromQuery(q) {
+		fe := FilterExplanation{
+			Text:    filter.RawText(),
+			Name:    filter.Name(),
+			Matcher: filter.MatcherOperation(),
+			Value:   filter.Value(),
+			Error:   filter.Error(),
+			IsValid: filter.Valid(),
+		}
+		if fe.IsValid {
+			fe.Matches = countFilteredAlerts(dedupedAlerts, getFiltersFromQuery([]string{fe.Text}))
+		}
+		resp.Filters = append(resp.Filters, fe)
+	}
+	resp.Matches = countFilteredAlerts(dedupedAlerts, getFiltersFromQuery(q))
+
+	mimeJSON(w)
+	w.WriteHeader(http.StatusOK)
+	data, _ := marshalJSON(resp)
+	_, _ = w.Write(data)
+}
+
 func sortSliceOfLabels(ls []models.OrderedLabels, sortKeys []string, fallback string) {
 	sort.SliceStable(ls, func(i, j int) bool {
 		for _, k := range sortKeys {
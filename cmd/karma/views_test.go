@@ -2683,6 +2683,88 @@ func TestAlertList(t *testing.T) {
 	}
 }
 
+func TestFilterExplain(t *testing.T) {
+	type testCaseT struct {
+		args string
+		resp FilterExplainResponse
+	}
+
+	testCases := []testCaseT{
+		{
+			args: "",
+			resp: FilterExplainResponse{
+				Filters: []FilterExplanation{},
+				Matches: 24,
+			},
+		},
+		{
+			args: "q=alertname=Host_Down&q=cluster=prod",
+			resp: FilterExplainResponse{
+				Filters: []FilterExplanation{
+					{Text: "alertname=Host_Down", Name: "alertname", Matcher: "=", Value: "Host_Down", IsValid: true, Matches: 16},
+					{Text: "cluster=prod", Name: "cluster", Matcher: "=", Value: "prod", IsValid: true, Matches: 6},
+				},
+				Matches: 4,
+			},
+		},
+		{
+			args: "q=alertname=Host_Down&q=@state=~active&q=instance=~server[",
+			resp: FilterExplainResponse{
+				Filters: []FilterExplanation{
+					{Text: "alertname=Host_Down", Name: "alertname", Matcher: "=", Value: "Host_Down", IsValid: true, Matches: 16},
+					{Text: "@state=~active", Error: `operator "=~" is not supported by @state, supported operators: = !=`},
+					{Text: "instance=~server[", Error: "invalid regular expression \"server[\": error parsing regexp: missing closing ]: `[`"},
+				},
+				Matches: 16,
+			},
+		},
+		{
+			args: "q=!alertname=Host_Down",
+			resp: FilterExplainResponse{
+				Filters: []FilterExplanation{
+					{Text: "!alertname=Host_Down", Name: "!alertname", Matcher: "=", Value: "Host_Down", IsValid: true, Matches: 8},
+				},
+				Matches: 8,
+			},
+		},
+		{
+			args: "q=foo=bar",
+			resp: FilterExplainResponse{
+				Filters: []FilterExplanation{
+					{Text: "foo=bar", Name: "foo", Matcher: "=", Value: "bar", IsValid: true},
+				},
+			},
+		},
+	}
+
+	mockConfig(t.Setenv)
+	for _, tc := range testCases {
+		for _, version := range mock.ListAllMocks() {
+			t.Run(fmt.Sprintf("%s:%s", version, tc.args), func(t *testing.T) {
+				mockAlerts(version)
+				r := testRouter()
+				setupRouter(r, nil)
+				req := httptest.NewRequest("GET", "/filters/explain.json?"+tc.args, nil)
+				resp := httptest.NewRecorder()
+				r.ServeHTTP(resp, req)
+				if resp.Code != http.StatusOK {
+					t.Errorf("GET /filters/explain.json returned status %d", resp.Code)
+				}
+
+				ur := FilterExplainResponse{}
+				err := json.Unmarshal(resp.Body.Bytes(), &ur)
+				if err != nil {
+					t.Errorf("Failed to unmarshal response: %s", err)
+				}
+				if diff := cmp.Diff(tc.resp, ur); diff != "" {
+					t.Errorf("Wrong filter explanation returned (-want +got):\n%s", diff)
+				}
+			})
+			break
+		}
+	}
+}
+
 func TestSortSliceOfLabels(t *testing.T) {
 	type testCaseT struct {
 		labels   []models.OrderedLabels
@@ -27,6 +27,7 @@ func DedupAlerts() []models.AlertGroup {
 		}
 	}
 
+	now := time.Now()
 	dedupedGroups := make([]models.AlertGroup, 0, len(uniqueGroups))
 	alertStates := map[string][]models.AlertState{}
 	for _, agList := range uniqueGroups {
@@ -117,6 +118,7 @@ func DedupAlerts() []models.AlertGroup {
 			default:
 				alert.State = models.AlertStateUnprocessed
 			}
+			alert.FlapScore = flapping.score(alertLFP, now, config.Config.Flapping.Window)
 
 			// sort Alertmanager instances for every alert
 			sort.Slice(alert.Alertmanager, func(i, j int) bool {
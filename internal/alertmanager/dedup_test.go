@@ -120,8 +120,11 @@ func TestDedupAutocomplete(t *testing.T) {
 	// 2 hints per @alertmanager
 	// 2 hits per @cluster
 	// 6 hints for silences in for each alertmanager
+	// 12 hints for @label_name, 2 per each label name
+	// 22 hints for @has, 2 per each label and annotation name
+	// 6 hints for negated @age and @inhibited
 	// silence id might get duplicated so this check isn't very strict
-	expected := 74 + 4 + mockCount*2 + mockCount*2 + mockCount*6
+	expected := 74 + 4 + mockCount*2 + mockCount*2 + mockCount*6 + 12 + 22 + 6
 	if len(ac) <= int(float64(expected)*0.8) || len(ac) > expected {
 		t.Errorf("Expected %d autocomplete hints, got %d", expected, len(ac))
 	}
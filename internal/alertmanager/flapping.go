@@ -0,0 +1,102 @@
+package alertmanager
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prymitive/karma/internal/config"
+	"github.com/prymitive/karma/internal/models"
+)
+
+// flapState holds all transitions of a single alert, every time an alert
+// appears or disappears we record a new transition
+type flapState struct {
+	transitions []time.Time
+	// names of Alertmanager instances the alert was last seen on
+	alertmanagers []string
+	isPresent     bool
+}
+
+type flapTracker struct {
+	alerts        map[string]*flapState
+	isInitialized bool
+	lock          sync.RWMutex
+}
+
+var flapping = newFlapTracker()
+
+func newFlapTracker() *flapTracker {
+	return &flapTracker{alerts: map[string]*flapState{}}
+}
+
+// TrackFlapping records alert transitions, it should be called once after
+// each collection cycle with the result of DedupAlerts()
+func TrackFlapping(groups []models.AlertGroup, now time.Time) {
+	flapping.update(groups, UnhealthyAlertmanagers(), now, config.Config.Flapping.Window)
+}
+
+func (ft *flapTracker) update(groups []models.AlertGroup, unhealthy map[string]bool, now time.Time, window time.Duration) {
+	seen := map[string][]string{}
+	for _, ag := range groups {
+		for _, alert := range ag.Alerts {
+			for _, am := range alert.Alertmanager {
+				seen[alert.LabelsFP] = append(seen[alert.LabelsFP], am.Name)
+			}
+		}
+	}
+
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	for fp, alertmanagers := range seen {
+		fs, ok := ft.alerts[fp]
+		if !ok {
+			fs = &flapState{}
+			ft.alerts[fp] = fs
+		}
+		// all alerts are new when we collect them for the first time, don't
+		// count those as transitions
+		if !fs.isPresent && ft.isInitialized {
+			fs.transitions = append(fs.transitions, now)
+		}
+		fs.isPresent = true
+		fs.alertmanagers = alertmanagers
+	}
+	ft.isInitialized = true
+
+	cutoff := now.Add(-window)
+	for fp, fs := range ft.alerts {
+		if _, ok := seen[fp]; !ok && fs.isPresent {
+			// alert might be missing only because we failed to collect alerts
+			// from Alertmanager, don't count that as a transition
+			if !slices.ContainsFunc(fs.alertmanagers, func(name string) bool { return unhealthy[name] }) {
+				fs.isPresent = false
+				fs.transitions = append(fs.transitions, now)
+			}
+		}
+		fs.transitions = slices.DeleteFunc(fs.transitions, func(ts time.Time) bool { return !ts.After(cutoff) })
+		if !fs.isPresent && len(fs.transitions) == 0 {
+			delete(ft.alerts, fp)
+		}
+	}
+}
+
+// score returns the number of transitions of given alert in the last window
+func (ft *flapTracker) score(fp string, now time.Time, window time.Duration) int {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+
+	fs, ok := ft.alerts[fp]
+	if !ok {
+		return 0
+	}
+	cutoff := now.Add(-window)
+	var score int
+	for _, ts := range fs.transitions {
+		if ts.After(cutoff) {
+			score++
+		}
+	}
+	return score
+}
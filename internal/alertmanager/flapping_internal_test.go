@@ -0,0 +1,84 @@
+package alertmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+func TestFlapTracker(t *testing.T) {
+	start := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	window := time.Hour
+
+	alert := func(fp string, alertmanagers ...string) models.Alert {
+		a := models.Alert{LabelsFP: fp}
+		for _, name := range alertmanagers {
+			a.Alertmanager = append(a.Alertmanager, models.AlertmanagerInstance{Name: name})
+		}
+		return a
+	}
+	groups := func(alerts ...models.Alert) []models.AlertGroup {
+		return []models.AlertGroup{{Alerts: alerts}}
+	}
+
+	type step struct {
+		groups    []models.AlertGroup
+		unhealthy map[string]bool
+		scores    map[string]int
+	}
+
+	steps := []step{
+		{
+			// alerts seen on the first collection are not new
+			groups: groups(alert("a", "am1"), alert("b", "am1", "am2")),
+			scores: map[string]int{"a": 0, "b": 0},
+		},
+		{
+			// a is resolved, c is new
+			groups: groups(alert("b", "am1", "am2"), alert("c", "am2")),
+			scores: map[string]int{"a": 1, "b": 0, "c": 1},
+		},
+		{
+			// a fires again, c is missing because am2 failed
+			groups:    groups(alert("a", "am1"), alert("b", "am1")),
+			unhealthy: map[string]bool{"am2": true},
+			scores:    map[string]int{"a": 2, "b": 0, "c": 1},
+		},
+		{
+			groups: groups(alert("b", "am1", "am2"), alert("c", "am2")),
+			scores: map[string]int{"a": 3, "b": 0, "c": 1},
+		},
+		{
+			groups: groups(alert("a", "am1"), alert("b", "am1", "am2"), alert("c", "am2")),
+			scores: map[string]int{"a": 4, "b": 0, "c": 1},
+		},
+	}
+
+	ft := newFlapTracker()
+	now := start
+	for i, s := range steps {
+		ft.update(s.groups, s.unhealthy, now, window)
+		for fp, expected := range s.scores {
+			if score := ft.score(fp, now, window); score != expected {
+				t.Errorf("[%d] score(%q) returned %d, expected %d", i, fp, score, expected)
+			}
+		}
+		now = now.Add(time.Minute)
+	}
+
+	// transitions older than window are removed
+	now = now.Add(window)
+	ft.update(groups(alert("a", "am1")), nil, now, window)
+	for fp, expected := range map[string]int{"a": 0, "b": 1, "c": 1} {
+		if score := ft.score(fp, now, window); score != expected {
+			t.Errorf("score(%q) returned %d, expected %d", fp, score, expected)
+		}
+	}
+
+	now = now.Add(window)
+	ft.update(groups(alert("a", "am1")), nil, now, window)
+	if len(ft.alerts) != 1 {
+		t.Errorf("Expected only a single alert to be tracked, got %d", len(ft.alerts))
+	}
+}
@@ -142,6 +142,26 @@ func (am *Alertmanager) pullSilences(version string) error {
 	return nil
 }
 
+// CreateSilence sends given silence to this Alertmanager instance and returns
+// the ID of created silence. If silence has an ID set then Alertmanager will
+// update that silence instead.
+func (am *Alertmanager) CreateSilence(silence models.Silence) (string, error) {
+	m, err := mapper.GetSilenceMapper(am.Version())
+	if err != nil {
+		return "", err
+	}
+	return m.Create(am.URI, am.HTTPHeaders, am.RequestTimeout, am.HTTPTransport, silence)
+}
+
+// ExpireSilence expires silence with given ID on this Alertmanager instance
+func (am *Alertmanager) ExpireSilence(silenceID string) error {
+	m, err := mapper.GetSilenceMapper(am.Version())
+	if err != nil {
+		return err
+	}
+	return m.Expire(am.URI, am.HTTPHeaders, am.RequestTimeout, am.HTTPTransport, silenceID)
+}
+
 // InternalURI is the URI of this Alertmanager that will be used for all request made by the UI
 func (am *Alertmanager) InternalURI() string {
 	if am.ProxyRequests {
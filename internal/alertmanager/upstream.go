@@ -99,6 +99,18 @@ func GetAlertmanagers() []*Alertmanager {
 	return ams
 }
 
+// UnhealthyAlertmanagers returns names of all Alertmanager instances that
+// failed the last collection, alerts from those might be missing
+func UnhealthyAlertmanagers() map[string]bool {
+	unhealthy := map[string]bool{}
+	for _, am := range GetAlertmanagers() {
+		if !am.IsHealthy() {
+			unhealthy[am.Name] = true
+		}
+	}
+	return unhealthy
+}
+
 // GetAlertmanagerByName returns an instance of Alertmanager by name or nil
 // if not found
 func GetAlertmanagerByName(name string) *Alertmanager {
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	yaml "go.yaml.in/yaml/v3"
 )
@@ -24,9 +25,9 @@ type SilenceFilters struct {
 	IsRegex    *bool  `yaml:"isRegex,omitempty"`
 	IsEqual    *bool  `yaml:"isEqual,omitempty"`
 	Name       string `yaml:"name,omitempty"`
-	NameRegex  string `yaml:"name_re,omitempty"`
+	NameRegex  string `yaml:"name_re,omitempty" koanf:"name_re"`
 	Value      string `yaml:"value,omitempty"`
-	ValueRegex string `yaml:"value_re,omitempty"`
+	ValueRegex string `yaml:"value_re,omitempty" koanf:"value_re"`
 }
 
 type SilenceACLRuleScope struct {
@@ -35,11 +36,19 @@ type SilenceACLRuleScope struct {
 	Filters       []SilenceFilters
 }
 
+type SilenceACLConstraints struct {
+	MaxDuration   time.Duration `yaml:"maxDuration"`
+	MaxStartsIn   time.Duration `yaml:"maxStartsIn"`
+	CommentRegex  string        `yaml:"commentRegex"`
+	RequireTicket bool          `yaml:"requireTicket"`
+}
+
 type SilenceACLRule struct {
-	Action   string
-	Reason   string
-	Scope    SilenceACLRuleScope
-	Matchers SilenceACLMatchersConfig
+	Action      string
+	Reason      string
+	Scope       SilenceACLRuleScope
+	Matchers    SilenceACLMatchersConfig
+	Constraints SilenceACLConstraints
 }
 
 type SilencesACLSchema struct {
@@ -90,6 +90,12 @@ func SetupFlags(f *pflag.FlagSet) {
 	f.Bool("debug", false, "Enable debug mode")
 
 	f.StringSlice("filters.default", []string{}, "List of default filters")
+	f.String("filters.presets.path", "", "Path to a file used to store user created filter presets")
+
+	f.Duration("flapping.window", time.Hour, "Time window used to calculate alert flap score")
+	f.Int("flapping.threshold", 4, "Flap score at which alerts are considered to be flapping")
+
+	f.Bool("graphql.enabled", false, "Enable GraphQL API endpoint")
 
 	f.StringSlice("labels.order", []string{}, "Preferred order of label names")
 	f.StringSlice("labels.color.static", []string{},
@@ -117,6 +123,11 @@ func SetupFlags(f *pflag.FlagSet) {
 	f.Bool("history.enabled", true, "Enable alert history queries")
 	f.Duration("history.timeout", time.Second*20, "Timeout for history queries against source Prometheus servers")
 	f.Int("history.workers", 30, "Number of history query workers to run")
+	f.Duration("history.maxRange", time.Hour*24*30, "Maximum time range that can be requested for alert history")
+	f.Duration("history.minStep", time.Minute*5, "Minimum resolution that can be requested for alert history")
+	f.Int("history.maxSamples", 1000, "Maximum number of samples that can be requested for alert history")
+	f.Bool("history.recorder.enabled", false, "Record alert history from collected alerts and use it when Prometheus can't be queried")
+	f.String("history.recorder.path", "", "Path to a file used to store recorded alert history")
 
 	f.Bool("log.config", false, "Log used configuration to log on startup")
 	f.String("log.level", "info",
@@ -136,6 +147,16 @@ func SetupFlags(f *pflag.FlagSet) {
 		"List of regular expressions to ignore matching receivers")
 
 	f.Duration("silences.expired", time.Minute*10, "Maximum age of expired silences to show on active alerts")
+	f.String("silences.schedules.path", "", "Path to a file used to store recurring silence schedules")
+	f.Duration("silences.schedules.lead", time.Hour, "How long before each scheduled window karma will create the silence")
+	f.String("silences.sync.path", "", "Path to a file used to store silence replicas expired by hand")
+	f.Duration("silences.reminders.before", 0, "Notify silence authors this long before their silences expire, 0 disables reminders")
+	f.String("silences.reminders.webhook.uri", "", "Webhook URI used to send silence expiry reminders")
+	f.Duration("silences.reminders.webhook.timeout", time.Second*10, "Timeout for requests sent to the silence reminder webhook")
+	f.String("silences.reminders.extendLink.secret", "", "Secret used to sign silence extension links, links are disabled if not set")
+	f.String("silences.reminders.extendLink.baseURL", "", "Public URL of karma used to generate silence extension links")
+	f.Duration("silences.reminders.extendLink.duration", time.Hour*24, "How long silences are extended by when using extension links if the original silence duration is not known")
+	f.Int("silences.preview.warnPercent", 25, "Warn when a silence preview matches more than this percent of all alerts, 0 disables the warning")
 	f.StringSlice("silenceForm.strip.labels", []string{}, "List of labels to ignore when auto-filling silence form from alerts")
 	f.StringSlice("silenceForm.defaultAlertmanagers", []string{}, "List of Alertmanager names to use as default when creating a new silence")
 
@@ -461,9 +482,133 @@ func (config *configSchema) Read(flags *pflag.FlagSet) (string, error) {
 		return "", errors.New("listen.tls.cert must be set when listen.tls.key is set")
 	}
 
+	presetNames := map[string]struct{}{}
+	for _, preset := range config.Filters.Presets.Static {
+		if preset.Name == "" {
+			return "", errors.New("'name' is required for every filter preset")
+		}
+		if len(preset.Filters) == 0 {
+			return "", fmt.Errorf("filter preset %q has no filters", preset.Name)
+		}
+		if _, found := presetNames[preset.Name]; found {
+			return "", fmt.Errorf("duplicated filter preset name %q", preset.Name)
+		}
+		presetNames[preset.Name] = struct{}{}
+	}
+
+	templateNames := map[string]struct{}{}
+	for _, tmpl := range config.SilenceForm.Templates {
+		if tmpl.Name == "" {
+			return "", errors.New("'name' is required for every silence template")
+		}
+		if _, found := templateNames[tmpl.Name]; found {
+			return "", fmt.Errorf("duplicated silence template name %q", tmpl.Name)
+		}
+		templateNames[tmpl.Name] = struct{}{}
+		if len(tmpl.Matchers) == 0 {
+			return "", fmt.Errorf("silence template %q has no matchers", tmpl.Name)
+		}
+		for _, m := range tmpl.Matchers {
+			if m.Name == "" {
+				return "", fmt.Errorf("silence template %q has a matcher without a name", tmpl.Name)
+			}
+			if m.IsRegex {
+				if _, err = regex.CompileAnchored(m.Value); err != nil {
+					return "", fmt.Errorf("silence template %q matcher %q has invalid regex %q: %w", tmpl.Name, m.Name, m.Value, err)
+				}
+			}
+		}
+		if tmpl.Duration < 0 {
+			return "", fmt.Errorf("silence template %q duration must be >= 0", tmpl.Name)
+		}
+		if tmpl.Require.Ticket && len(config.Silences.Comments.LinkDetect.Rules) == 0 {
+			return "", fmt.Errorf("silence template %q requires a ticket but there are no silences.comments.linkDetect rules configured", tmpl.Name)
+		}
+	}
+
+	if config.Silences.Schedules.Lead < 0 {
+		return "", errors.New("silences.schedules.lead must be >= 0")
+	}
+
+	syncGroupNames := map[string]struct{}{}
+	syncGroupClusters := map[string]string{}
+	for _, group := range config.Silences.Sync.Groups {
+		if group.Name == "" {
+			return "", errors.New("'name' is required for every silence sync group")
+		}
+		if _, ok := syncGroupNames[group.Name]; ok {
+			return "", fmt.Errorf("duplicated silence sync group name %q", group.Name)
+		}
+		syncGroupNames[group.Name] = struct{}{}
+		if len(slices.Compact(slices.Sorted(slices.Values(group.Clusters)))) < 2 {
+			return "", fmt.Errorf("silence sync group %q must have at least two clusters", group.Name)
+		}
+		for _, cluster := range group.Clusters {
+			if other, ok := syncGroupClusters[cluster]; ok && other != group.Name {
+				return "", fmt.Errorf("cluster %q is used in multiple silence sync groups: %q and %q", cluster, other, group.Name)
+			}
+			syncGroupClusters[cluster] = group.Name
+		}
+	}
+
+	if config.Silences.Reminders.Before < 0 {
+		return "", errors.New("silences.reminders.before must be >= 0")
+	}
+	if config.Silences.Reminders.Before > 0 && config.Silences.Reminders.Webhook.URI == "" {
+		return "", errors.New("silences.reminders.webhook.uri is required when silences.reminders.before is set")
+	}
+	if config.Silences.Reminders.ExtendLink.Secret != "" && config.Silences.Reminders.ExtendLink.BaseURL == "" {
+		return "", errors.New("silences.reminders.extendLink.baseURL is required when silences.reminders.extendLink.secret is set")
+	}
+	if config.Silences.Reminders.ExtendLink.Secret != "" && config.Silences.Reminders.ExtendLink.Duration <= 0 {
+		return "", errors.New("silences.reminders.extendLink.duration must be > 0")
+	}
+
+	if config.Silences.Preview.WarnPercent < 0 || config.Silences.Preview.WarnPercent > 100 {
+		return "", errors.New("silences.preview.warnPercent must be between 0 and 100")
+	}
+
+	if config.Flapping.Window <= 0 {
+		return "", errors.New("flapping.window must be > 0")
+	}
+	if config.Flapping.Threshold < 1 {
+		return "", errors.New("flapping.threshold must be >= 1")
+	}
+
 	if config.History.Workers < 1 {
 		return "", errors.New("history.workers must be >= 1")
 	}
+	if config.History.MaxRange < time.Hour*24 {
+		return "", errors.New("history.maxRange must be >= 24h")
+	}
+	if config.History.MinStep <= 0 || config.History.MinStep > time.Hour {
+		return "", errors.New("history.minStep must be > 0 and <= 1h")
+	}
+	if config.History.MaxSamples < 24 {
+		return "", errors.New("history.maxSamples must be >= 24")
+	}
+	historyGroupNames := map[string]struct{}{}
+	for i := 0; i < len(config.History.Groups); i++ {
+		group := &config.History.Groups[i]
+		if group.Name == "" {
+			return "", errors.New("'name' is required for every history group")
+		}
+		if _, ok := historyGroupNames[group.Name]; ok {
+			return "", fmt.Errorf("duplicated history group name %q", group.Name)
+		}
+		historyGroupNames[group.Name] = struct{}{}
+		if len(group.Sources) == 0 && group.URI == "" {
+			return "", fmt.Errorf("history group %q must have a uri when no sources are set", group.Name)
+		}
+		group.SourceRegexes = make([]*regexp.Regexp, 0, len(group.Sources))
+		for _, source := range group.Sources {
+			re, err := regex.CompileAnchored(source)
+			if err != nil {
+				return "", fmt.Errorf("history group %q source regex %q is invalid: %w", group.Name, source, err)
+			}
+			group.SourceRegexes = append(group.SourceRegexes, re)
+		}
+	}
 	for i := 0; i < len(config.History.Rewrite); i++ {
 		config.History.Rewrite[i].SourceRegex, err = regex.CompileAnchored(config.History.Rewrite[i].Source)
 		if err != nil {
@@ -533,6 +678,18 @@ func (config *configSchema) LogValues() {
 	}
 	cfg.Alertmanager.Servers = servers
 
+	historyGroups := make([]HistoryGroup, 0, len(cfg.History.Groups))
+	for _, g := range cfg.History.Groups {
+		g.URI = uri.SanitizeURI(g.URI)
+		historyGroups = append(historyGroups, g)
+	}
+	cfg.History.Groups = historyGroups
+
+	cfg.Silences.Reminders.Webhook.URI = uri.SanitizeURI(cfg.Silences.Reminders.Webhook.URI)
+	if cfg.Silences.Reminders.ExtendLink.Secret != "" {
+		cfg.Silences.Reminders.ExtendLink.Secret = "***"
+	}
+
 	var buf bytes.Buffer
 	enc := yaml.NewEncoder(&buf)
 	enc.SetIndent(2)
@@ -77,6 +77,14 @@ filters:
   default:
     - '@state=active'
     - foo=bar
+  presets:
+    path: ""
+    static: []
+flapping:
+  window: 1h0m0s
+  threshold: 4
+graphql:
+  enabled: false
 grid:
   sorting:
     order: startsAt
@@ -92,6 +100,13 @@ history:
   enabled: true
   workers: 30
   timeout: 20s
+  maxRange: 720h0m0s
+  minStep: 5m0s
+  maxSamples: 1000
+  recorder:
+    enabled: false
+    path: ""
+  groups: []
   rewrite: []
 karma:
   name: another karma
@@ -146,6 +161,24 @@ receivers:
   strip_re: []
 silences:
   expired: 10m0s
+  schedules:
+    path: ""
+    lead: 1h0m0s
+  preview:
+    warnPercent: 25
+  sync:
+    groups: []
+    path: ""
+  reminders:
+    before: 0s
+    webhook:
+      uri: ""
+      timeout: 10s
+    selector: []
+    extendLink:
+      secret: ""
+      baseURL: ""
+      duration: 24h0m0s
   comments:
     linkDetect:
       rules: []
@@ -153,6 +186,7 @@ silenceForm:
   strip:
     labels: []
   defaultAlertmanagers: []
+  templates: []
 ui:
   refresh: 30s
   hideFiltersWhenIdle: true
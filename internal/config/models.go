@@ -63,6 +63,49 @@ type AuthorizationGroup struct {
 	Members []string
 }
 
+type FilterPreset struct {
+	Name    string   `yaml:"name"`
+	Filters []string `yaml:"filters"`
+	Groups  []string `yaml:"groups"`
+}
+
+type SilenceSyncGroup struct {
+	Name     string           `yaml:"name"`
+	Clusters []string         `yaml:"clusters"`
+	Selector []SilenceFilters `yaml:"selector"`
+}
+
+type SilenceRemindersConfig struct {
+	Before  time.Duration
+	Webhook struct {
+		URI     string
+		Timeout time.Duration
+	}
+	Selector   []SilenceFilters `yaml:"selector"`
+	ExtendLink struct {
+		Secret   string
+		BaseURL  string `yaml:"baseURL" koanf:"baseURL"`
+		Duration time.Duration
+	} `yaml:"extendLink" koanf:"extendLink"`
+}
+
+type SilenceTemplateMatcher struct {
+	Name    string `yaml:"name"`
+	Value   string `yaml:"value"`
+	IsRegex bool   `yaml:"isRegex" koanf:"isRegex"`
+}
+
+type SilenceTemplate struct {
+	Name     string                   `yaml:"name"`
+	Matchers []SilenceTemplateMatcher `yaml:"matchers"`
+	Duration time.Duration            `yaml:"duration"`
+	Comment  string                   `yaml:"comment"`
+	Require  struct {
+		Ticket   bool     `yaml:"ticket"`
+		Matchers []string `yaml:"matchers"`
+	} `yaml:"require"`
+}
+
 type HistoryRewrite struct {
 	Source      string            `yaml:"source"`
 	SourceRegex *regexp.Regexp    `yaml:"-"`
@@ -72,6 +115,14 @@ type HistoryRewrite struct {
 	Headers     map[string]string `yaml:"headers" koanf:"headers"`
 }
 
+type HistoryGroup struct {
+	Name          string           `yaml:"name"`
+	Sources       []string         `yaml:"sources"`
+	SourceRegexes []*regexp.Regexp `yaml:"-"`
+	URI           string           `yaml:"uri"`
+	ReplicaLabels []string         `yaml:"replicaLabels" koanf:"replicaLabels"`
+}
+
 type configSchema struct {
 	Authentication struct {
 		Enabled bool `yaml:"-" koanf:"-"`
@@ -132,7 +183,18 @@ type configSchema struct {
 	Debug   bool
 	Filters struct {
 		Default []string
+		Presets struct {
+			Path   string
+			Static []FilterPreset
+		}
+	}
+	Flapping struct {
+		Window    time.Duration
+		Threshold int
 	}
+	GraphQL struct {
+		Enabled bool
+	} `yaml:"graphql" koanf:"graphql"`
 	Grid struct {
 		Sorting struct {
 			Order        string
@@ -149,9 +211,17 @@ type configSchema struct {
 		GroupLimit int `yaml:"groupLimit"`
 	} `yaml:"grid"`
 	History struct {
-		Enabled bool
-		Workers int
-		Timeout time.Duration
+		Enabled    bool
+		Workers    int
+		Timeout    time.Duration
+		MaxRange   time.Duration `yaml:"maxRange" koanf:"maxRange"`
+		MinStep    time.Duration `yaml:"minStep" koanf:"minStep"`
+		MaxSamples int           `yaml:"maxSamples" koanf:"maxSamples"`
+		Recorder   struct {
+			Enabled bool
+			Path    string
+		}
+		Groups  []HistoryGroup
 		Rewrite []HistoryRewrite
 	}
 	Karma struct {
@@ -206,8 +276,20 @@ type configSchema struct {
 		CompiledStripRegex []*regexp.Regexp `yaml:"-"`
 	}
 	Silences struct {
-		Expired  time.Duration
-		Comments struct {
+		Expired   time.Duration
+		Schedules struct {
+			Path string
+			Lead time.Duration
+		}
+		Preview struct {
+			WarnPercent int `yaml:"warnPercent" koanf:"warnPercent"`
+		}
+		Sync struct {
+			Groups []SilenceSyncGroup `yaml:"groups"`
+			Path   string
+		}
+		Reminders SilenceRemindersConfig
+		Comments  struct {
 			LinkDetect struct {
 				Rules []LinkDetectRules `yaml:"rules"`
 			} `yaml:"linkDetect" koanf:"linkDetect"`
@@ -217,7 +299,8 @@ type configSchema struct {
 		Strip struct {
 			Labels []string
 		}
-		DefaultAlertmanagers []string `yaml:"defaultAlertmanagers" koanf:"defaultAlertmanagers"`
+		DefaultAlertmanagers []string          `yaml:"defaultAlertmanagers" koanf:"defaultAlertmanagers"`
+		Templates            []SilenceTemplate `yaml:"templates"`
 	} `yaml:"silenceForm" koanf:"silenceForm"`
 	// nolint: maligned
 	UI UIConfig
@@ -0,0 +1,130 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prymitive/karma/internal/cron"
+)
+
+func TestParseErrors(t *testing.T) {
+	type testCaseT struct {
+		expr string
+		err  string
+	}
+
+	testCases := []testCaseT{
+		{expr: "", err: `invalid cron expression "", expected 5 fields, got 0`},
+		{expr: "* * * *", err: `invalid cron expression "* * * *", expected 5 fields, got 4`},
+		{expr: "60 * * * *", err: `invalid cron expression "60 * * * *": minute value 60 is out of range 0-59`},
+		{expr: "* 24 * * *", err: `invalid cron expression "* 24 * * *": hour value 24 is out of range 0-23`},
+		{expr: "* * 0 * *", err: `invalid cron expression "* * 0 * *": day of month value 0 is out of range 1-31`},
+		{expr: "* * * foo *", err: `invalid cron expression "* * * foo *": invalid month value "foo"`},
+		{expr: "* * * * 8", err: `invalid cron expression "* * * * 8": day of week value 8 is out of range 0-7`},
+		{expr: "*/0 * * * *", err: `invalid cron expression "*/0 * * * *": invalid minute step "0"`},
+		{expr: "5-1 * * * *", err: `invalid cron expression "5-1 * * * *": invalid minute range "5-1"`},
+		{expr: "1,,2 * * * *", err: `invalid cron expression "1,,2 * * * *": invalid minute value ""`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := cron.Parse(tc.expr)
+			if err == nil {
+				t.Fatalf("Parse(%q) didn't return any error", tc.expr)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("Parse(%q) returned error %q, expected %q", tc.expr, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCaseT struct {
+		expr string
+		from time.Time
+		next time.Time
+	}
+
+	testCases := []testCaseT{
+		{
+			expr: "* * * * *",
+			from: time.Date(2026, 1, 1, 10, 0, 30, 0, time.UTC),
+			next: time.Date(2026, 1, 1, 10, 1, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 2 * * 0",
+			from: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 2 * * sun",
+			from: time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 1, 11, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 2 * * 7",
+			from: time.Date(2026, 1, 4, 1, 59, 59, 0, time.UTC),
+			next: time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "*/15 9-17 * * mon-fri",
+			from: time.Date(2026, 1, 2, 17, 50, 0, 0, time.UTC),
+			next: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "30 4 1,15 * *",
+			from: time.Date(2026, 1, 15, 5, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 2, 1, 4, 30, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 0 1 jan *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			next: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// day of month and day of week are both restricted, either can match
+			expr: "0 0 13 * 5",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 0 29 2 *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			next: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 0 31 2 *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			next: time.Time{},
+		},
+		{
+			// 02:00 doesn't exist on the day clocks are moved forward, so it's skipped
+			expr: "0 2 * * *",
+			from: time.Date(2026, 3, 28, 12, 0, 0, 0, warsaw),
+			next: time.Date(2026, 3, 30, 2, 0, 0, 0, warsaw),
+		},
+		{
+			expr: "0 4 * * *",
+			from: time.Date(2026, 10, 24, 12, 0, 0, 0, warsaw),
+			next: time.Date(2026, 10, 25, 4, 0, 0, 0, warsaw),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			s, err := cron.Parse(tc.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned an error: %s", tc.expr, err)
+			}
+			if next := s.Next(tc.from); !next.Equal(tc.next) {
+				t.Errorf("Next(%s) returned %s, expected %s", tc.from, next, tc.next)
+			}
+		})
+	}
+}
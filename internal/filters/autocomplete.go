@@ -24,6 +24,7 @@ func BuildAutocomplete(alerts []models.Alert, dst map[string]models.Autocomplete
 	for _, filterConfig := range AllFilters {
 		if filterConfig.Autocomplete != nil {
 			filterConfig.Autocomplete(filterConfig.Label, filterConfig.SupportedOperators, alerts, dst)
+			negatedAutocomplete(filterConfig, alerts, dst)
 		}
 	}
 }
@@ -24,10 +24,18 @@ var acTests = []acTest{
 	{
 		Alerts: []models.Alert{},
 		Expected: []string{
+			"!@age\u003c10m",
+			"!@age\u003c1h",
+			"!@age\u003e10m",
+			"!@age\u003e1h",
+			"!@inhibited=false",
+			"!@inhibited=true",
 			"@age\u003e1h",
 			"@age\u003c10m",
 			"@age\u003c1h",
 			"@age\u003e10m",
+			"@flapping=false",
+			"@flapping=true",
 			"@inhibited=false",
 			"@inhibited=true",
 			"@limit=10",
@@ -37,9 +45,10 @@ var acTests = []acTest{
 	{
 		Alerts: []models.Alert{
 			{
-				State:    models.AlertStateActive,
-				Labels:   labels.FromStrings("foo", "bar", "number", "1"),
-				Receiver: "default",
+				State:     models.AlertStateActive,
+				Labels:    labels.FromStrings("foo", "bar", "number", "1"),
+				Receiver:  "default",
+				FlapScore: 3,
 				Alertmanager: []models.AlertmanagerInstance{
 					{Cluster: "cluster", Name: "am1"},
 					{Cluster: "cluster", Name: "am2"},
@@ -69,6 +78,12 @@ var acTests = []acTest{
 			},
 		},
 		Expected: []string{
+			"!@age\u003c10m",
+			"!@age\u003c1h",
+			"!@age\u003e10m",
+			"!@age\u003e1h",
+			"!@inhibited=false",
+			"!@inhibited=true",
 			"@age\u003c10m",
 			"@age\u003c1h",
 			"@age\u003e10m",
@@ -79,10 +94,22 @@ var acTests = []acTest{
 			"@alertmanager=am2",
 			"@cluster!=cluster",
 			"@cluster=cluster",
+			"@flapping=false",
+			"@flapping=true",
+			"@flapping\u003c3",
+			"@flapping\u003e3",
+			"@has!=foo",
+			"@has!=number",
+			"@has=foo",
+			"@has=number",
 			"@inhibited=false",
 			"@inhibited=true",
 			"@inhibited_by!=1234567890",
 			"@inhibited_by=1234567890",
+			"@label_name!=foo",
+			"@label_name!=number",
+			"@label_name=foo",
+			"@label_name=number",
 			"@limit=10",
 			"@limit=50",
 			"@receiver!=default",
@@ -20,6 +20,7 @@ type Filter interface {
 	MatcherOperation() string
 	Value() string
 	IsAlertmanagerFilter() bool
+	Error() string
 }
 
 // filterBase holds common state shared by all filter implementations.
@@ -30,6 +31,7 @@ type filterBase struct {
 	name                 string
 	rawText              string
 	value                string
+	err                  string
 	hits                 int
 	isValid              bool
 	isAlertmanagerFilter bool
@@ -42,26 +44,29 @@ func (f *filterBase) Name() string               { return f.name }
 func (f *filterBase) Value() string              { return f.value }
 func (f *filterBase) IsAlertmanagerFilter() bool { return f.isAlertmanagerFilter }
 func (f *filterBase) MatcherOperation() string   { return f.matcher.Operator }
+func (f *filterBase) Error() string              { return f.err }
 
 func (f *filterBase) Match(*models.Alert, int) bool                       { return false }
 func (f *filterBase) MatchAlertmanager(*models.AlertmanagerInstance) bool { return false }
 
+// newInvalidFilter returns a filter that will never match anything, with err
+// explaining why the expression couldn't be parsed.
+func newInvalidFilter(rawText, err string) Filter {
+	return &filterBase{rawText: rawText, err: err}
+}
+
 // buildMatcher creates a Matcher for the given operator and value.
 // For regex operators it compiles the pattern; for others it delegates to newMatcher.
-func buildMatcher(operator, value string) (Matcher, bool) {
+func buildMatcher(operator, value string) (Matcher, error) {
 	switch operator {
 	case regexpOperator, negativeRegexOperator:
 		m, err := newRegexpMatcher(operator, value)
 		if err != nil {
-			return Matcher{}, false
+			return Matcher{}, fmt.Errorf("invalid regular expression %q: %w", value, err)
 		}
-		return m, true
+		return m, nil
 	default:
-		m, err := newMatcher(operator)
-		if err != nil {
-			return Matcher{}, false
-		}
-		return m, true
+		return newMatcher(operator)
 	}
 }
 
@@ -72,7 +77,25 @@ func NewFilter(expression string) Filter {
 	trimmed := strings.Trim(expression, " \t")
 
 	if trimmed == "" {
-		return &filterBase{rawText: trimmed}
+		return newInvalidFilter(trimmed, "filter is empty")
+	}
+
+	if strings.HasPrefix(trimmed, "{") {
+		return newLabelNameSelectorFilter(trimmed)
+	}
+
+	// negation only applies to filters with a name and an operator, anything
+	// else is a fuzzy filter that happens to start with !
+	if inner, ok := strings.CutPrefix(trimmed, negationPrefix); ok && inner != "" {
+		switch f := NewFilter(inner).(type) {
+		case *fuzzyFilter:
+		case *negatedFilter:
+			return newInvalidFilter(trimmed, "filter can only be negated once")
+		case *limitFilter:
+			return newInvalidFilter(trimmed, "@limit filter can't be negated")
+		default:
+			return newNegatedFilter(trimmed, f)
+		}
 	}
 
 	reExp := fmt.Sprintf("^(?P<matched>(%s))(?P<operator>(%s))(?P<value>(.*))", filterRegex, matcherRegex)
@@ -94,7 +117,7 @@ func NewFilter(expression string) Filter {
 	}
 
 	if value == "" {
-		return &filterBase{rawText: trimmed}
+		return newInvalidFilter(trimmed, fmt.Sprintf("missing value after %q", matched+operator))
 	}
 
 	for _, fc := range AllFilters {
@@ -102,10 +125,16 @@ func NewFilter(expression string) Filter {
 			continue
 		}
 		if !slices.Contains(fc.SupportedOperators, operator) {
-			return &filterBase{rawText: trimmed}
+			if _, err := newMatcher(operator); err != nil {
+				return newInvalidFilter(trimmed, fmt.Sprintf("unknown operator %q", operator))
+			}
+			return newInvalidFilter(trimmed, fmt.Sprintf(
+				"operator %q is not supported by %s, supported operators: %s",
+				operator, matched, strings.Join(fc.SupportedOperators, " "),
+			))
 		}
 		return fc.Factory(matched, operator, trimmed, value)
 	}
 
-	return &filterBase{rawText: trimmed}
+	return newInvalidFilter(trimmed, fmt.Sprintf("unknown filter %q", matched))
 }
@@ -35,7 +35,7 @@ func (filter *ageFilter) MatchAlertmanager(am *models.AlertmanagerInstance) bool
 func newAgeFilter(name, operator, rawText, value string) Filter {
 	dur, err := time.ParseDuration(value)
 	if err != nil {
-		return &filterBase{rawText: rawText}
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid duration %q, expected a value like 10m or 1h", value))
 	}
 	if dur > 0 {
 		dur = -dur
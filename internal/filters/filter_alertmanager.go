@@ -28,9 +28,9 @@ func (filter *alertmanagerInstanceFilter) MatchAlertmanager(am *models.Alertmana
 }
 
 func newAlertmanagerInstanceFilter(name, operator, rawText, value string) Filter {
-	m, ok := buildMatcher(operator, value)
-	if !ok {
-		return &filterBase{rawText: rawText}
+	m, err := buildMatcher(operator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
 	}
 	return &alertmanagerInstanceFilter{
 		filterBase: filterBase{
@@ -28,9 +28,9 @@ func (filter *alertmanagerClusterFilter) MatchAlertmanager(am *models.Alertmanag
 }
 
 func newAlertmanagerClusterFilter(name, operator, rawText, value string) Filter {
-	m, ok := buildMatcher(operator, value)
-	if !ok {
-		return &filterBase{rawText: rawText}
+	m, err := buildMatcher(operator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
 	}
 	return &alertmanagerClusterFilter{
 		filterBase: filterBase{
@@ -0,0 +1,75 @@
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+// flappingFilter matches alerts based on their flap score, value can be
+// true or false, to match alerts above flapping.threshold, or a number to
+// compare the score directly
+type flappingFilter struct {
+	filterBase
+	isBool bool
+}
+
+func (filter *flappingFilter) Match(alert *models.Alert, _ int) bool {
+	var isMatch bool
+	if filter.isBool {
+		isMatch = filter.matcher.Compare(strconv.FormatBool(alert.IsFlapping()), filter.value)
+	} else {
+		isMatch = filter.matcher.Compare(strconv.Itoa(alert.FlapScore), filter.value)
+	}
+	if isMatch {
+		filter.hits++
+	}
+	return isMatch
+}
+
+func newFlappingFilter(name, operator, rawText, value string) Filter {
+	isBool := value == trueValue || value == falseValue
+	switch {
+	case isBool && (operator == moreThanOperator || operator == lessThanOperator):
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid value %q, expected a number", value))
+	case !isBool && !isDigits(value):
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid value %q, expected %s, %s or a number", value, trueValue, falseValue))
+	}
+	// operator is pre-validated by the registry, buildMatcher cannot fail here
+	m, _ := buildMatcher(operator, value)
+	return &flappingFilter{
+		filterBase: filterBase{
+			matcher: m,
+			name:    name,
+			rawText: rawText,
+			value:   value,
+			isValid: true,
+		},
+		isBool: isBool,
+	}
+}
+
+func flappingAutocomplete(name string, _ []string, alerts []models.Alert, dst map[string]models.Autocomplete) {
+	for _, val := range []string{trueValue, falseValue} {
+		setAC(dst, name+equalOperator+val, []string{
+			name,
+			strings.TrimPrefix(name, "@"),
+			name + equalOperator,
+			val,
+		})
+	}
+	for _, alert := range alerts {
+		if alert.FlapScore == 0 {
+			continue
+		}
+		for _, operator := range []string{moreThanOperator, lessThanOperator} {
+			setAC(dst, name+operator+strconv.Itoa(alert.FlapScore), []string{
+				name,
+				strings.TrimPrefix(name, "@"),
+				name + operator,
+			})
+		}
+	}
+}
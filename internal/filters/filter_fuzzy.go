@@ -55,7 +55,7 @@ func (filter *fuzzyFilter) Match(alert *models.Alert, _ int) bool {
 func newFuzzyFilter(rawText string) Filter {
 	re, err := regexp.Compile("(?i)" + rawText)
 	if err != nil {
-		return &filterBase{rawText: rawText}
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid regular expression %q: %s", rawText, err))
 	}
 	return &fuzzyFilter{
 		filterBase: filterBase{
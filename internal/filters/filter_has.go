@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+// hasFilter matches alerts that have (=) or don't have (!=) a label or an
+// annotation with given name.
+type hasFilter struct {
+	filterBase
+}
+
+func (filter *hasFilter) Match(alert *models.Alert, _ int) bool {
+	found := alert.Labels.Has(filter.value)
+	if !found {
+		for _, a := range alert.Annotations {
+			if a.Name == filter.value {
+				found = true
+				break
+			}
+		}
+	}
+
+	isMatch := found
+	if filter.matcher.Operator == notEqualOperator {
+		isMatch = !found
+	}
+	if isMatch {
+		filter.hits++
+	}
+	return isMatch
+}
+
+func newHasFilter(name, operator, rawText, value string) Filter {
+	// operator is pre-validated by the registry, buildMatcher cannot fail here
+	m, _ := buildMatcher(operator, value)
+	return &hasFilter{
+		filterBase: filterBase{
+			matcher: m,
+			name:    name,
+			rawText: rawText,
+			value:   value,
+			isValid: true,
+		},
+	}
+}
+
+func hasAutocomplete(name string, operators []string, alerts []models.Alert, dst map[string]models.Autocomplete) {
+	names := map[string]struct{}{}
+	for _, alert := range alerts {
+		alert.Labels.Range(func(l labels.Label) {
+			names[l.Name] = struct{}{}
+		})
+		for _, a := range alert.Annotations {
+			names[a.Name] = struct{}{}
+		}
+	}
+	for n := range names {
+		for _, operator := range operators {
+			setAC(dst, name+operator+n, []string{
+				name,
+				name + operator,
+			})
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -45,7 +46,7 @@ func newInhibitedFilter(name, operator, rawText, value string) Filter {
 	case falseValue:
 		bv = false
 	default:
-		return &filterBase{rawText: rawText}
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid value %q, expected %s or %s", value, trueValue, falseValue))
 	}
 	// operator is pre-validated by the registry, buildMatcher cannot fail here
 	m, _ := buildMatcher(operator, value)
@@ -37,9 +37,9 @@ func (filter *labelFilter) Match(alert *models.Alert, _ int) bool {
 }
 
 func newLabelFilter(name, operator, rawText, value string) Filter {
-	m, ok := buildMatcher(operator, value)
-	if !ok {
-		return &filterBase{rawText: rawText}
+	m, err := buildMatcher(operator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
 	}
 	return &labelFilter{
 		filterBase: filterBase{
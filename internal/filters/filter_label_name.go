@@ -0,0 +1,155 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+// labelNameSelectorRegex matches label name selectors like {__name__=~"team_.*"}
+// that can be used instead of a label name to match all labels with names
+// passing the selector
+var labelNameSelectorRegex = regexp.MustCompile(`^\{__name__(=~|!~|=|!=)("(?:[^"\\]|\\.)*")\}`)
+
+var labelNameSelectorOperatorRegex = regexp.MustCompile("^(" + matcherRegex + ")")
+
+// labelNameFilter matches alerts based on label names, ignoring values.
+// Positive operators will match alerts with at least one label name passing
+// the matcher, negative operators will match alerts without any such label.
+type labelNameFilter struct {
+	filterBase
+	nameMatcher Matcher
+	negate      bool
+}
+
+func (filter *labelNameFilter) Match(alert *models.Alert, _ int) bool {
+	var hasLabel bool
+	alert.Labels.Range(func(l labels.Label) {
+		if filter.nameMatcher.Compare(l.Name, filter.value) {
+			hasLabel = true
+		}
+	})
+	isMatch := hasLabel != filter.negate
+	if isMatch {
+		filter.hits++
+	}
+	return isMatch
+}
+
+func newLabelNameFilter(name, operator, rawText, value string) Filter {
+	var negate bool
+	nameOperator := operator
+	switch operator {
+	case notEqualOperator:
+		negate, nameOperator = true, equalOperator
+	case negativeRegexOperator:
+		negate, nameOperator = true, regexpOperator
+	}
+
+	nm, err := buildMatcher(nameOperator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
+	}
+	// operator is pre-validated by the registry, buildMatcher cannot fail here
+	m, _ := buildMatcher(operator, value)
+	return &labelNameFilter{
+		filterBase: filterBase{
+			matcher: m,
+			name:    name,
+			rawText: rawText,
+			value:   value,
+			isValid: true,
+		},
+		nameMatcher: nm,
+		negate:      negate,
+	}
+}
+
+func labelNameAutocomplete(name string, operators []string, alerts []models.Alert, dst map[string]models.Autocomplete) {
+	labelNames := map[string]struct{}{}
+	for _, alert := range alerts {
+		alert.Labels.Range(func(l labels.Label) {
+			labelNames[l.Name] = struct{}{}
+		})
+	}
+	for labelName := range labelNames {
+		for _, operator := range operators {
+			switch operator {
+			case equalOperator, notEqualOperator:
+				setAC(dst, name+operator+labelName, []string{
+					name,
+					name + operator,
+				})
+			}
+		}
+	}
+}
+
+// labelNameSelectorFilter matches alerts with at least one label that has
+// a name passing the name selector and a value passing the value matcher.
+type labelNameSelectorFilter struct {
+	filterBase
+	nameMatcher Matcher
+	namePattern string
+}
+
+func (filter *labelNameSelectorFilter) Match(alert *models.Alert, _ int) bool {
+	var isMatch bool
+	alert.Labels.Range(func(l labels.Label) {
+		if filter.nameMatcher.Compare(l.Name, filter.namePattern) && filter.matcher.Compare(l.Value, filter.value) {
+			isMatch = true
+		}
+	})
+	if isMatch {
+		filter.hits++
+	}
+	return isMatch
+}
+
+// newLabelNameSelectorFilter parses expressions like {__name__=~"team_.*"}="db"
+func newLabelNameSelectorFilter(rawText string) Filter {
+	selector := labelNameSelectorRegex.FindStringSubmatch(rawText)
+	if selector == nil {
+		return newInvalidFilter(rawText, `invalid label name selector, expected {__name__=~"pattern"}`)
+	}
+	nameOperator := selector[1]
+	namePattern, err := strconv.Unquote(selector[2])
+	if err != nil {
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid label name selector value %s: %s", selector[2], err))
+	}
+	nm, err := buildMatcher(nameOperator, namePattern)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
+	}
+
+	name := selector[0]
+	rest := rawText[len(name):]
+	op := labelNameSelectorOperatorRegex.FindString(rest)
+	value := rest[len(op):]
+	if op == "" || value == "" {
+		return newInvalidFilter(rawText, fmt.Sprintf("missing value after %q", name+op))
+	}
+	if _, err = newMatcher(op); err != nil {
+		return newInvalidFilter(rawText, fmt.Sprintf("unknown operator %q", op))
+	}
+	m, err := buildMatcher(op, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
+	}
+
+	return &labelNameSelectorFilter{
+		filterBase: filterBase{
+			matcher: m,
+			name:    name,
+			rawText: rawText,
+			value:   value,
+			isValid: true,
+		},
+		nameMatcher: nm,
+		namePattern: namePattern,
+	}
+}
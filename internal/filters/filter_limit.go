@@ -28,7 +28,7 @@ func (filter *limitFilter) Match(_ *models.Alert, matches int) bool {
 func newLimitFilter(name, operator, rawText, value string) Filter {
 	val, err := strconv.Atoi(value)
 	if err != nil || val < 1 {
-		return &filterBase{rawText: rawText}
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid value %q, expected a positive number", value))
 	}
 	// operator is pre-validated by the registry, buildMatcher cannot fail here
 	m, _ := buildMatcher(operator, value)
@@ -0,0 +1,64 @@
+package filters
+
+import (
+	"slices"
+
+	"github.com/prymitive/karma/internal/models"
+)
+
+const negationPrefix = "!"
+
+// negatedFilter wraps any other filter and inverts its result, it's created
+// for every filter expression prefixed with !, like !@inhibited=true
+type negatedFilter struct {
+	filter  Filter
+	rawText string
+	hits    int
+}
+
+func (f *negatedFilter) Match(alert *models.Alert, matches int) bool {
+	if !f.filter.Valid() {
+		return false
+	}
+	isMatch := !f.filter.Match(alert, matches)
+	if isMatch {
+		f.hits++
+	}
+	return isMatch
+}
+
+func (f *negatedFilter) MatchAlertmanager(am *models.AlertmanagerInstance) bool {
+	return f.filter.Valid() && !f.filter.MatchAlertmanager(am)
+}
+
+func (f *negatedFilter) RawText() string            { return f.rawText }
+func (f *negatedFilter) Hits() int                  { return f.hits }
+func (f *negatedFilter) Valid() bool                { return f.filter.Valid() }
+func (f *negatedFilter) Name() string               { return negationPrefix + f.filter.Name() }
+func (f *negatedFilter) MatcherOperation() string   { return f.filter.MatcherOperation() }
+func (f *negatedFilter) Value() string              { return f.filter.Value() }
+func (f *negatedFilter) IsAlertmanagerFilter() bool { return f.filter.IsAlertmanagerFilter() }
+func (f *negatedFilter) Error() string              { return f.filter.Error() }
+
+func newNegatedFilter(rawText string, filter Filter) Filter {
+	return &negatedFilter{filter: filter, rawText: rawText}
+}
+
+// negatedAutocomplete adds negated variants of all hints for filters that
+// don't support != operator and so have no other way of being inverted.
+// @limit is skipped since it doesn't filter alerts, only truncates the list.
+func negatedAutocomplete(fc filterConfig, alerts []models.Alert, dst map[string]models.Autocomplete) {
+	if slices.Contains(fc.SupportedOperators, notEqualOperator) || fc.Label == "@limit" {
+		return
+	}
+
+	hints := map[string]models.Autocomplete{}
+	fc.Autocomplete(fc.Label, fc.SupportedOperators, alerts, hints)
+	for _, hint := range hints {
+		tokens := make([]string, 0, len(hint.Tokens)-1)
+		for _, token := range hint.Tokens[:len(hint.Tokens)-1] {
+			tokens = append(tokens, negationPrefix+token)
+		}
+		setAC(dst, negationPrefix+hint.Value, tokens)
+	}
+}
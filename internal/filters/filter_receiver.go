@@ -19,9 +19,9 @@ func (filter *receiverFilter) Match(alert *models.Alert, _ int) bool {
 }
 
 func newReceiverFilter(name, operator, rawText, value string) Filter {
-	m, ok := buildMatcher(operator, value)
-	if !ok {
-		return &filterBase{rawText: rawText}
+	m, err := buildMatcher(operator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
 	}
 	return &receiverFilter{
 		filterBase: filterBase{
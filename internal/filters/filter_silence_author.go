@@ -41,9 +41,9 @@ func (filter *silenceAuthorFilter) MatchAlertmanager(am *models.AlertmanagerInst
 }
 
 func newSilenceAuthorFilter(name, operator, rawText, value string) Filter {
-	m, ok := buildMatcher(operator, value)
-	if !ok {
-		return &filterBase{rawText: rawText}
+	m, err := buildMatcher(operator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
 	}
 	return &silenceAuthorFilter{
 		filterBase: filterBase{
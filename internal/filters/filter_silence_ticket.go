@@ -41,9 +41,9 @@ func (filter *silenceTicketFilter) MatchAlertmanager(am *models.AlertmanagerInst
 }
 
 func newSilenceTicketFilter(name, operator, rawText, value string) Filter {
-	m, ok := buildMatcher(operator, value)
-	if !ok {
-		return &filterBase{rawText: rawText}
+	m, err := buildMatcher(operator, value)
+	if err != nil {
+		return newInvalidFilter(rawText, err.Error())
 	}
 	return &silenceTicketFilter{
 		filterBase: filterBase{
@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/prymitive/karma/internal/models"
@@ -29,7 +30,11 @@ func (filter *stateFilter) MatchAlertmanager(am *models.AlertmanagerInstance) bo
 
 func newStateFilter(name, operator, rawText, value string) Filter {
 	if _, ok := models.AlertStateFromString(value); !ok {
-		return &filterBase{rawText: rawText}
+		states := make([]string, 0, len(models.AlertStateList))
+		for _, state := range models.AlertStateList {
+			states = append(states, state.String())
+		}
+		return newInvalidFilter(rawText, fmt.Sprintf("invalid alert state %q, expected one of: %s", value, strings.Join(states, " ")))
 	}
 	// operator is pre-validated by the registry, buildMatcher cannot fail here
 	m, _ := buildMatcher(operator, value)
@@ -10,6 +10,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/prymitive/karma/internal/alertmanager"
+	"github.com/prymitive/karma/internal/config"
 	"github.com/prymitive/karma/internal/filters"
 	"github.com/prymitive/karma/internal/models"
 )
@@ -1053,6 +1054,244 @@ var tests = []filterTest{
 		Expression: "node=~[",
 		IsValid:    false,
 	},
+
+	{
+		Expression: "@label_name=team_owner",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_owner", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "@label_name=team_owner",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_secondary", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: "@label_name!=team_owner",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_owner", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: "@label_name!=team_owner",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_secondary", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "@label_name=~^team_.*",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo", "team_secondary", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "@label_name=~^team_.*",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo", "team", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: "@label_name!~^team_.*",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo", "team_owner", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: "@label_name!~^team_.*",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "@label_name!~[",
+		IsValid:    false,
+	},
+	{
+		Expression: "@label_name>foo",
+		IsValid:    false,
+	},
+
+	{
+		Expression: "!@inhibited=true",
+		IsValid:    true,
+		Alert: models.Alert{
+			State: models.AlertStateSuppressed,
+		},
+		IsMatch:             true,
+		IsAlertmanagerMatch: true,
+	},
+	{
+		Expression: "!@inhibited=true",
+		IsValid:    true,
+		Alert: models.Alert{
+			State:       models.AlertStateSuppressed,
+			InhibitedBy: []string{"1234567890"},
+		},
+		IsMatch: false,
+	},
+	{
+		Expression: "!@silenced_by=abcdef",
+		IsValid:    true,
+		Alert: models.Alert{
+			State:      models.AlertStateSuppressed,
+			SilencedBy: []string{"abcdef"},
+		},
+		Silence: models.Silence{ID: "abcdef"},
+		IsMatch: false,
+	},
+	{
+		Expression: "!@silenced_by=abcdef",
+		IsValid:    true,
+		Alert: models.Alert{
+			State:      models.AlertStateSuppressed,
+			SilencedBy: []string{"1"},
+		},
+		IsMatch:             true,
+		IsAlertmanagerMatch: true,
+	},
+	{
+		Expression:          "!@age<1h",
+		IsValid:             true,
+		Alert:               models.Alert{StartsAt: time.Now().Add(time.Hour * -2)},
+		IsMatch:             true,
+		IsAlertmanagerMatch: true,
+	},
+	{
+		Expression: "!@age<1h",
+		IsValid:    true,
+		Alert:      models.Alert{StartsAt: time.Now().Add(time.Minute * -55)},
+		IsMatch:    false,
+	},
+	{
+		Expression: "!node=vps1",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("node", "vps2")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "!node=vps1",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("node", "vps1")},
+		IsMatch:    false,
+	},
+	{
+		Expression: "!@state=foo",
+		IsValid:    false,
+	},
+	{
+		Expression: "!node=",
+		IsValid:    false,
+	},
+	{
+		Expression: "!vps1",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("node", "!vps1")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "!",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("node", "vps1")},
+		IsMatch:    false,
+	},
+
+	{
+		Expression: "@has=team",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: "@has=team",
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_owner", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: "@has=runbook_url",
+		IsValid:    true,
+		Alert: models.Alert{
+			Labels:      labels.FromStrings("team", "db"),
+			Annotations: models.Annotations{{Name: "runbook_url", Value: "http://localhost"}},
+		},
+		IsMatch: true,
+	},
+	{
+		Expression: "@has!=runbook_url",
+		IsValid:    true,
+		Alert: models.Alert{
+			Labels:      labels.FromStrings("team", "db"),
+			Annotations: models.Annotations{{Name: "runbook_url", Value: "http://localhost"}},
+		},
+		IsMatch: false,
+	},
+	{
+		Expression: "@has!=runbook_url",
+		IsValid:    true,
+		Alert: models.Alert{
+			Labels:      labels.FromStrings("team", "db"),
+			Annotations: models.Annotations{{Name: "summary", Value: "foo"}},
+		},
+		IsMatch: true,
+	},
+	{
+		Expression: "@has=~team",
+		IsValid:    false,
+	},
+
+	{
+		Expression: `{__name__=~"team_.*"}=db`,
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo", "team_secondary", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: `{__name__=~"team_.*"}="db"`,
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo", "team_secondary", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: `{__name__=~"team_.*"}=db`,
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("alertname", "foo", "team_owner", "web", "team", "db")},
+		IsMatch:    false,
+	},
+	{
+		Expression: `{__name__=~"team_.*"}=~^d`,
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_owner", "web", "team_secondary", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: `{__name__!~"team_.*"}=db`,
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_owner", "db", "service", "db")},
+		IsMatch:    true,
+	},
+	{
+		Expression: `{__name__="team_owner"}!=db`,
+		IsValid:    true,
+		Alert:      models.Alert{Labels: labels.FromStrings("team_owner", "web")},
+		IsMatch:    true,
+	},
+	{
+		Expression: `{__name__=~"team_.*"}`,
+		IsValid:    false,
+	},
+	{
+		Expression: `{__name__=~"["}=db`,
+		IsValid:    false,
+	},
+	{
+		Expression: `{__name__=~"team_.*"}==db`,
+		IsValid:    false,
+	},
+	{
+		Expression: `{team=~"team_.*"}=db`,
+		IsValid:    false,
+	},
 }
 
 func TestFilters(t *testing.T) {
@@ -1092,10 +1331,13 @@ func TestFilters(t *testing.T) {
 			if f.Valid() != ft.IsValid {
 				t.Errorf("[%s] Valid() returned %#v while %#v was expected", ft.Expression, f.Valid(), ft.IsValid)
 			}
+			if f.Valid() != (f.Error() == "") {
+				t.Errorf("[%s] Valid() returned %#v while Error() returned %q", ft.Expression, f.Valid(), f.Error())
+			}
 			if f.Valid() {
 				isAlertmanagerFilter := slices.Contains(
 					[]string{"@age", "@alertmanager", "@cluster", "@inhibited", "@inhibited_by", "@state", "@silenced_by", "@silence_ticket", "@silence_author", "@fingerprint"},
-					f.Name(),
+					strings.TrimPrefix(f.Name(), "!"),
 				)
 				if isAlertmanagerFilter != f.IsAlertmanagerFilter() {
 					t.Errorf("[%s] IsAlertmanagerFilter() returned %#v while %#v was expected", ft.Expression, f.IsAlertmanagerFilter(), isAlertmanagerFilter)
@@ -1191,6 +1433,91 @@ var limitTests = []limitFilterTest{
 	},
 }
 
+func TestFilterErrors(t *testing.T) {
+	type testCaseT struct {
+		expression string
+		err        string
+	}
+
+	testCases := []testCaseT{
+		{expression: "", err: "filter is empty"},
+		{expression: "foo=", err: `missing value after "foo="`},
+		{expression: "foo===bar", err: `unknown operator "==="`},
+		{expression: "@state=~active", err: `operator "=~" is not supported by @state, supported operators: = !=`},
+		{expression: "@foo=bar", err: `unknown filter "@foo"`},
+		{expression: "foo=~[", err: "invalid regular expression \"[\": error parsing regexp: missing closing ]: `[`"},
+		{expression: "[", err: "invalid regular expression \"[\": error parsing regexp: missing closing ]: `[`"},
+		{expression: "@state=foo", err: `invalid alert state "foo", expected one of: unprocessed active suppressed`},
+		{expression: "@inhibited=foo", err: `invalid value "foo", expected true or false`},
+		{expression: "@limit=-1", err: `invalid value "-1", expected a positive number`},
+		{expression: "@age>foo", err: `invalid duration "foo", expected a value like 10m or 1h`},
+		{expression: "@flapping=foo", err: `invalid value "foo", expected true, false or a number`},
+		{expression: "@flapping>true", err: `invalid value "true", expected a number`},
+		{expression: "@flapping=~true", err: `operator "=~" is not supported by @flapping, supported operators: = != < >`},
+		{expression: `{__name__=~"team_.*"}`, err: `missing value after "{__name__=~\"team_.*\"}"`},
+		{expression: `{foo="bar"}=db`, err: `invalid label name selector, expected {__name__=~"pattern"}`},
+		{expression: "!@state=foo", err: `invalid alert state "foo", expected one of: unprocessed active suppressed`},
+		{expression: "!@limit=10", err: "@limit filter can't be negated"},
+		{expression: "!!node=vps1", err: "filter can only be negated once"},
+		{expression: "foo=bar", err: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			f := filters.NewFilter(tc.expression)
+			if f.Error() != tc.err {
+				t.Errorf("Error() returned %q while %q was expected", f.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestNegatedFilterName(t *testing.T) {
+	f := filters.NewFilter("!@state=active")
+	if f.Name() != "!@state" || f.MatcherOperation() != "=" || f.Value() != "active" {
+		t.Errorf("Negated filter returned name=%q operator=%q value=%q", f.Name(), f.MatcherOperation(), f.Value())
+	}
+}
+
+func TestFlappingFilter(t *testing.T) {
+	defer func() { config.Config.Flapping.Threshold = 0 }()
+	config.Config.Flapping.Threshold = 4
+
+	type testCaseT struct {
+		expression string
+		score      int
+		isMatch    bool
+	}
+
+	testCases := []testCaseT{
+		{expression: "@flapping=true", score: 0, isMatch: false},
+		{expression: "@flapping=true", score: 3, isMatch: false},
+		{expression: "@flapping=true", score: 4, isMatch: true},
+		{expression: "@flapping=false", score: 3, isMatch: true},
+		{expression: "@flapping!=true", score: 5, isMatch: false},
+		{expression: "@flapping=5", score: 5, isMatch: true},
+		{expression: "@flapping!=5", score: 5, isMatch: false},
+		{expression: "@flapping>5", score: 5, isMatch: false},
+		{expression: "@flapping>5", score: 6, isMatch: true},
+		{expression: "@flapping<2", score: 1, isMatch: true},
+		{expression: "@flapping<2", score: 2, isMatch: false},
+		{expression: "!@flapping=true", score: 10, isMatch: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			f := filters.NewFilter(tc.expression)
+			if !f.Valid() {
+				t.Fatalf("[%s] Valid() returned false: %s", tc.expression, f.Error())
+			}
+			alert := models.Alert{FlapScore: tc.score}
+			if isMatch := f.Match(&alert, 0); isMatch != tc.isMatch {
+				t.Errorf("[%s] Match() for score=%d returned %v, expected %v", tc.expression, tc.score, isMatch, tc.isMatch)
+			}
+		})
+	}
+}
+
 func TestLimitFilter(t *testing.T) {
 	for _, ft := range limitTests {
 		f := filters.NewFilter(ft.Expression)
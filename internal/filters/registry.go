@@ -89,6 +89,13 @@ var AllFilters = []filterConfig{
 		Factory:            newAgeFilter,
 		Autocomplete:       ageAutocomplete,
 	},
+	{
+		Label:              "@flapping",
+		LabelRe:            regexp.MustCompile("^@flapping$"),
+		SupportedOperators: []string{equalOperator, notEqualOperator, lessThanOperator, moreThanOperator},
+		Factory:            newFlappingFilter,
+		Autocomplete:       flappingAutocomplete,
+	},
 	{
 		Label:              "@silenced_by",
 		LabelRe:            regexp.MustCompile("^@silenced_by$"),
@@ -110,6 +117,13 @@ var AllFilters = []filterConfig{
 		Factory:            newSilenceAuthorFilter,
 		Autocomplete:       silenceAuthorAutocomplete,
 	},
+	{
+		Label:              "@has",
+		LabelRe:            regexp.MustCompile("^@has$"),
+		SupportedOperators: []string{equalOperator, notEqualOperator},
+		Factory:            newHasFilter,
+		Autocomplete:       hasAutocomplete,
+	},
 	{
 		Label:              "@limit",
 		LabelRe:            regexp.MustCompile("^@limit$"),
@@ -117,6 +131,13 @@ var AllFilters = []filterConfig{
 		Factory:            newLimitFilter,
 		Autocomplete:       limitAutocomplete,
 	},
+	{
+		Label:              "@label_name",
+		LabelRe:            regexp.MustCompile("^@label_name$"),
+		SupportedOperators: []string{regexpOperator, negativeRegexOperator, equalOperator, notEqualOperator},
+		Factory:            newLabelNameFilter,
+		Autocomplete:       labelNameAutocomplete,
+	},
 	{
 		Label:              "[a-zA-Z_][a-zA-Z0-9_]*",
 		LabelRe:            regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$"),
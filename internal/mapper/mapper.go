@@ -29,6 +29,8 @@ type AlertMapper interface {
 type SilenceMapper interface {
 	Mapper
 	Collect(string, map[string]string, time.Duration, http.RoundTripper) ([]models.Silence, error)
+	Create(string, map[string]string, time.Duration, http.RoundTripper, models.Silence) (string, error)
+	Expire(string, map[string]string, time.Duration, http.RoundTripper, string) error
 	RewriteUsername([]byte, string) ([]byte, error)
 	Unmarshal([]byte) (*models.Silence, error)
 }
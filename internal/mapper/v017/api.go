@@ -1,6 +1,7 @@
 package v017
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"net/url"
 	"path"
 	"sort"
+	"strings"
 	"time"
 
 	json "github.com/go-json-experiment/json"
@@ -168,15 +170,26 @@ func newHTTPClient(uri string, headers map[string]string, httpTransport http.Rou
 	return &http.Client{Transport: transport}, u
 }
 
+// maxErrorBodySize limits how much of the response body is included in errors
+// returned for failed requests
+const maxErrorBodySize = 512
+
 func apiGet(ctx context.Context, client *http.Client, baseURL *url.URL, apiPath string) (io.ReadCloser, error) {
+	return apiRequest(ctx, client, baseURL, http.MethodGet, apiPath, nil)
+}
+
+func apiRequest(ctx context.Context, client *http.Client, baseURL *url.URL, method, apiPath string, body io.Reader) (io.ReadCloser, error) {
 	u := *baseURL
 	u.Path = path.Join(u.Path, "api/v2", apiPath)
 	u.User = nil
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request for %s: %w", u.String(), err)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -184,7 +197,12 @@ func apiGet(ctx context.Context, client *http.Client, baseURL *url.URL, apiPath
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
+		defer resp.Body.Close()
+		// Alertmanager explains why a request was rejected in the response body
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		if text := strings.TrimSpace(string(msg)); text != "" {
+			return nil, fmt.Errorf("request to %s returned status %d: %s", u.String(), resp.StatusCode, text)
+		}
 		return nil, fmt.Errorf("request to %s returned status %d", u.String(), resp.StatusCode)
 	}
 
@@ -275,6 +293,62 @@ func silences(client *http.Client, baseURL *url.URL, timeout time.Duration) ([]m
 	return ret, nil
 }
 
+type postSilenceResponse struct {
+	SilenceID string `json:"silenceID"`
+}
+
+func createSilence(client *http.Client, baseURL *url.URL, timeout time.Duration, us models.Silence) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s := silence{
+		ID:        us.ID,
+		StartsAt:  dateTime(us.StartsAt),
+		EndsAt:    dateTime(us.EndsAt),
+		CreatedBy: us.CreatedBy,
+		Comment:   us.Comment,
+		Matchers:  make([]matcher, 0, len(us.Matchers)),
+	}
+	for _, m := range us.Matchers {
+		isEqual := m.IsEqual
+		s.Matchers = append(s.Matchers, matcher{
+			Name:    m.Name,
+			Value:   m.Value,
+			IsRegex: m.IsRegex,
+			IsEqual: &isEqual,
+		})
+	}
+
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := apiRequest(ctx, client, baseURL, http.MethodPost, "silences", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var resp postSilenceResponse
+	if err := json.UnmarshalRead(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to decode silence response: %w", err)
+	}
+
+	return resp.SilenceID, nil
+}
+
+func expireSilence(client *http.Client, baseURL *url.URL, timeout time.Duration, silenceID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, err := apiRequest(ctx, client, baseURL, http.MethodDelete, path.Join("silence", url.PathEscape(silenceID)), nil)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
 func rewriteSilenceUsername(body []byte, username string) ([]byte, error) {
 	var s silence
 	if err := json.Unmarshal(body, &s); err != nil {
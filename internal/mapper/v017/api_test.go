@@ -6,11 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	json "github.com/go-json-experiment/json"
 	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/prymitive/karma/internal/models"
 )
 
 func TestDateTimeUnmarshalJSONFromValid(t *testing.T) {
@@ -378,3 +381,121 @@ func TestSilencesApiGetError(t *testing.T) {
 		t.Errorf("expected empty result, got %d silences", len(result))
 	}
 }
+
+func TestCreateSilenceValid(t *testing.T) {
+	// verifies that silence is sent as a POST request with all matchers and
+	// silence ID from the response is returned
+	var method, path string
+	var sent map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		_ = json.UnmarshalRead(r.Body, &sent)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"silenceID":"silence-1"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	id, err := createSilence(srv.Client(), u, 5*time.Second, models.Silence{
+		StartsAt:  time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC),
+		EndsAt:    time.Date(2025, 3, 11, 12, 0, 0, 0, time.UTC),
+		CreatedBy: "user@example.com",
+		Comment:   "test silence",
+		Matchers: []models.SilenceMatcher{
+			models.NewSilenceMatcher("alertname", "TestAlert", false, true),
+			models.NewSilenceMatcher("env", "prod", true, false),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "silence-1" {
+		t.Errorf("ID = %q, want %q", id, "silence-1")
+	}
+	if method != http.MethodPost || path != "/api/v2/silences" {
+		t.Errorf("got %s %s, want POST /api/v2/silences", method, path)
+	}
+	if sent["endsAt"] != "2025-03-11T12:00:00.000Z" {
+		t.Errorf("endsAt = %v, want 2025-03-11T12:00:00.000Z", sent["endsAt"])
+	}
+	if _, ok := sent["id"]; ok {
+		t.Errorf("id was sent for a new silence: %v", sent["id"])
+	}
+	matchers, _ := sent["matchers"].([]any)
+	if len(matchers) != 2 {
+		t.Fatalf("got %d matchers, want 2", len(matchers))
+	}
+	if m, _ := matchers[1].(map[string]any); m["isEqual"] != false || m["isRegex"] != true {
+		t.Errorf("wrong matcher sent: %v", m)
+	}
+}
+
+func TestCreateSilenceError(t *testing.T) {
+	// verifies that a non-200 response and invalid response body both return an error
+	for _, body := range []string{"", "not json"} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if body == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(body))
+		}))
+
+		u, _ := url.Parse(srv.URL)
+		_, err := createSilence(srv.Client(), u, 5*time.Second, models.Silence{})
+		if err == nil {
+			t.Errorf("expected error for response body %q, got nil", body)
+		}
+		srv.Close()
+	}
+}
+
+func TestExpireSilence(t *testing.T) {
+	// verifies that silence is expired via a DELETE request and that non-200
+	// responses are returned as errors
+	for _, code := range []int{http.StatusOK, http.StatusNotFound} {
+		var method, path string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			w.WriteHeader(code)
+		}))
+
+		u, _ := url.Parse(srv.URL)
+		err := expireSilence(srv.Client(), u, 5*time.Second, "silence-1")
+		if code == http.StatusOK && err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if code != http.StatusOK && err == nil {
+			t.Errorf("expected error for status %d, got nil", code)
+		}
+		if method != http.MethodDelete || path != "/api/v2/silence/silence-1" {
+			t.Errorf("got %s %s, want DELETE /api/v2/silence/silence-1", method, path)
+		}
+		srv.Close()
+	}
+}
+
+func TestApiRequestErrorBody(t *testing.T) {
+	// verifies that the response body is included in errors, truncated if too long
+	for _, tc := range []struct {
+		body string
+		err  string
+	}{
+		{body: "", err: "returned status 400"},
+		{body: "silence invalid: start time must be before end time\n", err: "returned status 400: silence invalid: start time must be before end time"},
+		{body: strings.Repeat("x", maxErrorBodySize*2), err: "returned status 400: " + strings.Repeat("x", maxErrorBodySize)},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(tc.body))
+		}))
+
+		u, _ := url.Parse(srv.URL)
+		_, err := apiRequest(context.Background(), srv.Client(), u, http.MethodPost, "silences", strings.NewReader("{}"))
+		if err == nil || !strings.HasSuffix(err.Error(), tc.err) {
+			t.Errorf("apiRequest() returned %v, expected error ending with %q", err, tc.err)
+		}
+		srv.Close()
+	}
+}
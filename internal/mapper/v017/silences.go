@@ -27,6 +27,16 @@ func (m SilenceMapper) Collect(uri string, headers map[string]string, timeout ti
 	return silences(c, u, timeout)
 }
 
+func (m SilenceMapper) Create(uri string, headers map[string]string, timeout time.Duration, httpTransport http.RoundTripper, silence models.Silence) (string, error) {
+	c, u := newHTTPClient(uri, headers, httpTransport)
+	return createSilence(c, u, timeout, silence)
+}
+
+func (m SilenceMapper) Expire(uri string, headers map[string]string, timeout time.Duration, httpTransport http.RoundTripper, silenceID string) error {
+	c, u := newHTTPClient(uri, headers, httpTransport)
+	return expireSilence(c, u, timeout, silenceID)
+}
+
 func (m SilenceMapper) RewriteUsername(body []byte, username string) ([]byte, error) {
 	return rewriteSilenceUsername(body, username)
 }
@@ -205,6 +205,14 @@ type Alert struct {
 	InhibitedBy  []string               `json:"-"`
 	Alertmanager []AlertmanagerInstance `json:"alertmanager"`
 	State        AlertState             `json:"state"`
+	// FlapScore is the number of state changes of this alert seen in the
+	// last flapping.window
+	FlapScore int `json:"flapScore"`
+}
+
+// IsFlapping returns true if alert flap score reached flapping.threshold
+func (a *Alert) IsFlapping() bool {
+	return a.FlapScore >= config.Config.Flapping.Threshold
 }
 
 // APIAlert is the JSON-serializable representation of Alert.
@@ -217,6 +225,7 @@ type APIAlert struct {
 	Annotations  Annotations            `json:"annotations"`
 	Labels       OrderedLabels          `json:"labels"`
 	Alertmanager []AlertmanagerInstance `json:"alertmanager"`
+	FlapScore    int                    `json:"flapScore"`
 }
 
 func (a APIAlert) MarshalJSONTo(enc *jsontext.Encoder) error {
@@ -258,6 +267,8 @@ func (a *APIAlert) marshalTo(w *jsonWriter) {
 		a.Alertmanager[i].marshalTo(w)
 	}
 	w.endArray()
+	w.key("flapScore")
+	w.integer(a.FlapScore)
 	w.endObject()
 }
 
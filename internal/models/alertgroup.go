@@ -60,6 +60,7 @@ func (ag AlertGroup) ContentFingerprint() string {
 	h := xxhash.New()
 	for _, alert := range ag.Alerts {
 		_, _ = io.WriteString(h, alert.ContentFingerprint())
+		_, _ = io.WriteString(h, strconv.Itoa(alert.FlapScore))
 	}
 	return strconv.FormatUint(h.Sum64(), 16)
 }
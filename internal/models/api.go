@@ -290,6 +290,48 @@ func (s *APIAlertGroupSharedMaps) marshalTo(w *jsonWriter) {
 	w.endObject()
 }
 
+// AlertLifetime holds statistics about alerts with the same alertname, based
+// on alert history recorded by karma, all durations are in seconds
+type AlertLifetime struct {
+	Alertname string `json:"alertname"`
+	// number of times alerts started firing
+	Fired int `json:"fired"`
+	// number of times alerts stopped firing
+	Resolved int `json:"resolved"`
+	// number of times alerts were silenced while firing
+	Silenced int `json:"silenced"`
+	// median and 90th percentile of the time resolved alerts were firing
+	DurationMedian int `json:"durationMedian"`
+	DurationP90    int `json:"durationP90"`
+	// median time between alert firing and being silenced
+	TimeToSilenceMedian int `json:"timeToSilenceMedian"`
+}
+
+func (al AlertLifetime) MarshalJSONTo(enc *jsontext.Encoder) error {
+	w := jsonWriter{enc: enc}
+	al.marshalTo(&w)
+	return w.err
+}
+
+func (al *AlertLifetime) marshalTo(w *jsonWriter) {
+	w.beginObject()
+	w.key("alertname")
+	w.str(al.Alertname)
+	w.key("fired")
+	w.integer(al.Fired)
+	w.key("resolved")
+	w.integer(al.Resolved)
+	w.key("silenced")
+	w.integer(al.Silenced)
+	w.key("durationMedian")
+	w.integer(al.DurationMedian)
+	w.key("durationP90")
+	w.integer(al.DurationP90)
+	w.key("timeToSilenceMedian")
+	w.integer(al.TimeToSilenceMedian)
+	w.endObject()
+}
+
 // APIAlertGroup is how AlertGroup is returned in the API response.
 // All labels and annotations that are shared between all alerts in given group
 // are moved to Shared namespace, each alert instance only tracks labels and
@@ -305,10 +347,17 @@ type APIAlertGroup struct {
 	Labels            OrderedLabels                  `json:"labels"`
 	Alerts            []APIAlert                     `json:"alerts"`
 	TotalAlerts       int                            `json:"totalAlerts"`
+	// set only if there's recorded history for this group alertname
+	Lifetime *AlertLifetime `json:"lifetime,omitempty"`
 }
 
 func (ag APIAlertGroup) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w := jsonWriter{enc: enc}
+	ag.marshalTo(&w)
+	return w.err
+}
+
+func (ag *APIAlertGroup) marshalTo(w *jsonWriter) {
 	w.beginObject()
 	w.key("allLabels")
 	w.beginObject()
@@ -326,7 +375,7 @@ func (ag APIAlertGroup) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w.key("id")
 	w.str(ag.ID)
 	w.key("shared")
-	ag.Shared.marshalTo(&w)
+	ag.Shared.marshalTo(w)
 	w.key("labels")
 	w.beginArray()
 	for _, l := range ag.Labels {
@@ -341,13 +390,16 @@ func (ag APIAlertGroup) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w.key("alerts")
 	w.beginArray()
 	for i := range ag.Alerts {
-		ag.Alerts[i].marshalTo(&w)
+		ag.Alerts[i].marshalTo(w)
 	}
 	w.endArray()
 	w.key("totalAlerts")
 	w.integer(ag.TotalAlerts)
+	if ag.Lifetime != nil {
+		w.key("lifetime")
+		ag.Lifetime.marshalTo(w)
+	}
 	w.endObject()
-	return w.err
 }
 
 // NewAPIAlertGroup converts an AlertGroup into its API representation.
@@ -363,6 +415,7 @@ func NewAPIAlertGroup(ag AlertGroup, shared AlertGroupSharedMaps, allLabels map[
 			Annotations:  a.Annotations,
 			Labels:       LabelsToOrderedLabels(a.Labels),
 			Alertmanager: a.Alertmanager,
+			FlapScore:    a.FlapScore,
 		}
 	}
 	return APIAlertGroup{
@@ -514,7 +567,13 @@ func (ag *AlertGroup) dedupSilences(shared *AlertGroupSharedMaps) {
 }
 
 func (ag *AlertGroup) dedupSources(shared *AlertGroupSharedMaps) {
-	shared.Sources = []string{}
+	shared.Sources = ag.Sources()
+}
+
+// Sources returns a sorted list of unique Prometheus URIs that generated
+// alerts in this group
+func (ag *AlertGroup) Sources() []string {
+	sources := []string{}
 
 	urls := map[string]struct{}{}
 	var err error
@@ -539,9 +598,10 @@ func (ag *AlertGroup) dedupSources(shared *AlertGroupSharedMaps) {
 	}
 
 	for u := range urls {
-		shared.Sources = append(shared.Sources, u)
+		sources = append(sources, u)
 	}
-	sort.Strings(shared.Sources)
+	sort.Strings(sources)
+	return sources
 }
 
 func (ag *AlertGroup) dedupClusters(shared *AlertGroupSharedMaps) {
@@ -697,9 +757,58 @@ func (s SilenceFormStripSettings) MarshalJSONTo(enc *jsontext.Encoder) error {
 	return w.err
 }
 
+type SilenceTemplateMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+}
+
+// SilenceTemplate is a silence form preset defined in karma configuration
+type SilenceTemplate struct {
+	Name             string                   `json:"name"`
+	Matchers         []SilenceTemplateMatcher `json:"matchers"`
+	DurationSeconds  int                      `json:"durationSeconds"`
+	Comment          string                   `json:"comment"`
+	RequireTicket    bool                     `json:"requireTicket"`
+	RequiredMatchers []string                 `json:"requiredMatchers"`
+}
+
+func (w *jsonWriter) silenceTemplates(templates []SilenceTemplate) {
+	w.beginArray()
+	for _, t := range templates {
+		w.beginObject()
+		w.key("name")
+		w.str(t.Name)
+		w.key("matchers")
+		w.beginArray()
+		for _, m := range t.Matchers {
+			w.beginObject()
+			w.key("name")
+			w.str(m.Name)
+			w.key("value")
+			w.str(m.Value)
+			w.key("isRegex")
+			w.boolean(m.IsRegex)
+			w.endObject()
+		}
+		w.endArray()
+		w.key("durationSeconds")
+		w.integer(t.DurationSeconds)
+		w.key("comment")
+		w.str(t.Comment)
+		w.key("requireTicket")
+		w.boolean(t.RequireTicket)
+		w.key("requiredMatchers")
+		w.strings(t.RequiredMatchers)
+		w.endObject()
+	}
+	w.endArray()
+}
+
 type SilenceFormSettings struct {
 	Strip                SilenceFormStripSettings `json:"strip"`
 	DefaultAlertmanagers []string                 `json:"defaultAlertmanagers"`
+	Templates            []SilenceTemplate        `json:"templates"`
 }
 
 func (s SilenceFormSettings) MarshalJSONTo(enc *jsontext.Encoder) error {
@@ -712,6 +821,8 @@ func (s SilenceFormSettings) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w.endObject()
 	w.key("defaultAlertmanagers")
 	w.strings(s.DefaultAlertmanagers)
+	w.key("templates")
+	w.silenceTemplates(s.Templates)
 	w.endObject()
 	return w.err
 }
@@ -814,6 +925,8 @@ func (s Settings) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w.endObject()
 	w.key("defaultAlertmanagers")
 	w.strings(s.SilenceForm.DefaultAlertmanagers)
+	w.key("templates")
+	w.silenceTemplates(s.SilenceForm.Templates)
 	w.endObject()
 	w.key("annotationsHidden")
 	w.strings(s.AnnotationsHidden)
@@ -871,6 +984,11 @@ type APIGrid struct {
 
 func (g APIGrid) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w := jsonWriter{enc: enc}
+	g.marshalTo(&w)
+	return w.err
+}
+
+func (g *APIGrid) marshalTo(w *jsonWriter) {
 	w.beginObject()
 	w.key("stateCount")
 	w.mapStringInt(g.StateCount)
@@ -881,51 +999,12 @@ func (g APIGrid) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w.key("alertGroups")
 	w.beginArray()
 	for i := range g.AlertGroups {
-		ag := &g.AlertGroups[i]
-		w.beginObject()
-		w.key("allLabels")
-		w.beginObject()
-		for state, labels := range ag.AllLabels {
-			w.key(state)
-			w.mapStringStringSlice(labels)
-		}
-		w.endObject()
-		w.key("alertmanagerCount")
-		w.mapStringInt(ag.AlertmanagerCount)
-		w.key("stateCount")
-		w.mapStringInt(ag.StateCount)
-		w.key("receiver")
-		w.str(ag.Receiver)
-		w.key("id")
-		w.str(ag.ID)
-		w.key("shared")
-		ag.Shared.marshalTo(&w)
-		w.key("labels")
-		w.beginArray()
-		for _, l := range ag.Labels {
-			w.beginObject()
-			w.key("name")
-			w.str(l.Name)
-			w.key("value")
-			w.str(l.Value)
-			w.endObject()
-		}
-		w.endArray()
-		w.key("alerts")
-		w.beginArray()
-		for j := range ag.Alerts {
-			ag.Alerts[j].marshalTo(&w)
-		}
-		w.endArray()
-		w.key("totalAlerts")
-		w.integer(ag.TotalAlerts)
-		w.endObject()
+		g.AlertGroups[i].marshalTo(w)
 	}
 	w.endArray()
 	w.key("totalGroups")
 	w.integer(g.TotalGroups)
 	w.endObject()
-	return w.err
 }
 
 // nolint: maligned
@@ -1071,61 +1150,7 @@ func (r AlertsResponse) MarshalJSONTo(enc *jsontext.Encoder) error {
 	w.key("grids")
 	w.beginArray()
 	for i := range r.Grids {
-		g := &r.Grids[i]
-		w.beginObject()
-		w.key("stateCount")
-		w.mapStringInt(g.StateCount)
-		w.key("labelName")
-		w.str(g.LabelName)
-		w.key("labelValue")
-		w.str(g.LabelValue)
-		w.key("alertGroups")
-		w.beginArray()
-		for j := range g.AlertGroups {
-			ag := &g.AlertGroups[j]
-			w.beginObject()
-			w.key("allLabels")
-			w.beginObject()
-			for state, labels := range ag.AllLabels {
-				w.key(state)
-				w.mapStringStringSlice(labels)
-			}
-			w.endObject()
-			w.key("alertmanagerCount")
-			w.mapStringInt(ag.AlertmanagerCount)
-			w.key("stateCount")
-			w.mapStringInt(ag.StateCount)
-			w.key("receiver")
-			w.str(ag.Receiver)
-			w.key("id")
-			w.str(ag.ID)
-			w.key("shared")
-			ag.Shared.marshalTo(&w)
-			w.key("labels")
-			w.beginArray()
-			for _, l := range ag.Labels {
-				w.beginObject()
-				w.key("name")
-				w.str(l.Name)
-				w.key("value")
-				w.str(l.Value)
-				w.endObject()
-			}
-			w.endArray()
-			w.key("alerts")
-			w.beginArray()
-			for k := range ag.Alerts {
-				ag.Alerts[k].marshalTo(&w)
-			}
-			w.endArray()
-			w.key("totalAlerts")
-			w.integer(ag.TotalAlerts)
-			w.endObject()
-		}
-		w.endArray()
-		w.key("totalGroups")
-		w.integer(g.TotalGroups)
-		w.endObject()
+		r.Grids[i].marshalTo(&w)
 	}
 	w.endArray()
 	w.key("labelNames")
@@ -324,11 +324,24 @@ func TestMarshalJSONTo_MatchesV1(t *testing.T) {
 			},
 		},
 		{
-			// silence form settings with nested strip
+			// silence form settings with nested strip and templates
 			name: "SilenceFormSettings/full",
 			val: models.SilenceFormSettings{
 				Strip:                models.SilenceFormStripSettings{Labels: []string{"job"}},
 				DefaultAlertmanagers: []string{"am1"},
+				Templates: []models.SilenceTemplate{
+					{
+						Name: "deploy",
+						Matchers: []models.SilenceTemplateMatcher{
+							{Name: "job", Value: "api"},
+							{Name: "instance", Value: "api.+", IsRegex: true},
+						},
+						DurationSeconds:  3600,
+						Comment:          "Deploy window, ticket: ",
+						RequireTicket:    true,
+						RequiredMatchers: []string{"instance"},
+					},
+				},
 			},
 		},
 		{
@@ -363,6 +376,7 @@ func TestMarshalJSONTo_MatchesV1(t *testing.T) {
 				SilenceForm: models.SilenceFormSettings{
 					Strip:                models.SilenceFormStripSettings{Labels: []string{}},
 					DefaultAlertmanagers: []string{},
+					Templates:            []models.SilenceTemplate{},
 				},
 				AnnotationsHidden:  []string{"help"},
 				AnnotationsVisible: []string{"summary"},
@@ -402,6 +416,40 @@ func TestMarshalJSONTo_MatchesV1(t *testing.T) {
 				Total: 5,
 			},
 		},
+		{
+			// alert lifetime statistics
+			name: "AlertLifetime/full",
+			val: models.AlertLifetime{
+				Alertname:           "Test",
+				Fired:               10,
+				Resolved:            9,
+				Silenced:            2,
+				DurationMedian:      300,
+				DurationP90:         1800,
+				TimeToSilenceMedian: 600,
+			},
+		},
+		{
+			// alert group with lifetime statistics set
+			name: "APIAlertGroup/lifetime",
+			val: models.APIAlertGroup{
+				AllLabels:         map[string]map[string][]string{},
+				AlertmanagerCount: map[string]int{"am1": 1},
+				StateCount:        map[string]int{"active": 1},
+				Receiver:          "default",
+				ID:                "group-1",
+				Shared: models.APIAlertGroupSharedMaps{
+					Annotations: models.Annotations{},
+					Labels:      models.OrderedLabels{},
+					Silences:    map[string][]string{},
+					Sources:     []string{},
+					Clusters:    []string{},
+				},
+				Labels:   models.OrderedLabels{{Name: "alertname", Value: "Test"}},
+				Alerts:   []models.APIAlert{},
+				Lifetime: &models.AlertLifetime{Alertname: "Test", Fired: 1},
+			},
+		},
 		{
 			// API grid with state counts, label info, and nested alert groups
 			name: "APIGrid/full",
@@ -482,6 +530,7 @@ func TestMarshalJSONTo_MatchesV1(t *testing.T) {
 							},
 						},
 						TotalAlerts: 1,
+						Lifetime:    &models.AlertLifetime{Alertname: "Test", Fired: 3, Resolved: 2, DurationMedian: 60},
 					},
 				},
 				TotalGroups: 1,